@@ -1,18 +1,36 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
+	"tstore/internal/cache"
 	"tstore/internal/config"
+	"tstore/internal/davfs"
+	"tstore/internal/gateway"
+	"tstore/internal/index"
+	"tstore/internal/ingestion"
 	"tstore/internal/metadata"
+	"tstore/internal/mount"
+	"tstore/internal/policy"
+	"tstore/internal/s3gw"
+	"tstore/internal/secrets"
+	"tstore/internal/share"
 	tsync "tstore/internal/sync"
 	"tstore/internal/telegram"
 	"tstore/pkg/model"
 
+	"github.com/wailsapp/wails/v2/pkg/options"
 	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
@@ -22,20 +40,106 @@ type App struct {
 	uploader       *telegram.Uploader
 	client         *telegram.Client
 	store          metadata.Store
+	chunkCache     *cache.ChunkCache
+	contentIndex   *index.ContentIndex
+	sentLog        *telegram.SentLog
+	transfers      *telegram.TransferLog
+	secrets        secrets.Store
+	vault          *secrets.Vault
+	mount          *mount.Mount
+	gateway        *gateway.Server
+	webdav         *davfs.Server
+	s3             *s3gw.Server
+	jobs           chan syncJob
+	syncMu         sync.RWMutex
+	syncSlots      chan struct{}
+	stopSync       context.CancelFunc
+	scrubMu        sync.Mutex
 	backupTickerMu sync.Mutex
 	backupTimer    *time.Timer
 }
 
 const DEBOUNCE_DURATION = time.Minute
 
+const POLICY_INTERVAL = 10 * time.Minute
+
+const REPAIR_INTERVAL = 24 * time.Hour
+
+const SCRUB_INTERVAL = 7 * 24 * time.Hour
+
+const GC_INTERVAL = 24 * time.Hour
+
+const TRASH_PURGE_INTERVAL = time.Hour
+
+const CONNECTION_TEST_TIMEOUT = 15 * time.Second
+
+// SERVICE_STOP_TIMEOUT bounds how long the servers get to finish in-flight
+// requests when they are stopped for a config change or on exit.
+const SERVICE_STOP_TIMEOUT = 10 * time.Second
+
+// MASTER_PASSWORD_ENV unlocks the secret vault at startup without a prompt.
+const MASTER_PASSWORD_ENV = "TSTORE_MASTER_PASSWORD"
+
+// STATS_LARGEST_FILES is how many of the largest files GetStorageStats
+// lists.
+const STATS_LARGEST_FILES = 10
+
+// DEFAULT_TRASH_RETENTION applies when trash_retention_days is not set.
+const DEFAULT_TRASH_RETENTION = 30 * 24 * time.Hour
+
+// DEFAULT_GC_GRACE applies to on-demand collection when gc_grace_days is
+// not set.
+const DEFAULT_GC_GRACE = 7 * 24 * time.Hour
+
 func (a *App) initServices() error {
-	newCfg, err := config.LoadConfig()
+	if a.secrets == nil {
+		if err := a.openSecrets(); err != nil {
+			return err
+		}
+	}
+	newCfg, err := config.LoadConfigWithSecrets(a.secrets)
+	locked := errors.Is(err, secrets.ErrLocked)
+	if locked {
+		// Start without credentials until the vault is unlocked.
+		newCfg, err = config.LoadConfig()
+		if err == nil {
+			newCfg.ClearStoredSecrets()
+		}
+	}
 	if err != nil {
 		return fmt.Errorf("loading config: %w", err)
 	}
+	validate := newCfg.Validate
+	if locked {
+		validate = newCfg.ValidateWithoutSecrets
+	}
+	if err := validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
 	a.cfg = newCfg
 
 	a.client = telegram.NewClient(a.cfg.BotToken)
+	if a.sentLog == nil {
+		cfgPath, err := config.ConfigPath()
+		if err != nil {
+			return err
+		}
+		a.sentLog, err = telegram.NewSentLog(filepath.Join(filepath.Dir(cfgPath), "sent.jsonl"))
+		if err != nil {
+			return fmt.Errorf("init sent message log: %w", err)
+		}
+	}
+	a.client.Sent = a.sentLog
+	if a.transfers == nil {
+		cfgPath, err := config.ConfigPath()
+		if err != nil {
+			return err
+		}
+		a.transfers, err = telegram.NewTransferLog(filepath.Join(filepath.Dir(cfgPath), "transfers.json"))
+		if err != nil {
+			return fmt.Errorf("init transfer log: %w", err)
+		}
+	}
 	if a.store == nil {
 		a.store, err = metadata.NewDefaultJSONStore()
 		if err != nil {
@@ -43,15 +147,333 @@ func (a *App) initServices() error {
 		}
 	}
 
-	const chunkSize = 5 * 1024 * 1024
-	a.uploader = telegram.NewUploader(a.client, a.store, a.cfg.SyncFolder, chunkSize)
+	if a.chunkCache == nil {
+		a.chunkCache, err = cache.NewDefaultChunkCache()
+		if err != nil {
+			return fmt.Errorf("init chunk cache: %w", err)
+		}
+	}
+
+	a.uploader = telegram.NewUploader(a.client, a.store, a.cfg.SyncFolder, a.cfg.ChunkSize)
+	a.uploader.Cache = a.chunkCache
+	a.uploader.Previews = true
+	a.uploader.Transfers = a.transfers
+
+	if len(a.cfg.Targets) > 0 {
+		a.uploader.Targets = a.storageTargets()
+		a.uploader.Placement, err = telegram.NewPlacement(a.cfg.Placement, a.uploader.Targets, a.cfg.FolderTargets, func() map[string]int64 {
+			recs, err := a.store.List(context.Background())
+			if err != nil {
+				log.Printf("failed to compute target usage: %v", err)
+			}
+			trashed, err := a.store.ListTrash(context.Background())
+			if err != nil {
+				log.Printf("failed to compute target usage: %v", err)
+			}
+			return a.uploader.TargetUsage(append(recs, trashed...))
+		})
+		if err != nil {
+			return fmt.Errorf("init placement: %w", err)
+		}
+		a.uploader.Replication = a.cfg.ReplicationFactor
+	}
+	if a.cfg.ContentIndex {
+		if a.contentIndex == nil {
+			a.contentIndex, err = index.NewDefaultContentIndex()
+			if err != nil {
+				return fmt.Errorf("init content index: %w", err)
+			}
+		}
+		a.uploader.Index = a.contentIndex
+	}
+	if a.cfg.ErasureDataShards > 0 {
+		a.uploader.Erasure, err = ingestion.NewErasure(a.cfg.ErasureDataShards, a.cfg.ErasureParityShards)
+		if err != nil {
+			return fmt.Errorf("init erasure coding: %w", err)
+		}
+	}
 
 	return nil
 }
 
+// openSecrets opens the encrypted vault next to config.json.
+func (a *App) openSecrets() error {
+	cfgPath, err := config.ConfigPath()
+	if err != nil {
+		return err
+	}
+	a.vault = secrets.NewVault(filepath.Join(filepath.Dir(cfgPath), "secrets.vault"))
+	a.secrets = a.vault
+	if password := os.Getenv(MASTER_PASSWORD_ENV); password != "" {
+		if err := a.vault.Unlock(password); err != nil {
+			return fmt.Errorf("unlocking secret vault: %w", err)
+		}
+	}
+	return nil
+}
+
+func (a *App) storageTargets() []*telegram.Target {
+	clients := map[string]*telegram.Client{a.cfg.BotToken: a.client}
+	targets := make([]*telegram.Target, 0, len(a.cfg.Targets))
+	for _, t := range a.cfg.Targets {
+		token := t.BotToken
+		if token == "" {
+			token = a.cfg.BotToken
+		}
+		if clients[token] == nil {
+			clients[token] = telegram.NewClient(token)
+			clients[token].Sent = a.sentLog
+		}
+		targets = append(targets, &telegram.Target{
+			Name:     t.Name,
+			Client:   clients[token],
+			ChatID:   t.ChatID,
+			MaxBytes: t.MaxBytes,
+		})
+	}
+	return targets
+}
+
+// syncJob is a new file the watcher found in Folder, the sync folder at
+// the time it was detected.
 type syncJob struct {
-	Path string
-	Name string
+	Path   string
+	Name   string
+	Folder string
+}
+
+// runSyncJobs uploads the files the watcher finds, up to concurrency at a
+// time. Each job runs on the config and uploader current when it started,
+// so restarting the sync services does not wait for uploads in flight.
+// Jobs that overlap form a batch, and metadata is backed up once when the
+// last of them finishes.
+func (a *App) runSyncJobs(ctx context.Context) {
+	var (
+		batchMu  sync.Mutex
+		running  int
+		uploaded bool
+	)
+	for job := range a.jobs {
+		batchMu.Lock()
+		running++
+		batchMu.Unlock()
+
+		a.syncMu.RLock()
+		slots, cfg, up := a.syncSlots, a.cfg, a.uploader
+		a.syncMu.RUnlock()
+
+		slots <- struct{}{}
+		go func() {
+			defer func() { <-slots }()
+			ok := runSyncJob(ctx, cfg, up, job)
+
+			batchMu.Lock()
+			running--
+			uploaded = uploaded || ok
+			done := running == 0 && uploaded
+			if done {
+				uploaded = false
+			}
+			batchMu.Unlock()
+
+			if done {
+				if err := up.BackupMetadata(ctx, cfg.ChatID); err != nil {
+					log.Printf("metadata backup failed: %v", err)
+				}
+			}
+		}()
+	}
+}
+
+// runSyncJob uploads one file with up and reports whether it was stored.
+func runSyncJob(ctx context.Context, cfg *config.Config, up *telegram.Uploader, job syncJob) bool {
+	if job.Folder != cfg.SyncFolder {
+		// The sync folder changed after the file was found. Follow it if
+		// it was moved along and drop it otherwise.
+		rel, err := filepath.Rel(job.Folder, job.Path)
+		if err != nil {
+			return false
+		}
+		job.Path = filepath.Join(cfg.SyncFolder, rel)
+		if _, err := os.Stat(job.Path); err != nil {
+			log.Printf("skipping %s: left behind in %s", job.Name, job.Folder)
+			return false
+		}
+	}
+
+	if _, err := up.Store.Get(ctx, job.Name); err == nil {
+		// Stored since the watcher found it, as when an upload moved the
+		// file into the sync folder.
+		return false
+	}
+
+	runtime.EventsEmit(ctx, "syncStart", job.Name)
+
+	_, err := up.UploadFileAs(ctx, job.Path, job.Name, cfg.ChatID,
+		func(p float64) {
+			runtime.EventsEmit(ctx, "syncProgress", job.Name, p)
+		},
+	)
+	if err != nil {
+		runtime.EventsEmit(ctx, "syncError", job.Name, err.Error())
+		return false
+	}
+	runtime.EventsEmit(ctx, "syncSuccess", job.Name)
+	return true
+}
+
+// startSyncServices starts every service that runs on the current config
+// and uploader: the sync folder watcher, the offload policy worker, the
+// repair, scrub, trash purge and GC loops, the bot, the mount and the
+// gateway, WebDAV and S3 servers. stopSyncServices stops them again; the
+// caller must hold syncMu for both.
+func (a *App) startSyncServices() {
+	ctx, cancel := context.WithCancel(a.ctx)
+	a.stopSync = cancel
+	a.syncSlots = make(chan struct{}, a.cfg.Concurrency)
+
+	folder := a.cfg.SyncFolder
+	backup := func(ctx context.Context) {
+		if err := a.uploader.BackupMetadata(ctx, a.cfg.ChatID); err != nil {
+			log.Printf("metadata backup failed: %v", err)
+		}
+	}
+	err := tsync.StartSyncWatcher(
+		ctx,
+		folder,
+		a.store,
+		func(ctx context.Context, path, name string) {
+			a.jobs <- syncJob{Path: path, Name: name, Folder: folder}
+		},
+		backup,
+		backup,
+	)
+	if err != nil {
+		log.Printf("failed to start sync watcher: %v", err)
+	}
+
+	worker := policy.NewWorker(
+		a.store,
+		a.cfg.SyncFolder,
+		a.cfg.OffloadRules,
+		a.cfg.MaxLocalBytes,
+		func(ctx context.Context, name string) error {
+			_, err := a.uploader.Offload(ctx, name)
+			return err
+		},
+		func(ctx context.Context, name string) error {
+			_, err := a.uploader.Download(ctx, name, func(p float64) {
+				runtime.EventsEmit(ctx, fmt.Sprintf("downloadProgress/%s", name), p)
+			})
+			return err
+		},
+		func(ctx context.Context) error {
+			return a.uploader.BackupMetadata(ctx, a.cfg.ChatID)
+		},
+	)
+	if worker.Enabled() {
+		go worker.Run(ctx, POLICY_INTERVAL)
+	}
+
+	if a.cfg.ReplicationFactor > 1 {
+		go a.uploader.RunRepair(ctx, a.cfg.ChatID, REPAIR_INTERVAL)
+	}
+
+	if a.cfg.ScrubMode != "" {
+		go a.uploader.RunScrub(ctx, telegram.ScrubOptions{Mode: a.cfg.ScrubMode}, SCRUB_INTERVAL, a.saveScrubReport)
+	}
+
+	go a.uploader.RunTrashPurge(ctx, a.cfg.ChatID, a.trashRetention(), TRASH_PURGE_INTERVAL)
+
+	if a.cfg.GCGraceDays > 0 {
+		go a.uploader.RunGC(ctx, a.cfg.ChatID, telegram.GCOptions{Grace: a.gcGrace()}, GC_INTERVAL)
+	}
+
+	if len(a.cfg.BotAllowedUsers) > 0 {
+		bot := telegram.NewBot(a.client, a.store, a.uploader, a.cfg.BotAllowedUsers)
+		go bot.Run(ctx)
+	}
+
+	if a.cfg.MountPoint != "" {
+		a.mount, err = mount.New(a.cfg.MountPoint, a.cfg.SyncFolder, a.store, a.uploader)
+		if err != nil {
+			log.Printf("failed to mount %q: %v", a.cfg.MountPoint, err)
+		}
+	}
+
+	if a.cfg.GatewayAddr != "" {
+		a.gateway = gateway.New(a.cfg.GatewayAddr, a.store, a.uploader)
+		if err := a.gateway.Start(); err != nil {
+			log.Printf("failed to start gateway: %v", err)
+			a.gateway = nil
+		}
+	}
+
+	if a.cfg.WebDAVAddr != "" {
+		if err := a.startWebDAV(); err != nil {
+			log.Printf("failed to start webdav server: %v", err)
+		}
+	}
+
+	if a.cfg.S3Addr != "" && a.s3 == nil {
+		if err := a.startS3(); err != nil {
+			log.Printf("failed to start s3 gateway: %v", err)
+		}
+	}
+}
+
+func (a *App) stopSyncServices() {
+	if a.stopSync != nil {
+		a.stopSync()
+		a.stopSync = nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), SERVICE_STOP_TIMEOUT)
+	defer cancel()
+	if a.mount != nil {
+		if err := a.mount.Unmount(); err != nil {
+			log.Printf("failed to unmount %q: %v", a.cfg.MountPoint, err)
+		}
+		a.mount = nil
+	}
+	if a.gateway != nil {
+		if err := a.gateway.Shutdown(ctx); err != nil {
+			log.Printf("failed to stop gateway: %v", err)
+		}
+		a.gateway = nil
+	}
+	if a.webdav != nil {
+		if err := a.webdav.Shutdown(ctx); err != nil {
+			log.Printf("failed to stop webdav server: %v", err)
+		}
+		a.webdav = nil
+	}
+	if a.s3 != nil {
+		stopS3(ctx, a.s3)
+		a.s3 = nil
+	}
+}
+
+func stopS3(ctx context.Context, srv *s3gw.Server) {
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Printf("failed to stop s3 gateway: %v", err)
+	}
+}
+
+// keepS3 hands srv, the S3 gateway started on old, to the current uploader
+// when its address and keys did not change, so clients are not cut off by
+// an unrelated config change. Otherwise it stops srv and startSyncServices
+// starts a new gateway.
+func (a *App) keepS3(srv *s3gw.Server, old *config.Config) {
+	if a.cfg.S3Addr == old.S3Addr && a.cfg.S3AccessKey == old.S3AccessKey && a.cfg.S3SecretKey == old.S3SecretKey {
+		srv.SetFiles(a.uploader, a.cfg.ChatID)
+		a.s3 = srv
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), SERVICE_STOP_TIMEOUT)
+	defer cancel()
+	stopS3(ctx, srv)
 }
 
 func (a *App) startup(ctx context.Context) {
@@ -59,67 +481,110 @@ func (a *App) startup(ctx context.Context) {
 	if err := a.initServices(); err != nil {
 		log.Fatalf("failed to init services: %v", err)
 	}
+	if a.vault != nil && a.vault.Locked() {
+		runtime.EventsEmit(ctx, "secretsLocked")
+		return
+	}
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	// Saving the config or unlocking the vault retries a failed start.
+	if err := a.start(ctx); err != nil {
+		log.Printf("failed to start: %v", err)
+	}
+}
+
+// start loads the metadata backup and starts the services once the config
+// and its secrets are loaded. A chat with no pinned backup yet starts with
+// the local metadata. The caller must hold syncMu.
+func (a *App) start(ctx context.Context) error {
+	if err := a.loadBackup(ctx); err != nil {
+		return err
+	}
+
+	if a.uploader.Index != nil {
+		if a.uploader.Index.Len() == 0 {
+			if err := a.uploader.RestoreContentIndex(ctx, a.cfg.ChatID); err != nil {
+				log.Printf("failed to restore content index: %v", err)
+			}
+		}
+		go func() {
+			if _, err := a.uploader.IndexLocalFiles(ctx); err != nil {
+				log.Printf("failed to index local files: %v", err)
+			}
+		}()
+	}
+
+	a.jobs = make(chan syncJob)
+	go a.runSyncJobs(ctx)
+	a.startSyncServices()
+	return nil
+}
 
+// loadBackup replaces the metadata with the backup pinned in the chat.
+func (a *App) loadBackup(ctx context.Context) error {
 	fileID, err := a.client.GetPinnedFileID(ctx, a.cfg.ChatID)
+	if errors.Is(err, telegram.ErrNoPinnedDocument) {
+		return nil
+	}
 	if err != nil {
-		log.Printf("failed to get pinned file ID: %v", err)
-		return
+		return fmt.Errorf("find metadata backup: %w", err)
 	}
 
 	reader, err := a.client.DownloadFile(ctx, fileID)
 	if err != nil {
-		log.Fatalf("failed to download file: %v", err)
+		return fmt.Errorf("download metadata backup: %w", err)
 	}
 	defer reader.Close()
 
 	if err := a.store.Load(ctx, reader); err != nil {
-		log.Fatalf("failed to load metadata: %v", err)
+		return fmt.Errorf("load metadata: %w", err)
 	}
+	return nil
+}
 
-	jobCh := make(chan syncJob)
+func (a *App) startWebDAV() error {
+	cfgPath, err := config.ConfigPath()
+	if err != nil {
+		return err
+	}
+	stagingDir := filepath.Join(filepath.Dir(cfgPath), "staging", "webdav")
 
-	go func() {
-		for job := range jobCh {
-			runtime.EventsEmit(ctx, "syncStart", job.Name)
+	fsys, err := davfs.NewFileSystem(a.store, a.uploader, a.cfg.ChatID, stagingDir)
+	if err != nil {
+		return fmt.Errorf("init webdav filesystem: %w", err)
+	}
 
-			_, err := a.uploader.UploadFile(ctx, job.Path, a.cfg.ChatID,
-				func(p float64) {
-					runtime.EventsEmit(ctx, "syncProgress", job.Name, p)
-				},
-			)
-			if err != nil {
-				runtime.EventsEmit(ctx, "syncError", job.Name, err.Error())
-			} else {
-				a.uploader.BackupMetadata(ctx, a.cfg.ChatID)
-				runtime.EventsEmit(ctx, "syncSuccess", job.Name)
-			}
-		}
-	}()
+	srv := davfs.NewServer(a.cfg.WebDAVAddr, a.cfg.WebDAVUser, a.cfg.WebDAVPassword, fsys)
+	if err := srv.Start(); err != nil {
+		return err
+	}
+	a.webdav = srv
+	return nil
+}
 
-	go func() {
-		backup := func(ctx context.Context) {
-			if err := a.uploader.BackupMetadata(ctx, a.cfg.ChatID); err != nil {
-				log.Printf("metadata backup failed: %v", err)
-			}
-		}
+func (a *App) startS3() error {
+	cfgPath, err := config.ConfigPath()
+	if err != nil {
+		return err
+	}
+	stagingDir := filepath.Join(filepath.Dir(cfgPath), "staging", "s3")
 
-		err := tsync.StartSyncWatcher(
-			a.ctx,
-			a.cfg.SyncFolder,
-			a.store,
-			func(ctx context.Context, path, name string) {
-				jobCh <- syncJob{Path: path, Name: name}
-			},
-			backup,
-			backup,
-		)
-		if err != nil {
-			log.Printf("failed to start sync watcher: %v", err)
-		}
-	}()
+	srv, err := s3gw.NewServer(a.cfg.S3Addr, a.cfg.S3AccessKey, a.cfg.S3SecretKey, a.cfg.ChatID, stagingDir, a.store, a.uploader)
+	if err != nil {
+		return fmt.Errorf("init s3 gateway: %w", err)
+	}
+	if err := srv.Start(); err != nil {
+		return err
+	}
+	a.s3 = srv
+	return nil
 }
 
 func (a *App) shutdown(ctx context.Context) {
+	a.syncMu.Lock()
+	a.stopSyncServices()
+	a.syncMu.Unlock()
+
 	a.backupTickerMu.Lock()
 	timer := a.backupTimer
 	a.backupTimer = nil
@@ -133,6 +598,58 @@ func (a *App) shutdown(ctx context.Context) {
 	}
 }
 
+func (a *App) onSecondInstanceLaunch(options.SecondInstanceData) {
+	runtime.WindowUnminimise(a.ctx)
+	runtime.WindowShow(a.ctx)
+}
+
+func (a *App) GetProfile() string {
+	return config.Profile()
+}
+
+func (a *App) ListProfiles() ([]string, error) {
+	return config.ListProfiles()
+}
+
+// OpenProfile starts another tstore process for the named profile,
+// creating the profile if it does not exist yet. If it is already open,
+// that window is brought to the front instead.
+func (a *App) OpenProfile(name string) error {
+	if !config.ValidProfileName(name) {
+		return fmt.Errorf("invalid profile name %q: use lower-case letters, digits, '-' and '_'", name)
+	}
+	if name == config.Profile() {
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := config.ConfigDir()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(exe, "-config-dir", dir, "-profile", name)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("starting profile %q: %w", name, err)
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// SwitchProfile opens the named profile and closes this one.
+func (a *App) SwitchProfile(name string) error {
+	if name == config.Profile() {
+		return nil
+	}
+	if err := a.OpenProfile(name); err != nil {
+		return err
+	}
+	runtime.Quit(a.ctx)
+	return nil
+}
+
 func (a *App) Minimize() {
 	runtime.WindowMinimise(a.ctx)
 }
@@ -175,28 +692,189 @@ func (a *App) SelectFile() (string, error) {
 	return file, nil
 }
 
-func (a *App) UpdateConfig(newCfg *config.Config) error {
-	if err := a.validateAndSaveConfig(newCfg); err != nil {
-		return err
+// UpdateConfig saves newCfg and restarts the services with it. When the
+// sync folder changes and moveFiles is set, the files in the old folder
+// are moved to the new one first. The returned warnings describe missing
+// bot rights that do not stop files from being stored.
+func (a *App) UpdateConfig(newCfg *config.Config, moveFiles bool) ([]string, error) {
+	var oldFolder string
+	oldCfg := a.cfg
+	if oldCfg != nil {
+		oldFolder = oldCfg.SyncFolder
+	}
+	warnings, err := a.validateAndSaveConfig(newCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+
+	// The S3 gateway is left running until the new config shows whether it
+	// can be kept.
+	s3 := a.s3
+	a.s3 = nil
+	a.stopSyncServices()
+	var moveErr error
+	if moveFiles && oldFolder != "" && oldFolder != newCfg.SyncFolder {
+		moved, conflicts, err := tsync.MoveFolder(oldFolder, newCfg.SyncFolder)
+		if err != nil {
+			moveErr = fmt.Errorf("moving files to the new sync folder: %w", err)
+		} else if len(conflicts) > 0 {
+			moveErr = fmt.Errorf("moved %d files; %d already existed in the new sync folder and were left in %s: %s",
+				moved, len(conflicts), oldFolder, strings.Join(conflicts, ", "))
+		}
 	}
 
 	if err := a.initServices(); err != nil {
-		return err
+		if s3 != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), SERVICE_STOP_TIMEOUT)
+			defer cancel()
+			stopS3(ctx, s3)
+		}
+		return warnings, err
+	}
+	if s3 != nil {
+		a.keepS3(s3, oldCfg)
+	}
+	if oldFolder != "" && oldFolder != newCfg.SyncFolder {
+		// Files that were not moved are only in the chat now.
+		if _, err := a.uploader.OffloadMissing(a.ctx, a.cfg.ChatID); err != nil {
+			moveErr = errors.Join(moveErr, fmt.Errorf("marking files left in %s offloaded: %w", oldFolder, err))
+		}
+	}
+	switch {
+	case a.jobs != nil:
+		a.startSyncServices()
+	case a.vault == nil || !a.vault.Locked():
+		// The services never started, as when the chat had no backup or
+		// could not be reached at startup.
+		if err := a.start(a.ctx); err != nil {
+			return warnings, errors.Join(moveErr, err)
+		}
 	}
 
-	return nil
+	return warnings, moveErr
 }
 
-func (a *App) validateAndSaveConfig(newCfg *config.Config) error {
+// validateAndSaveConfig checks newCfg and saves it. The bots of the primary
+// chat and of every storage target whose token or chat changed are tested;
+// saving is refused only when a bot cannot store files at all.
+func (a *App) validateAndSaveConfig(newCfg *config.Config) ([]string, error) {
 	if info, err := os.Stat(newCfg.SyncFolder); err != nil || !info.IsDir() {
-		return fmt.Errorf("invalid sync_folder %q", newCfg.SyncFolder)
+		return nil, fmt.Errorf("invalid sync_folder %q", newCfg.SyncFolder)
+	}
+	if err := newCfg.Validate(); err != nil {
+		return nil, err
+	}
+	if newCfg.GatewayAddr != "" {
+		if err := gateway.ValidateAddr(newCfg.GatewayAddr); err != nil {
+			return nil, fmt.Errorf("invalid gateway_addr %q: %w", newCfg.GatewayAddr, err)
+		}
+	}
+	if newCfg.ScrubMode != "" && !telegram.ValidScrubMode(newCfg.ScrubMode) {
+		return nil, fmt.Errorf("unknown scrub_mode %q", newCfg.ScrubMode)
+	}
+	if len(newCfg.Targets) > 0 {
+		targets := make([]*telegram.Target, 0, len(newCfg.Targets))
+		for _, t := range newCfg.Targets {
+			targets = append(targets, &telegram.Target{Name: t.Name})
+		}
+		if _, err := telegram.NewPlacement(newCfg.Placement, targets, newCfg.FolderTargets, nil); err != nil {
+			return nil, fmt.Errorf("invalid placement: %w", err)
+		}
+	}
+
+	var warnings []string
+	if a.cfg == nil || newCfg.BotToken != a.cfg.BotToken || newCfg.ChatID != a.cfg.ChatID {
+		w, err := a.checkChat("the chat", newCfg.BotToken, newCfg.ChatID, true)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, w...)
+	}
+	for _, t := range newCfg.Targets {
+		token := cmp.Or(t.BotToken, newCfg.BotToken)
+		if a.cfg != nil && slices.ContainsFunc(a.cfg.Targets, func(old config.Target) bool {
+			return old.Name == t.Name && old.ChatID == t.ChatID && cmp.Or(old.BotToken, a.cfg.BotToken) == token
+		}) {
+			continue
+		}
+		// Only the primary chat holds the pinned metadata backup.
+		w, err := a.checkChat(fmt.Sprintf("storage target %q", t.Name), token, t.ChatID, false)
+		if err != nil {
+			return nil, err
+		}
+		warnings = append(warnings, w...)
 	}
 
-	if err := config.SaveConfig(newCfg); err != nil {
-		return fmt.Errorf("saving config: %w", err)
+	if err := config.SaveConfigWithSecrets(newCfg, a.secrets); err != nil {
+		return nil, fmt.Errorf("saving config: %w", err)
 	}
 
-	return nil
+	return warnings, nil
+}
+
+// checkChat tests a bot's access to a chat. An invalid token, an unreachable
+// chat or a bot that may not post fail the check; missing pin and delete
+// rights are returned as warnings.
+func (a *App) checkChat(what, botToken, chatID string, needPin bool) ([]string, error) {
+	report, err := a.TestConnection(botToken, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", what, err)
+	}
+
+	var warnings []string
+	for i, permission := range report.Missing {
+		switch {
+		case permission == telegram.PermissionPost:
+			return nil, fmt.Errorf("bot @%s lacks access to %s: %s", report.BotName, what, report.Problems[i])
+		case permission == telegram.PermissionPin && !needPin:
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s: %s", what, report.Problems[i]))
+		}
+	}
+	return warnings, nil
+}
+
+// TestConnection checks a bot token and chat ID, such as ones typed into
+// the settings before saving, and reports which permissions the bot lacks.
+func (a *App) TestConnection(botToken, chatID string) (*telegram.AccessReport, error) {
+	ctx, cancel := context.WithTimeout(a.ctx, CONNECTION_TEST_TIMEOUT)
+	defer cancel()
+	return telegram.NewClient(botToken).CheckAccess(ctx, chatID)
+}
+
+func (a *App) GetSecretsStatus() *secrets.Status {
+	if a.vault == nil {
+		return &secrets.Status{Backend: secrets.BackendVault}
+	}
+	return &secrets.Status{
+		Backend: secrets.BackendVault,
+		Exists:  a.vault.Exists(),
+		Locked:  a.vault.Locked(),
+	}
+}
+
+// UnlockSecrets opens the secret vault, creating it with password on first
+// use, and finishes the startup that was waiting for credentials. When the
+// startup fails, as on a network error, the error is returned and calling
+// UnlockSecrets again retries it.
+func (a *App) UnlockSecrets(password string) error {
+	a.syncMu.Lock()
+	defer a.syncMu.Unlock()
+	if a.vault == nil || a.jobs != nil {
+		return nil
+	}
+	if a.vault.Locked() {
+		if err := a.vault.Unlock(password); err != nil {
+			return err
+		}
+	}
+	if err := a.initServices(); err != nil {
+		return err
+	}
+	return a.start(a.ctx)
 }
 
 func (a *App) GetConfig() *config.Config {
@@ -215,10 +893,38 @@ func (a *App) UploadFile(path string) (string, error) {
 	return rec.Name, nil
 }
 
+// UploadDirectory uploads a folder tree. It and the other directory methods
+// emit "dirProgress" events with the aggregate progress.
+func (a *App) UploadDirectory(path string) (*telegram.DirResult, error) {
+	return a.uploader.UploadDirectory(a.ctx, path, a.cfg.ChatID, a.emitDirProgress)
+}
+
+func (a *App) OffloadDirectory(dir string) (*telegram.DirResult, error) {
+	return a.uploader.OffloadDirectory(a.ctx, dir, a.cfg.ChatID, a.emitDirProgress)
+}
+
+func (a *App) DownloadDirectory(dir string) (*telegram.DirResult, error) {
+	return a.uploader.DownloadDirectory(a.ctx, dir, a.cfg.ChatID, a.emitDirProgress)
+}
+
+func (a *App) DeleteDirectory(dir string) (*telegram.DirResult, error) {
+	return a.uploader.DeleteDirectory(a.ctx, dir, a.cfg.ChatID, a.emitDirProgress)
+}
+
+func (a *App) emitDirProgress(p telegram.DirProgress) {
+	runtime.EventsEmit(a.ctx, "dirProgress", p)
+}
+
 func (a *App) GetFilesMetadata() ([]*model.FileRecord, error) {
 	return a.store.List(a.ctx)
 }
 
+// ListFiles returns one sorted page of files; pass the page's NextCursor
+// back in opts to get the next one.
+func (a *App) ListFiles(opts metadata.ListOptions) (*metadata.ListPage, error) {
+	return a.store.ListFiles(a.ctx, opts)
+}
+
 func (a *App) OffloadFile(name string) error {
 	return a.uploader.OffloadFile(a.ctx, name, a.cfg.ChatID)
 }
@@ -229,6 +935,127 @@ func (a *App) DownloadFile(name string) error {
 	})
 }
 
+func (a *App) ExtractRange(name string, offset, length int64, dstPath string) error {
+	return a.uploader.ExtractRange(a.ctx, name, offset, length, dstPath)
+}
+
+func (a *App) GetStreamURL(name string) (string, error) {
+	if a.gateway == nil {
+		return "", fmt.Errorf("streaming gateway is not enabled")
+	}
+	if _, err := a.store.Get(a.ctx, name); err != nil {
+		return "", fmt.Errorf("lookup %q: %w", name, err)
+	}
+	return a.gateway.URL(name), nil
+}
+
+func (a *App) RepairReplicas() (*telegram.RepairReport, error) {
+	return a.uploader.Repair(a.ctx, a.cfg.ChatID)
+}
+
+// ScrubFiles checks every chunk now, using the configured scrub mode or a
+// plain availability check, and saves the report.
+func (a *App) ScrubFiles() (*telegram.ScrubReport, error) {
+	if !a.scrubMu.TryLock() {
+		return nil, fmt.Errorf("a scrub is already running")
+	}
+	defer a.scrubMu.Unlock()
+
+	report, err := a.uploader.Scrub(a.ctx, telegram.ScrubOptions{Mode: a.cfg.ScrubMode})
+	if err != nil {
+		return nil, err
+	}
+	a.saveScrubReport(report)
+	return report, nil
+}
+
+// GetScrubReport returns the last saved scrub report, or nil before the
+// first scrub.
+func (a *App) GetScrubReport() (*telegram.ScrubReport, error) {
+	path, err := scrubReportPath()
+	if err != nil {
+		return nil, err
+	}
+	report, err := telegram.LoadScrubReport(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return report, err
+}
+
+func (a *App) saveScrubReport(report *telegram.ScrubReport) {
+	path, err := scrubReportPath()
+	if err == nil {
+		err = telegram.SaveScrubReport(path, report)
+	}
+	if err != nil {
+		log.Printf("failed to save scrub report: %v", err)
+		return
+	}
+	runtime.EventsEmit(a.ctx, "scrubFinished")
+}
+
+func scrubReportPath() (string, error) {
+	cfgPath, err := config.ConfigPath()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(filepath.Dir(cfgPath), "scrub-report.json"), nil
+}
+
+// CollectGarbage deletes chunk and backup messages nothing references any
+// more. With dryRun set it only reports what would be deleted.
+func (a *App) CollectGarbage(dryRun bool) (*telegram.GCReport, error) {
+	return a.uploader.CollectGarbage(a.ctx, a.cfg.ChatID, telegram.GCOptions{
+		Grace:  a.gcGrace(),
+		DryRun: dryRun,
+	})
+}
+
+func (a *App) gcGrace() time.Duration {
+	if a.cfg.GCGraceDays > 0 {
+		return time.Duration(a.cfg.GCGraceDays) * 24 * time.Hour
+	}
+	return DEFAULT_GC_GRACE
+}
+
+// ExportShare returns a share token for name and, when dstPath is set, also
+// writes the manifest there.
+func (a *App) ExportShare(name, dstPath string) (string, error) {
+	m, err := a.uploader.ExportShare(a.ctx, name)
+	if err != nil {
+		return "", err
+	}
+	if dstPath != "" {
+		if err := m.Save(dstPath); err != nil {
+			return "", fmt.Errorf("write share manifest: %w", err)
+		}
+	}
+	return m.Token()
+}
+
+// ImportShare accepts a share token or the path of a manifest file.
+func (a *App) ImportShare(tokenOrPath string) (string, error) {
+	var m *share.Manifest
+	var err error
+	if _, statErr := os.Stat(tokenOrPath); statErr == nil {
+		m, err = share.Load(tokenOrPath)
+	} else {
+		m, err = share.Parse(tokenOrPath)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	rec, err := a.uploader.ImportShare(a.ctx, m, "", a.cfg.ChatID, func(p float64) {
+		runtime.EventsEmit(a.ctx, fmt.Sprintf("downloadProgress/%s", m.Name), p)
+	})
+	if err != nil {
+		return "", err
+	}
+	return rec.Name, nil
+}
+
 func (a *App) UpdateDescription(name, description string) error {
 	rec, err := a.store.Get(a.ctx, name)
 	if err != nil {
@@ -244,6 +1071,103 @@ func (a *App) UpdateDescription(name, description string) error {
 	return nil
 }
 
+func (a *App) SetTags(name string, tags []string) error {
+	rec, err := a.store.Get(a.ctx, name)
+	if err != nil {
+		return fmt.Errorf("lookup %q: %w", name, err)
+	}
+
+	rec.Tags = metadata.NormalizeTags(tags)
+	if err := a.store.Update(a.ctx, rec); err != nil {
+		return fmt.Errorf("update metadata: %w", err)
+	}
+
+	a.scheduleBackup()
+	return nil
+}
+
+// SetAttributes replaces a file's attributes; empty values are dropped.
+func (a *App) SetAttributes(name string, attrs map[string]string) error {
+	rec, err := a.store.Get(a.ctx, name)
+	if err != nil {
+		return fmt.Errorf("lookup %q: %w", name, err)
+	}
+
+	rec.Attributes = nil
+	for k, v := range attrs {
+		k = strings.TrimSpace(k)
+		if k == "" || v == "" {
+			continue
+		}
+		if strings.ContainsAny(k, "= ") {
+			return fmt.Errorf("invalid attribute name %q", k)
+		}
+		if rec.Attributes == nil {
+			rec.Attributes = make(map[string]string)
+		}
+		rec.Attributes[k] = v
+	}
+	if err := a.store.Update(a.ctx, rec); err != nil {
+		return fmt.Errorf("update metadata: %w", err)
+	}
+
+	a.scheduleBackup()
+	return nil
+}
+
+// SearchFiles returns the files matching a query; see metadata.Query for
+// the syntax.
+func (a *App) SearchFiles(query string) ([]*model.FileRecord, error) {
+	recs, err := a.store.List(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.Search(recs, query)
+}
+
+// SearchContent returns the files whose text contains every word of query,
+// best matches first. It needs content_index to be enabled.
+func (a *App) SearchContent(query string) ([]*model.FileRecord, error) {
+	if a.uploader.Index == nil {
+		return nil, errors.New("content indexing is disabled")
+	}
+	recs, err := a.store.List(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	return a.uploader.Index.Search(recs, query), nil
+}
+
+func (a *App) GetStorageStats() (*telegram.StorageStats, error) {
+	return a.uploader.StorageStats(a.ctx, STATS_LARGEST_FILES)
+}
+
+// GetTransferHistory returns the bytes uploaded and downloaded on each of
+// the last days days, oldest first.
+func (a *App) GetTransferHistory(days int) ([]telegram.DayTransfer, error) {
+	if days < 1 || days > telegram.TransferRetentionDays {
+		return nil, fmt.Errorf("days must be between 1 and %d", telegram.TransferRetentionDays)
+	}
+	return a.transfers.History(days), nil
+}
+
+// GetPreview returns the stored preview of a file without downloading it:
+// a data URL for image thumbnails, or the snippet itself for text.
+func (a *App) GetPreview(name string) (string, error) {
+	rec, err := a.store.Get(a.ctx, name)
+	if err != nil {
+		return "", err
+	}
+	data, err := a.uploader.ReadPreview(a.ctx, rec)
+	if err != nil {
+		return "", err
+	}
+	if rec.Preview.Kind == model.PreviewText {
+		return string(data), nil
+	}
+	return "data:" + rec.Preview.MIME + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
+
 func (a *App) scheduleBackup() {
 	a.backupTickerMu.Lock()
 	defer a.backupTickerMu.Unlock()
@@ -264,6 +1188,30 @@ func (a *App) scheduleBackup() {
 	})
 }
 
+// DeleteFile moves a file to the trash; see RestoreFromTrash.
 func (a *App) DeleteFile(name string) error {
 	return a.uploader.DeleteFile(a.ctx, name, a.cfg.ChatID)
 }
+
+// GetTrash lists trashed files, most recently deleted first.
+func (a *App) GetTrash() ([]*model.FileRecord, error) {
+	recs, err := a.store.ListTrash(a.ctx)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(recs, func(x, y *model.FileRecord) int {
+		return y.TrashedAt.Compare(*x.TrashedAt)
+	})
+	return recs, nil
+}
+
+func (a *App) RestoreFromTrash(name string) error {
+	return a.uploader.RestoreFromTrash(a.ctx, name, a.cfg.ChatID)
+}
+
+func (a *App) trashRetention() time.Duration {
+	if a.cfg.TrashRetentionDays > 0 {
+		return time.Duration(a.cfg.TrashRetentionDays) * 24 * time.Hour
+	}
+	return DEFAULT_TRASH_RETENTION
+}
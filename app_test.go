@@ -0,0 +1,331 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tstore/internal/config"
+	"tstore/internal/secrets"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+)
+
+// fakeChats is a Bot API server for a bot that is alone in private chats
+// with nothing pinned. It records the chat of every getChat call.
+type fakeChats struct {
+	mu    sync.Mutex
+	chats []string
+}
+
+func (f *fakeChats) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getMe"):
+		fmt.Fprint(w, `{"ok":true,"result":{"id":7,"is_bot":true,"username":"fake_bot"}}`)
+	case strings.HasSuffix(r.URL.Path, "/getChat"):
+		f.mu.Lock()
+		f.chats = append(f.chats, r.URL.Query().Get("chat_id"))
+		f.mu.Unlock()
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"type":"private"}}`)
+	case strings.HasSuffix(r.URL.Path, "/sendDocument"):
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"document":{"file_id":"backup"}}}`)
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}
+}
+
+// waitFor waits until chat has been looked up and returns every lookup so
+// far.
+func (f *fakeChats) waitFor(t *testing.T, chat string) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		f.mu.Lock()
+		chats := slices.Clone(f.chats)
+		f.mu.Unlock()
+		if slices.Contains(chats, chat) {
+			return chats
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("chat %s was never looked up", chat)
+	return nil
+}
+
+func (f *fakeChats) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.chats = nil
+}
+
+func TestUpdateConfig_RestartsMaintenanceOnNewChat(t *testing.T) {
+	t.Setenv(config.ConfigDirEnv, t.TempDir())
+	t.Setenv(MASTER_PASSWORD_ENV, "pw")
+
+	api := &fakeChats{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	defer func(url string) { telegram.APIURL = url }(telegram.APIURL)
+	telegram.APIURL = srv.URL
+
+	cfg := config.Defaults()
+	cfg.BotToken = "123456:abcdefghijklmnopqrstuvwxyzABCDEFGHI"
+	cfg.ChatID = "1"
+	cfg.SyncFolder = t.TempDir()
+	cfg.GCGraceDays = 1
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	a := &App{ctx: context.Background()}
+	if err := a.initServices(); err != nil {
+		t.Fatalf("initServices: %v", err)
+	}
+	a.jobs = make(chan syncJob)
+	a.syncMu.Lock()
+	a.startSyncServices()
+	a.syncMu.Unlock()
+	defer a.shutdown(context.Background())
+
+	// GC looks for the pinned backup in the storage chat as soon as it
+	// starts.
+	api.waitFor(t, "1")
+
+	newCfg := *a.cfg
+	newCfg.ChatID = "2"
+	if _, err := a.UpdateConfig(&newCfg, false); err != nil {
+		t.Fatalf("UpdateConfig: %v", err)
+	}
+	if a.uploader.Client == nil || a.cfg.ChatID != "2" {
+		t.Fatalf("config after update = %+v", a.cfg)
+	}
+
+	// The connection test has looked at chat 2 by now; what follows is the
+	// restarted GC.
+	api.reset()
+	chats := api.waitFor(t, "2")
+	if slices.Contains(chats, "1") {
+		t.Errorf("services still used the old chat after the update: %v", chats)
+	}
+}
+
+func TestInitServices_LockedVaultWithServerSecrets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(config.ConfigDirEnv, dir)
+
+	api := &fakeChats{}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+	defer func(url string) { telegram.APIURL = url }(telegram.APIURL)
+	telegram.APIURL = srv.URL
+
+	vault := secrets.NewVault(filepath.Join(dir, "secrets.vault"))
+	if err := vault.Unlock("pw"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	for name, value := range map[string]string{
+		"bot_token":       "123456:abcdefghijklmnopqrstuvwxyzABCDEFGHI",
+		"webdav_password": "dav-secret",
+		"s3_secret_key":   "s3-secret",
+	} {
+		if err := vault.Set(name, value); err != nil {
+			t.Fatalf("Set %s: %v", name, err)
+		}
+	}
+
+	cfg := config.Defaults()
+	cfg.ChatID = "1"
+	cfg.SyncFolder = t.TempDir()
+	cfg.WebDAVAddr = "127.0.0.1:0"
+	cfg.WebDAVUser = "dav"
+	cfg.S3Addr = "127.0.0.1:0"
+	cfg.S3AccessKey = "s3"
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	a := &App{ctx: context.Background()}
+	if err := a.initServices(); err != nil {
+		t.Fatalf("initServices with the vault locked: %v", err)
+	}
+	if !a.vault.Locked() || a.cfg.WebDAVPassword != "" || a.cfg.S3SecretKey != "" {
+		t.Fatalf("secrets loaded before unlocking: %+v", a.cfg.Redacted())
+	}
+
+	if err := a.UnlockSecrets("pw"); err != nil {
+		t.Fatalf("UnlockSecrets: %v", err)
+	}
+	defer a.shutdown(context.Background())
+	if a.cfg.WebDAVPassword != "dav-secret" || a.cfg.S3SecretKey != "s3-secret" {
+		t.Errorf("secrets after unlocking: webdav %q, s3 %q", a.cfg.WebDAVPassword, a.cfg.S3SecretKey)
+	}
+}
+
+func TestUpdateConfig_NewSyncFolderWithoutMoving(t *testing.T) {
+	t.Setenv(config.ConfigDirEnv, t.TempDir())
+	t.Setenv(MASTER_PASSWORD_ENV, "pw")
+
+	srv := httptest.NewServer(&fakeChats{})
+	defer srv.Close()
+	defer func(url string) { telegram.APIURL = url }(telegram.APIURL)
+	telegram.APIURL = srv.URL
+
+	oldFolder, newFolder := t.TempDir(), t.TempDir()
+	cfg := config.Defaults()
+	cfg.BotToken = "123456:abcdefghijklmnopqrstuvwxyzABCDEFGHI"
+	cfg.ChatID = "1"
+	cfg.SyncFolder = oldFolder
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	a := &App{ctx: context.Background()}
+	if err := a.initServices(); err != nil {
+		t.Fatalf("initServices: %v", err)
+	}
+	ctx := context.Background()
+	for _, name := range []string{"a.txt", "both.txt"} {
+		if err := os.WriteFile(filepath.Join(oldFolder, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := a.store.Create(ctx, &model.FileRecord{Name: name, State: model.StateLocal}); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+	// A file that is already in the new folder stays local.
+	if err := os.WriteFile(filepath.Join(newFolder, "both.txt"), []byte("both.txt"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	newCfg := *a.cfg
+	newCfg.SyncFolder = newFolder
+	if _, err := a.UpdateConfig(&newCfg, false); err != nil {
+		t.Fatalf("UpdateConfig: %v", err)
+	}
+	defer a.shutdown(context.Background())
+
+	for name, want := range map[string]model.FileState{"a.txt": model.StateCloud, "both.txt": model.StateLocal} {
+		rec, err := a.store.Get(ctx, name)
+		if err != nil {
+			t.Fatalf("Get %s: %v", name, err)
+		}
+		if rec.State != want {
+			t.Errorf("%s is %s after changing the sync folder; want %s", name, rec.State, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(oldFolder, "a.txt")); err != nil {
+		t.Errorf("file left in the old folder: %v", err)
+	}
+}
+
+// pinnedBackup is a Bot API server whose chat has an empty metadata backup
+// pinned. Downloading it fails the first failures times.
+type pinnedBackup struct {
+	mu       sync.Mutex
+	failures int
+}
+
+func (f *pinnedBackup) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/getChat"):
+		fmt.Fprint(w, `{"ok":true,"result":{"id":1,"type":"private","pinned_message":{"message_id":1,"document":{"file_id":"backup"}}}}`)
+	case strings.HasSuffix(r.URL.Path, "/getFile"):
+		fmt.Fprint(w, `{"ok":true,"result":{"file_id":"backup","file_path":"metadata.json"}}`)
+	case strings.HasSuffix(r.URL.Path, "/metadata.json"):
+		f.mu.Lock()
+		fail := f.failures > 0
+		f.failures--
+		f.mu.Unlock()
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	default:
+		fmt.Fprint(w, `{"ok":true,"result":true}`)
+	}
+}
+
+func TestUnlockSecrets_RetriesFailedStart(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(config.ConfigDirEnv, dir)
+
+	srv := httptest.NewServer(&pinnedBackup{failures: 1})
+	defer srv.Close()
+	defer func(url string) { telegram.APIURL = url }(telegram.APIURL)
+	telegram.APIURL = srv.URL
+
+	vault := secrets.NewVault(filepath.Join(dir, "secrets.vault"))
+	if err := vault.Unlock("pw"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := vault.Set("bot_token", "123456:abcdefghijklmnopqrstuvwxyzABCDEFGHI"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	cfg := config.Defaults()
+	cfg.ChatID = "1"
+	cfg.SyncFolder = t.TempDir()
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	a := &App{ctx: context.Background()}
+	if err := a.initServices(); err != nil {
+		t.Fatalf("initServices: %v", err)
+	}
+	defer a.shutdown(context.Background())
+
+	if err := a.UnlockSecrets("pw"); err == nil {
+		t.Fatal("UnlockSecrets succeeded although the backup could not be downloaded")
+	}
+	if a.jobs != nil {
+		t.Fatal("services started without the metadata backup")
+	}
+	if err := a.UnlockSecrets("pw"); err != nil {
+		t.Fatalf("UnlockSecrets retry: %v", err)
+	}
+	if a.jobs == nil {
+		t.Error("services not started after the retry")
+	}
+}
+
+func TestUpdateConfig_StartsServicesOnNewChat(t *testing.T) {
+	t.Setenv(config.ConfigDirEnv, t.TempDir())
+	t.Setenv(MASTER_PASSWORD_ENV, "pw")
+
+	srv := httptest.NewServer(&fakeChats{})
+	defer srv.Close()
+	defer func(url string) { telegram.APIURL = url }(telegram.APIURL)
+	telegram.APIURL = srv.URL
+
+	cfg := config.Defaults()
+	cfg.BotToken = "123456:abcdefghijklmnopqrstuvwxyzABCDEFGHI"
+	cfg.ChatID = "1"
+	cfg.SyncFolder = t.TempDir()
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	a := &App{ctx: context.Background()}
+	if err := a.initServices(); err != nil {
+		t.Fatalf("initServices: %v", err)
+	}
+
+	// Nothing is pinned in the chat, so saving the config has to start the
+	// services on the empty store.
+	if _, err := a.UpdateConfig(a.cfg, false); err != nil {
+		t.Fatalf("UpdateConfig: %v", err)
+	}
+	defer a.shutdown(context.Background())
+	if a.jobs == nil || a.stopSync == nil {
+		t.Error("services not started after saving the config")
+	}
+}
@@ -1,6 +1,6 @@
 import { useMemo, useState } from "react";
 import styles from "./App.module.css";
-import { MenuBar } from "./components/menu-bar";
+import { MenuBar, View } from "./components/menu-bar";
 import { Files } from "./features/file/components/card";
 import { DetailsCard } from "./features/file/components/details-card";
 import {
@@ -12,6 +12,8 @@ import {
   CardTitle,
 } from "./components/ui/card";
 import { SettingsForm } from "./features/settings/components/form";
+import { UnlockDialog } from "./features/settings/components/unlock-dialog";
+import { Trash } from "./features/trash/components/card";
 import { Button } from "./components/ui/button";
 import { cx } from "class-variance-authority";
 import { Save } from "lucide-react";
@@ -19,7 +21,7 @@ import { useFilesContext } from "./lib/files-context";
 
 export function App() {
   const [collapsed, setCollapsed] = useState(false);
-  const [view, setView] = useState<"manager" | "settings">("manager");
+  const [view, setView] = useState<View>("manager");
 
   const { files, selectedFile: selectedFileId } = useFilesContext();
 
@@ -30,10 +32,11 @@ export function App() {
 
   return (
     <div className={styles.App}>
+      <UnlockDialog />
       <MenuBar currentView={view} setView={setView} />
       <div
         className={cx(styles.Content, {
-          "justify-center": view === "settings",
+          "justify-center": view !== "manager",
         })}
       >
         {view === "manager" ? (
@@ -44,6 +47,8 @@ export function App() {
             />
             {!collapsed && <DetailsCard file={selectedFile} />}
           </>
+        ) : view === "trash" ? (
+          <Trash />
         ) : (
           <Card className="flex flex-1">
             <CardHeader>
@@ -1,14 +1,24 @@
-import { ArrowLeft, Minus, Settings, Square, X } from "lucide-react";
+import {
+  ArrowLeft,
+  Minus,
+  Settings,
+  Square,
+  Trash2,
+  X,
+} from "lucide-react";
 import styles from "./styles.module.css";
 import { Minimize, ToggleFullscreen, Close } from "@/../wailsjs/go/main/App";
 import { Environment } from "@/../wailsjs//runtime/runtime";
 import { Button } from "../ui/button";
+import { ProfileMenu } from "../profile-menu";
 import { useQuery } from "@tanstack/react-query";
 import { cx } from "class-variance-authority";
 
+export type View = "manager" | "settings" | "trash";
+
 interface Props {
-  currentView: "manager" | "settings";
-  setView: (view: "manager" | "settings") => void;
+  currentView: View;
+  setView: (view: View) => void;
 }
 
 export function MenuBar({ currentView, setView }: Props) {
@@ -29,17 +39,27 @@ export function MenuBar({ currentView, setView }: Props) {
     >
       <div className="flex items-center gap-2">
         {currentView === "manager" ? (
-          <Button
-            size="icon"
-            variant="ghost"
-            onClick={() => setView("settings")}
-          >
-            <Settings />
-          </Button>
+          <>
+            <Button
+              size="icon"
+              variant="ghost"
+              onClick={() => setView("settings")}
+            >
+              <Settings />
+            </Button>
+            <Button
+              size="icon"
+              variant="ghost"
+              onClick={() => setView("trash")}
+            >
+              <Trash2 />
+            </Button>
+            <ProfileMenu />
+          </>
         ) : (
           <Button variant="ghost" onClick={() => setView("manager")}>
             <ArrowLeft />
-            Settings
+            {currentView === "settings" ? "Settings" : "Trash"}
           </Button>
         )}
       </div>
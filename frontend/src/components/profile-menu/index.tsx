@@ -0,0 +1,108 @@
+import { Button } from "@/components/ui/button";
+import {
+  DropdownMenu,
+  DropdownMenuContent,
+  DropdownMenuItem,
+  DropdownMenuLabel,
+  DropdownMenuRadioGroup,
+  DropdownMenuRadioItem,
+  DropdownMenuSeparator,
+  DropdownMenuSub,
+  DropdownMenuSubContent,
+  DropdownMenuSubTrigger,
+  DropdownMenuTrigger,
+} from "@/components/ui/dropdown-menu";
+import { Input } from "@/components/ui/input";
+import { AppWindow, Plus, UserRound } from "lucide-react";
+import { FormEvent, useState } from "react";
+import { useMutation, useQuery } from "@tanstack/react-query";
+import { toast } from "sonner";
+import {
+  GetProfile,
+  ListProfiles,
+  OpenProfile,
+  SwitchProfile,
+} from "@/../wailsjs/go/main/App";
+
+export function ProfileMenu() {
+  const [newName, setNewName] = useState("");
+
+  const { data: current } = useQuery({
+    queryKey: ["profile"],
+    queryFn: GetProfile,
+  });
+  const { data: profiles } = useQuery({
+    queryKey: ["profiles"],
+    queryFn: ListProfiles,
+  });
+
+  const onError = (e: unknown) => toast.error(String(e), { closeButton: true });
+  const { mutate: switchProfile } = useMutation({
+    mutationFn: SwitchProfile,
+    onError,
+  });
+  const { mutate: openProfile } = useMutation({
+    mutationFn: OpenProfile,
+    onError,
+  });
+
+  const handleCreate = (e: FormEvent) => {
+    e.preventDefault();
+    if (newName.trim() === "") return;
+    switchProfile(newName.trim());
+    setNewName("");
+  };
+
+  const others = (profiles || []).filter((name) => name !== current);
+
+  return (
+    <DropdownMenu>
+      <DropdownMenuTrigger asChild>
+        <Button variant="ghost" title="Profiles">
+          <UserRound />
+          {current}
+        </Button>
+      </DropdownMenuTrigger>
+      <DropdownMenuContent align="start">
+        <DropdownMenuLabel>Profiles</DropdownMenuLabel>
+        <DropdownMenuRadioGroup
+          value={current}
+          onValueChange={(name) => switchProfile(name)}
+        >
+          {(profiles || []).map((name) => (
+            <DropdownMenuRadioItem key={name} value={name}>
+              {name}
+            </DropdownMenuRadioItem>
+          ))}
+        </DropdownMenuRadioGroup>
+        {others.length > 0 && (
+          <DropdownMenuSub>
+            <DropdownMenuSubTrigger>
+              <AppWindow />
+              Open in new window
+            </DropdownMenuSubTrigger>
+            <DropdownMenuSubContent>
+              {others.map((name) => (
+                <DropdownMenuItem key={name} onSelect={() => openProfile(name)}>
+                  {name}
+                </DropdownMenuItem>
+              ))}
+            </DropdownMenuSubContent>
+          </DropdownMenuSub>
+        )}
+        <DropdownMenuSeparator />
+        <form onSubmit={handleCreate} className="flex items-center gap-1 p-1">
+          <Input
+            placeholder="New profile"
+            value={newName}
+            onChange={(e) => setNewName(e.target.value.toLowerCase())}
+            onKeyDown={(e) => e.stopPropagation()}
+          />
+          <Button type="submit" size="icon" variant="ghost">
+            <Plus />
+          </Button>
+        </form>
+      </DropdownMenuContent>
+    </DropdownMenu>
+  );
+}
@@ -1,11 +1,13 @@
 import {
   Cloud,
   File,
+  FileSearch,
   HardDrive,
   Info,
   PanelRightClose,
   PanelRightOpen,
   Search,
+  ShieldAlert,
 } from "lucide-react";
 import { getColumns } from "@/features/file/libs/table";
 import {
@@ -17,6 +19,7 @@ import {
 import { DataTable } from "@/features/file/components/data-table";
 import { Button } from "@/components/ui/button";
 import { useMemo, useState } from "react";
+import { useQuery } from "@tanstack/react-query";
 import { Input } from "@/components/ui/input";
 import { ViewSwitch } from "@/components/view-switch";
 import { FileCard } from "../file-card";
@@ -25,6 +28,12 @@ import { useFilesContext } from "@/lib/files-context";
 import { ScrollArea } from "@/components/ui/scroll-area";
 import { model } from "../../../../../wailsjs/go/models";
 import { GroupButtons } from "../group-buttons";
+import { SortMenu } from "../sort-menu";
+import {
+  GetConfig,
+  SearchContent,
+  SearchFiles,
+} from "../../../../../wailsjs/go/main/App";
 
 interface Props {
   collapsed: boolean;
@@ -34,36 +43,52 @@ interface Props {
 export function Files({ collapsed, onCollapse }: Props) {
   const [view, setView] = useState<"grid" | "list">("list");
   const [filter, setFilter] = useState<string>("");
+  const [damagedOnly, setDamagedOnly] = useState(false);
+  const [contentSearch, setContentSearch] = useState(false);
+
+  const { data: settings } = useQuery({
+    queryKey: ["settings"],
+    queryFn: GetConfig,
+  });
+  const canSearchContent = Boolean(settings?.content_index);
+
+  const {
+    files,
+    summary,
+    hasMore,
+    loadMore,
+    damaged,
+    selectedFile,
+    selectedRows,
+    setSelectedRows,
+  } = useFilesContext();
 
-  const { files, selectedFile, selectedRows, setSelectedRows } =
-    useFilesContext();
+  const damagedCount = Object.keys(damaged).length;
 
   const hasSelectedRows = Object.keys(selectedRows).length > 0;
 
-  const [cloudSize, localSize] = useMemo(
-    () =>
-      files.reduce(
-        (acc, file) => {
-          if (file.state === model.FileState.cloud) {
-            acc[0] += file.size / 1024 / 1024;
-          } else {
-            acc[1] += file.size / 1024 / 1024;
-          }
-          return acc;
-        },
-        [0, 0]
-      ),
-    [files]
-  );
+  const cloudSize = (summary.bytes[model.FileState.cloud] || 0) / 1024 / 1024;
+  const localSize = (summary.bytes[model.FileState.local] || 0) / 1024 / 1024;
 
-  const totalFiles = files.length;
+  const totalFiles = summary.total;
+
+  const query = filter.trim();
+  const { data: searchResults } = useQuery({
+    queryKey: ["files", "search", query, contentSearch],
+    queryFn: () =>
+      contentSearch && canSearchContent
+        ? SearchContent(query)
+        : SearchFiles(query),
+    enabled: query !== "",
+    retry: false,
+  });
 
   const filteredFiles = useMemo(
     () =>
-      files.filter((file) =>
-        file.name.toLowerCase().includes(filter.toLowerCase())
+      (query === "" ? files : searchResults || []).filter(
+        (file) => !damagedOnly || damaged[file.name]
       ),
-    [files, filter]
+    [files, query, searchResults, damagedOnly, damaged]
   );
 
   const columns = useMemo(() => getColumns(hasSelectedRows), [hasSelectedRows]);
@@ -76,12 +101,35 @@ export function Files({ collapsed, onCollapse }: Props) {
           <div className="relative flex items-center max-w-2xl ">
             <Search className="absolute left-2 top-1/2 h-4 w-4 -translate-y-1/2 transform" />
             <Input
-              placeholder="Search files..."
+              placeholder={
+                contentSearch
+                  ? "Search file contents..."
+                  : "Search, e.g. tag:work size:>1MB"
+              }
               className=" pl-8"
               value={filter}
               onChange={(e) => setFilter(e.target.value)}
             />
           </div>
+          {canSearchContent && (
+            <Button
+              variant={contentSearch ? "default" : "secondary"}
+              size="icon"
+              title="Search file contents"
+              onClick={() => setContentSearch(!contentSearch)}
+            >
+              <FileSearch className={contentSearch ? "!text-white" : ""} />
+            </Button>
+          )}
+          {(damagedCount > 0 || damagedOnly) && (
+            <Button
+              variant={damagedOnly ? "destructive" : "secondary"}
+              onClick={() => setDamagedOnly(!damagedOnly)}
+            >
+              <ShieldAlert />
+              {damagedCount} damaged
+            </Button>
+          )}
         </div>
         <div className="flex flex-row items-center space-x-2">
           {Object.keys(selectedRows).length > 0 ? (
@@ -91,6 +139,7 @@ export function Files({ collapsed, onCollapse }: Props) {
             />
           ) : (
             <>
+              <SortMenu />
               <ViewSwitch value={view} onChange={setView} />
               <Button variant="secondary" size="icon" onClick={onCollapse}>
                 {collapsed ? <PanelRightOpen /> : <PanelRightClose />}
@@ -115,6 +164,13 @@ export function Files({ collapsed, onCollapse }: Props) {
               ))}
             </div>
           )}
+          {hasMore && query === "" && (
+            <div className="flex justify-center pb-12">
+              <Button variant="secondary" onClick={loadMore}>
+                Load more
+              </Button>
+            </div>
+          )}
         </ScrollArea>
       </CardContent>
       <CardFooter
@@ -13,6 +13,8 @@ import { Textarea } from "@/components/ui/textarea";
 import { Button } from "@/components/ui/button";
 import { model } from "../../../../../wailsjs/go/models";
 import { DescriptionEditor } from "../description-editor";
+import { TagEditor } from "../tag-editor";
+import { FilePreview } from "../file-preview";
 import {
   useIsMutating,
   useMutation,
@@ -118,6 +120,12 @@ export function DetailsCard({ file }: Props) {
         <CardContent className="p-4 relative w-full h-full">
           {file ? (
             <div className="space-y-4">
+              {file.preview && (
+                <>
+                  <FilePreview file={file} />
+                  <Separator />
+                </>
+              )}
               <div className="grid grid-cols-2 gap-4">
                 <div className="space-y-1">
                   <div className="flex items-center gap-2 text-sm text-slate-500">
@@ -181,6 +189,10 @@ export function DetailsCard({ file }: Props) {
                   defaultValue={file.description}
                 />
               </div>
+              <div className="space-y-2">
+                <h3 className="text-sm font-medium">Tags</h3>
+                <TagEditor fileName={file.name} tags={file.tags || []} />
+              </div>
             </div>
           ) : (
             <div className="absolute top-1/2 left-1/2 -translate-x-1/2 -translate-y-1/2 text-center">
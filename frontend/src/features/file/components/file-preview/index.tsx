@@ -0,0 +1,33 @@
+import { useQuery } from "@tanstack/react-query";
+import { Loader } from "lucide-react";
+import { GetPreview } from "../../../../../wailsjs/go/main/App";
+import { model } from "../../../../../wailsjs/go/models";
+
+interface Props {
+  file: model.FileRecord;
+}
+
+export function FilePreview({ file }: Props) {
+  const { data, isLoading, isError } = useQuery({
+    queryKey: ["preview", file.name, file.preview?.file_id],
+    queryFn: () => GetPreview(file.name),
+    enabled: Boolean(file.preview),
+    staleTime: Infinity,
+    retry: false,
+  });
+
+  if (!file.preview || isError) return null;
+  if (isLoading || !data) return <Loader className="h-4 w-4 animate-spin" />;
+
+  return file.preview.kind === model.PreviewKind.image ? (
+    <img
+      src={data}
+      alt={file.name}
+      className="max-h-64 w-auto rounded-md border object-contain"
+    />
+  ) : (
+    <pre className="max-h-64 overflow-auto whitespace-pre-wrap rounded-md border bg-muted p-2 text-xs">
+      {data}
+    </pre>
+  );
+}
@@ -20,10 +20,10 @@ export function RemoveAlert({ showAlert, setShowAlert, handleDelete }: Props) {
     <AlertDialog open={showAlert} onOpenChange={setShowAlert}>
       <AlertDialogContent>
         <AlertDialogHeader>
-          <AlertDialogTitle>Are you absolutely sure?</AlertDialogTitle>
+          <AlertDialogTitle>Move to trash?</AlertDialogTitle>
           <AlertDialogDescription>
-            This action cannot be undone. This will permanently delete your file
-            locally and from the server.
+            This will delete your local copy and move the file to the trash. It
+            can be restored until the retention period runs out.
           </AlertDialogDescription>
         </AlertDialogHeader>
         <AlertDialogFooter>
@@ -0,0 +1,55 @@
+import { Button } from "@/components/ui/button";
+import {
+  DropdownMenu,
+  DropdownMenuCheckboxItem,
+  DropdownMenuContent,
+  DropdownMenuRadioGroup,
+  DropdownMenuRadioItem,
+  DropdownMenuSeparator,
+  DropdownMenuTrigger,
+} from "@/components/ui/dropdown-menu";
+import { useFilesContext } from "@/lib/files-context";
+import { ArrowDownWideNarrow, ArrowUpNarrowWide } from "lucide-react";
+import { metadata } from "../../../../../wailsjs/go/models";
+
+const labels: Record<metadata.SortField, string> = {
+  [metadata.SortField.name]: "Name",
+  [metadata.SortField.size]: "Size",
+  [metadata.SortField.uploaded_at]: "Uploaded",
+  [metadata.SortField.state]: "State",
+};
+
+export function SortMenu() {
+  const { sort, setSort } = useFilesContext();
+
+  return (
+    <DropdownMenu>
+      <DropdownMenuTrigger asChild>
+        <Button variant="secondary" size="icon" title="Sort files">
+          {sort.desc ? <ArrowDownWideNarrow /> : <ArrowUpNarrowWide />}
+        </Button>
+      </DropdownMenuTrigger>
+      <DropdownMenuContent align="end">
+        <DropdownMenuRadioGroup
+          value={sort.field}
+          onValueChange={(field) =>
+            setSort({ ...sort, field: field as metadata.SortField })
+          }
+        >
+          {Object.entries(labels).map(([field, label]) => (
+            <DropdownMenuRadioItem key={field} value={field}>
+              {label}
+            </DropdownMenuRadioItem>
+          ))}
+        </DropdownMenuRadioGroup>
+        <DropdownMenuSeparator />
+        <DropdownMenuCheckboxItem
+          checked={sort.desc}
+          onCheckedChange={(desc) => setSort({ ...sort, desc })}
+        >
+          Descending
+        </DropdownMenuCheckboxItem>
+      </DropdownMenuContent>
+    </DropdownMenu>
+  );
+}
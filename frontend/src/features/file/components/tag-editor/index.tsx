@@ -0,0 +1,56 @@
+import { Badge } from "@/components/ui/badge";
+import { Input } from "@/components/ui/input";
+import { useMutation, useQueryClient } from "@tanstack/react-query";
+import { X } from "lucide-react";
+import { useState } from "react";
+import { SetTags } from "../../../../../wailsjs/go/main/App";
+
+interface Props {
+  fileName: string;
+  tags: string[];
+}
+
+export function TagEditor({ fileName, tags }: Props) {
+  const [input, setInput] = useState("");
+  const queryClient = useQueryClient();
+
+  const mutation = useMutation({
+    mutationFn: (tags: string[]) => SetTags(fileName, tags),
+    onError(err) {
+      console.error("Failed to update tags:", err);
+    },
+    onSuccess() {
+      queryClient.invalidateQueries({ queryKey: ["files"] });
+    },
+  });
+
+  const handleKeyDown = (e: React.KeyboardEvent<HTMLInputElement>) => {
+    if (e.key !== "Enter" || input.trim() === "") return;
+    mutation.mutate([...tags, input]);
+    setInput("");
+  };
+
+  return (
+    <div className="space-y-2">
+      {tags.length > 0 && (
+        <div className="flex flex-wrap gap-1">
+          {tags.map((tag) => (
+            <Badge key={tag} variant="secondary">
+              {tag}
+              <X
+                className="cursor-pointer !pointer-events-auto"
+                onClick={() => mutation.mutate(tags.filter((t) => t !== tag))}
+              />
+            </Badge>
+          ))}
+        </div>
+      )}
+      <Input
+        placeholder="Add a tag..."
+        value={input}
+        onChange={(e) => setInput(e.target.value)}
+        onKeyDown={handleKeyDown}
+      />
+    </div>
+  );
+}
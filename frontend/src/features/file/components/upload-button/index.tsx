@@ -1,7 +1,12 @@
 import { Button } from "@/components/ui/button";
 import { useMutation, useQueryClient } from "@tanstack/react-query";
-import { Plus } from "lucide-react";
-import { SelectFile, UploadFile } from "@/../wailsjs/go/main/App";
+import { FolderUp, Plus } from "lucide-react";
+import {
+  SelectDirectory,
+  SelectFile,
+  UploadDirectory,
+  UploadFile,
+} from "@/../wailsjs/go/main/App";
 import { useEffect, useState } from "react";
 import { EventsOn } from "@/../wailsjs/runtime/runtime";
 
@@ -9,6 +14,12 @@ export function UploadButton() {
   const queryClient = useQueryClient();
   const [uploadPercentage, setUploadPercentage] = useState(0);
 
+  const [dirProgress, setDirProgress] = useState({
+    done: 0,
+    files: 0,
+    percent: 0,
+  });
+
   const [syncJobName, setSyncJobName] = useState<string | null>(null);
   const [syncPercentage, setSyncPercentage] = useState(0);
 
@@ -22,6 +33,15 @@ export function UploadButton() {
     };
   }, []);
 
+  useEffect(() => {
+    const unsub = EventsOn("dirProgress", (progress) => {
+      setDirProgress(progress);
+    });
+    return () => {
+      unsub();
+    };
+  }, []);
+
   useEffect(() => {
     const unsubStart = EventsOn("syncStart", (name) => {
       setSyncJobName(name);
@@ -65,23 +85,58 @@ export function UploadButton() {
     mutate(path);
   };
 
-  const busy = isPending || syncJobName !== null;
+  const { mutate: uploadDirectory, isPending: isDirPending } = useMutation({
+    mutationFn: UploadDirectory,
+    onSettled: () => {
+      queryClient.invalidateQueries({ queryKey: ["files"] });
+    },
+    onSuccess: (result) => {
+      for (const [name, err] of Object.entries(result.failed || {})) {
+        console.error(`Upload error for ${name}:`, err);
+      }
+    },
+  });
+
+  const handleDirectoryUpload = async () => {
+    setDirProgress({ done: 0, files: 0, percent: 0 });
+    const path = await SelectDirectory();
+    if (!path) return;
+    uploadDirectory(path);
+  };
+
+  const busy = isPending || isDirPending || syncJobName !== null;
 
   return (
-    <Button
-      size={busy ? "default" : "icon"}
-      onClick={handleFileUpload}
-      disabled={busy}
-    >
-      {isPending ? (
-        <div className="text-white">{uploadPercentage.toFixed(1)}%</div>
-      ) : syncJobName ? (
-        <div className="text-white">
-          {syncJobName}: {syncPercentage.toFixed(1)}%
-        </div>
-      ) : (
-        <Plus className="!text-white" />
+    <>
+      <Button
+        size={busy ? "default" : "icon"}
+        onClick={handleFileUpload}
+        disabled={busy}
+      >
+        {isPending ? (
+          <div className="text-white">{uploadPercentage.toFixed(1)}%</div>
+        ) : isDirPending ? (
+          <div className="text-white">
+            {dirProgress.done}/{dirProgress.files} files:{" "}
+            {dirProgress.percent.toFixed(1)}%
+          </div>
+        ) : syncJobName ? (
+          <div className="text-white">
+            {syncJobName}: {syncPercentage.toFixed(1)}%
+          </div>
+        ) : (
+          <Plus className="!text-white" />
+        )}
+      </Button>
+      {!busy && (
+        <Button
+          variant="secondary"
+          size="icon"
+          onClick={handleDirectoryUpload}
+        >
+          <FolderUp />
+        </Button>
       )}
-    </Button>
+    </>
   );
 }
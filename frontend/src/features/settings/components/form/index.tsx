@@ -12,15 +12,18 @@ import {
   FormMessage,
 } from "@/components/ui/form";
 import { Button } from "@/components/ui/button";
+import { Checkbox } from "@/components/ui/checkbox";
 import { Input } from "@/components/ui/input";
 import { Separator } from "@/components/ui/separator";
-import { FolderOpen, Loader } from "lucide-react";
+import { FolderOpen, Loader, PlugZap } from "lucide-react";
 import { MouseEventHandler, useEffect, useState } from "react";
 import {
   GetConfig,
   SelectDirectory,
+  TestConnection,
   UpdateConfig,
 } from "../../../../../wailsjs/go/main/App";
+import { config } from "../../../../../wailsjs/go/models";
 import { toast } from "sonner";
 import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
 
@@ -37,7 +40,13 @@ export function SettingsForm({ onSuccess }: Props) {
   });
 
   const { mutateAsync: update } = useMutation({
-    mutationFn: UpdateConfig,
+    mutationFn: ({
+      cfg,
+      moveFiles,
+    }: {
+      cfg: config.Config;
+      moveFiles: boolean;
+    }) => UpdateConfig(cfg, moveFiles),
   });
 
   const form = useForm<z.infer<typeof formSchema>>({
@@ -52,20 +61,50 @@ export function SettingsForm({ onSuccess }: Props) {
     form.setValue("syncDirLocation", data.sync_folder);
   }, [data, form]);
 
+  const syncDir = form.watch("syncDirLocation");
+  const folderChanged = !!data?.sync_folder && syncDir !== data.sync_folder;
+
   const onSubmit = (values: z.infer<typeof formSchema>) => {
     update({
-      bot_token: values.botToken,
-      chat_id: values.chatId,
-      sync_folder: values.syncDirLocation,
+      cfg: config.Config.createFrom({
+        ...data,
+        bot_token: values.botToken,
+        chat_id: values.chatId,
+        sync_folder: values.syncDirLocation,
+      }),
+      moveFiles: !!values.moveFiles,
     })
-      .then(() => {
-        toast.success("Settings were saved.", { closeButton: true });
+      .then((warnings) => {
+        if (warnings?.length) {
+          toast.warning(`Settings were saved, but:\n${warnings.join("\n")}`, {
+            closeButton: true,
+          });
+        } else {
+          toast.success("Settings were saved.", { closeButton: true });
+        }
         queryClient.invalidateQueries({ queryKey: ["settings"] });
         onSuccess();
       })
       .catch((e) => toast.error(e, { closeButton: true }));
   };
 
+  const { mutate: testConnection, isPending: isTesting } = useMutation({
+    mutationFn: () =>
+      TestConnection(form.getValues("botToken"), form.getValues("chatId")),
+    onSuccess: (report) => {
+      const chat = report.chat_title || report.chat_type;
+      if (report.missing.length === 0) {
+        toast.success(
+          `@${report.bot_name} can post, pin and delete in ${chat}.`,
+          { closeButton: true },
+        );
+        return;
+      }
+      toast.error(report.problems.join("\n"), { closeButton: true });
+    },
+    onError: (e) => toast.error(String(e), { closeButton: true }),
+  });
+
   const handleBrowseFolder: MouseEventHandler = () => {
     SelectDirectory().then((dir) => {
       form.setValue("syncDirLocation", dir, { shouldValidate: true });
@@ -114,6 +153,15 @@ export function SettingsForm({ onSuccess }: Props) {
               <FormDescription>
                 The chat ID is used for accessing storage.
               </FormDescription>
+              <Button
+                type="button"
+                variant="outline"
+                disabled={isTesting}
+                onClick={() => testConnection()}
+              >
+                {isTesting ? <Loader className="animate-spin" /> : <PlugZap />}
+                Test connection
+              </Button>
               <FormMessage />
             </FormItem>
           )}
@@ -141,6 +189,28 @@ export function SettingsForm({ onSuccess }: Props) {
             </FormItem>
           )}
         />
+        {folderChanged && (
+          <FormField
+            control={form.control}
+            name="moveFiles"
+            render={({ field }) => (
+              <FormItem className="flex items-center gap-2">
+                <FormControl>
+                  <Checkbox
+                    checked={!!field.value}
+                    onCheckedChange={(checked) =>
+                      field.onChange(checked === true)
+                    }
+                  />
+                </FormControl>
+                <FormLabel>
+                  Move existing files from {data?.sync_folder} to the new
+                  folder
+                </FormLabel>
+              </FormItem>
+            )}
+          />
+        )}
       </form>
     </Form>
   );
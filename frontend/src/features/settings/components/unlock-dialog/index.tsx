@@ -0,0 +1,104 @@
+import {
+  AlertDialog,
+  AlertDialogContent,
+  AlertDialogDescription,
+  AlertDialogFooter,
+  AlertDialogHeader,
+  AlertDialogTitle,
+} from "@/components/ui/alert-dialog";
+import { Button } from "@/components/ui/button";
+import { Input } from "@/components/ui/input";
+import { Loader, LockKeyholeOpen } from "lucide-react";
+import { FormEvent, useEffect, useState } from "react";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
+import {
+  GetSecretsStatus,
+  UnlockSecrets,
+} from "../../../../../wailsjs/go/main/App";
+import { EventsOn } from "../../../../../wailsjs/runtime/runtime";
+
+export function UnlockDialog() {
+  const queryClient = useQueryClient();
+  const [password, setPassword] = useState("");
+  const [confirm, setConfirm] = useState("");
+  const [error, setError] = useState("");
+
+  const { data: status } = useQuery({
+    queryKey: ["secrets"],
+    queryFn: GetSecretsStatus,
+  });
+
+  useEffect(() => {
+    const unsub = EventsOn("secretsLocked", () => {
+      queryClient.invalidateQueries({ queryKey: ["secrets"] });
+    });
+    return () => unsub();
+  }, [queryClient]);
+
+  const { mutate: unlock, isPending } = useMutation({
+    mutationFn: UnlockSecrets,
+    onSuccess: () => {
+      setPassword("");
+      setConfirm("");
+      setError("");
+      queryClient.invalidateQueries();
+    },
+    onError: (e) => setError(String(e)),
+  });
+
+  const creating = status ? !status.exists : false;
+
+  const handleSubmit = (e: FormEvent) => {
+    e.preventDefault();
+    if (creating && password !== confirm) {
+      setError("Passwords do not match.");
+      return;
+    }
+    unlock(password);
+  };
+
+  return (
+    <AlertDialog open={!!status?.locked}>
+      <AlertDialogContent>
+        <form onSubmit={handleSubmit} className="space-y-4">
+          <AlertDialogHeader>
+            <AlertDialogTitle>
+              {creating ? "Create a master password" : "Unlock tstore"}
+            </AlertDialogTitle>
+            <AlertDialogDescription>
+              {creating
+                ? "Your bot token and other credentials are kept in an encrypted vault. Choose a password to protect it."
+                : "Enter your master password to decrypt your credentials."}
+            </AlertDialogDescription>
+          </AlertDialogHeader>
+          <Input
+            type="password"
+            placeholder="Master password"
+            autoFocus
+            value={password}
+            onChange={(e) => setPassword(e.target.value)}
+          />
+          {creating && (
+            <Input
+              type="password"
+              placeholder="Confirm password"
+              value={confirm}
+              onChange={(e) => setConfirm(e.target.value)}
+            />
+          )}
+          {error && <p className="text-sm text-destructive">{error}</p>}
+          <AlertDialogFooter>
+            <Button type="submit" disabled={isPending || password === ""}>
+              {isPending ? (
+                <Loader className="animate-spin" />
+              ) : (
+                <LockKeyholeOpen />
+              )}
+              {creating ? "Create vault" : "Unlock"}
+            </Button>
+          </AlertDialogFooter>
+        </form>
+      </AlertDialogContent>
+    </AlertDialog>
+  );
+}
@@ -6,4 +6,5 @@ export const formSchema = z.object({
   syncDirLocation: z
     .string()
     .nonempty("Please, provide sync directory location"),
+  moveFiles: z.boolean().optional(),
 });
@@ -0,0 +1,111 @@
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { ScrollArea } from "@/components/ui/scroll-area";
+import {
+  Table,
+  TableBody,
+  TableCell,
+  TableHead,
+  TableHeader,
+  TableRow,
+} from "@/components/ui/table";
+import { useMutation, useQuery, useQueryClient } from "@tanstack/react-query";
+import { ArchiveRestore, Loader } from "lucide-react";
+import { GetTrash, RestoreFromTrash } from "../../../../../wailsjs/go/main/App";
+
+export function Trash() {
+  const queryClient = useQueryClient();
+
+  const { data: trash = [] } = useQuery({
+    queryKey: ["trash"],
+    queryFn: GetTrash,
+  });
+
+  const {
+    mutate: restore,
+    isPending,
+    variables: restoring,
+  } = useMutation({
+    mutationKey: ["restore"],
+    mutationFn: RestoreFromTrash,
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ["trash"] });
+      queryClient.invalidateQueries({ queryKey: ["files"] });
+    },
+  });
+
+  return (
+    <Card className="flex flex-1 overflow-hidden">
+      <CardHeader>
+        <CardTitle>Trash</CardTitle>
+        <CardDescription>
+          Deleted files are kept until the retention period runs out
+        </CardDescription>
+      </CardHeader>
+      <CardContent className="flex-1 overflow-hidden">
+        <ScrollArea className="h-full w-full">
+          <Table>
+            <TableHeader>
+              <TableRow>
+                <TableHead>Name</TableHead>
+                <TableHead>Size</TableHead>
+                <TableHead>Deleted At</TableHead>
+                <TableHead />
+              </TableRow>
+            </TableHeader>
+            <TableBody>
+              {trash.length === 0 && (
+                <TableRow>
+                  <TableCell
+                    colSpan={4}
+                    className="text-center text-muted-foreground"
+                  >
+                    Trash is empty
+                  </TableCell>
+                </TableRow>
+              )}
+              {trash.map((file) => (
+                <TableRow key={`${file.name}@${file.trashed_at}`}>
+                  <TableCell>{file.name}</TableCell>
+                  <TableCell className="text-muted-foreground">
+                    {(file.size / 1024 / 1024).toFixed(2)} MB
+                  </TableCell>
+                  <TableCell className="text-muted-foreground">
+                    {new Date(file.trashed_at).toLocaleString("en-US", {
+                      year: "numeric",
+                      month: "2-digit",
+                      day: "2-digit",
+                      hour: "2-digit",
+                      minute: "2-digit",
+                    })}
+                  </TableCell>
+                  <TableCell className="text-right">
+                    <Button
+                      variant="secondary"
+                      size="sm"
+                      disabled={isPending}
+                      onClick={() => restore(file.name)}
+                    >
+                      {isPending && restoring === file.name ? (
+                        <Loader className="animate-spin" />
+                      ) : (
+                        <ArchiveRestore />
+                      )}
+                      Restore
+                    </Button>
+                  </TableCell>
+                </TableRow>
+              ))}
+            </TableBody>
+          </Table>
+        </ScrollArea>
+      </CardContent>
+    </Card>
+  );
+}
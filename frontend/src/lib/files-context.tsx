@@ -1,5 +1,9 @@
 import { SelectedRows } from "@/features/file/types";
-import { useQuery, useQueryClient } from "@tanstack/react-query";
+import {
+  useInfiniteQuery,
+  useQuery,
+  useQueryClient,
+} from "@tanstack/react-query";
 import {
   createContext,
   Dispatch,
@@ -7,14 +11,28 @@ import {
   SetStateAction,
   useContext,
   useEffect,
+  useMemo,
   useState,
 } from "react";
-import { GetFilesMetadata } from "../../wailsjs/go/main/App";
-import { model } from "../../wailsjs/go/models";
+import { GetScrubReport, ListFiles } from "../../wailsjs/go/main/App";
+import { metadata, model, telegram } from "../../wailsjs/go/models";
 import { EventsOn } from "../../wailsjs/runtime/runtime";
 
+const PAGE_SIZE = 500;
+
+export interface FilesSort {
+  field: metadata.SortField;
+  desc: boolean;
+}
+
 interface FilesContextState {
   files: model.FileRecord[];
+  summary: Pick<metadata.ListPage, "total" | "states" | "bytes">;
+  hasMore: boolean;
+  loadMore: () => void;
+  sort: FilesSort;
+  setSort: Dispatch<SetStateAction<FilesSort>>;
+  damaged: Record<string, telegram.FileHealth>;
 
   selectedFile: string | undefined;
   selectFile: Dispatch<SetStateAction<string | undefined>>;
@@ -23,8 +41,16 @@ interface FilesContextState {
   setSelectedRows: Dispatch<SetStateAction<SelectedRows>>;
 }
 
+const emptySummary = { total: 0, states: {}, bytes: {} };
+
 const FilesContext = createContext<FilesContextState>({
   files: [],
+  summary: emptySummary,
+  hasMore: false,
+  loadMore: () => {},
+  sort: { field: metadata.SortField.name, desc: false },
+  setSort: () => {},
+  damaged: {},
   selectedFile: undefined,
   selectFile: () => {},
   selectedRows: {},
@@ -35,14 +61,49 @@ export const useFilesContext = () => useContext(FilesContext);
 export function FilesProvider({ children }: PropsWithChildren) {
   const [selectedFile, setSelectedFile] = useState<string | undefined>();
   const [selectedRows, setSelectedRows] = useState<SelectedRows>({});
+  const [sort, setSort] = useState<FilesSort>({
+    field: metadata.SortField.name,
+    desc: false,
+  });
 
   const queryClient = useQueryClient();
 
-  const { data } = useQuery({
-    queryKey: ["files"],
-    queryFn: GetFilesMetadata,
+  const { data, hasNextPage, fetchNextPage, isFetchingNextPage } =
+    useInfiniteQuery({
+      queryKey: ["files", "list", sort],
+      queryFn: ({ pageParam }) =>
+        ListFiles(
+          metadata.ListOptions.createFrom({
+            sort: sort.field,
+            desc: sort.desc,
+            cursor: pageParam,
+            limit: PAGE_SIZE,
+          })
+        ),
+      initialPageParam: "",
+      getNextPageParam: (last) => last.next_cursor || undefined,
+    });
+
+  const files = useMemo(
+    () => data?.pages.flatMap((page) => page.files || []) || [],
+    [data]
+  );
+
+  const { data: scrubReport } = useQuery({
+    queryKey: ["scrubReport"],
+    queryFn: GetScrubReport,
   });
 
+  const damaged = useMemo(() => {
+    const byName: Record<string, telegram.FileHealth> = {};
+    for (const file of scrubReport?.files || []) {
+      if (file.status !== "ok") {
+        byName[file.name] = file;
+      }
+    }
+    return byName;
+  }, [scrubReport]);
+
   useEffect(() => {
     const unsub = EventsOn("fileRenamed", () =>
       queryClient.invalidateQueries({ queryKey: ["files"] })
@@ -50,10 +111,14 @@ export function FilesProvider({ children }: PropsWithChildren) {
     const unsub2 = EventsOn("fileRemoved", () =>
       queryClient.invalidateQueries({ queryKey: ["files"] })
     );
+    const unsub3 = EventsOn("scrubFinished", () =>
+      queryClient.invalidateQueries({ queryKey: ["scrubReport"] })
+    );
 
     return () => {
       unsub();
       unsub2();
+      unsub3();
       queryClient.invalidateQueries({ queryKey: ["files"] });
     };
   }, []);
@@ -61,7 +126,15 @@ export function FilesProvider({ children }: PropsWithChildren) {
   return (
     <FilesContext.Provider
       value={{
-        files: data || [],
+        files,
+        summary: data?.pages[0] || emptySummary,
+        hasMore: Boolean(hasNextPage),
+        loadMore: () => {
+          if (!isFetchingNextPage) fetchNextPage();
+        },
+        sort,
+        setSort,
+        damaged,
         selectedFile,
         selectFile: setSelectedFile,
         selectedRows,
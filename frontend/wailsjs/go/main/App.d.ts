@@ -1,30 +1,91 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {config} from '../models';
+import {metadata} from '../models';
 import {model} from '../models';
+import {secrets} from '../models';
+import {telegram} from '../models';
 
 export function Close():Promise<void>;
 
+export function CollectGarbage(arg1:boolean):Promise<telegram.GCReport>;
+
+export function DeleteDirectory(arg1:string):Promise<telegram.DirResult>;
+
 export function DeleteFile(arg1:string):Promise<void>;
 
+export function DownloadDirectory(arg1:string):Promise<telegram.DirResult>;
+
 export function DownloadFile(arg1:string):Promise<void>;
 
+export function ExportShare(arg1:string,arg2:string):Promise<string>;
+
+export function ExtractRange(arg1:string,arg2:number,arg3:number,arg4:string):Promise<void>;
+
 export function GetConfig():Promise<config.Config>;
 
 export function GetFilesMetadata():Promise<Array<model.FileRecord>>;
 
+export function GetPreview(arg1:string):Promise<string>;
+
+export function GetProfile():Promise<string>;
+
+export function GetScrubReport():Promise<telegram.ScrubReport>;
+
+export function GetSecretsStatus():Promise<secrets.Status>;
+
+export function GetStorageStats():Promise<telegram.StorageStats>;
+
+export function GetStreamURL(arg1:string):Promise<string>;
+
+export function GetTransferHistory(arg1:number):Promise<Array<telegram.DayTransfer>>;
+
+export function GetTrash():Promise<Array<model.FileRecord>>;
+
+export function ImportShare(arg1:string):Promise<string>;
+
+export function ListFiles(arg1:metadata.ListOptions):Promise<metadata.ListPage>;
+
+export function ListProfiles():Promise<Array<string>>;
+
 export function Minimize():Promise<void>;
 
+export function OffloadDirectory(arg1:string):Promise<telegram.DirResult>;
+
 export function OffloadFile(arg1:string):Promise<void>;
 
+export function OpenProfile(arg1:string):Promise<void>;
+
+export function RepairReplicas():Promise<telegram.RepairReport>;
+
+export function RestoreFromTrash(arg1:string):Promise<void>;
+
+export function ScrubFiles():Promise<telegram.ScrubReport>;
+
+export function SearchContent(arg1:string):Promise<Array<model.FileRecord>>;
+
+export function SearchFiles(arg1:string):Promise<Array<model.FileRecord>>;
+
 export function SelectDirectory():Promise<string>;
 
 export function SelectFile():Promise<string>;
 
+export function SetAttributes(arg1:string,arg2:Record<string, string>):Promise<void>;
+
+export function SetTags(arg1:string,arg2:Array<string>):Promise<void>;
+
+export function SwitchProfile(arg1:string):Promise<void>;
+
+export function TestConnection(arg1:string,arg2:string):Promise<telegram.AccessReport>;
+
 export function ToggleFullscreen():Promise<void>;
 
-export function UpdateConfig(arg1:config.Config):Promise<void>;
+export function UnlockSecrets(arg1:string):Promise<void>;
+
+export function UpdateConfig(arg1:config.Config,arg2:boolean):Promise<Array<string>>;
 
 export function UpdateDescription(arg1:string,arg2:string):Promise<void>;
 
+export function UploadDirectory(arg1:string):Promise<telegram.DirResult>;
+
 export function UploadFile(arg1:string):Promise<string>;
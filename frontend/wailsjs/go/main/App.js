@@ -6,14 +6,34 @@ export function Close() {
   return window['go']['main']['App']['Close']();
 }
 
+export function CollectGarbage(arg1) {
+  return window['go']['main']['App']['CollectGarbage'](arg1);
+}
+
+export function DeleteDirectory(arg1) {
+  return window['go']['main']['App']['DeleteDirectory'](arg1);
+}
+
 export function DeleteFile(arg1) {
   return window['go']['main']['App']['DeleteFile'](arg1);
 }
 
+export function DownloadDirectory(arg1) {
+  return window['go']['main']['App']['DownloadDirectory'](arg1);
+}
+
 export function DownloadFile(arg1) {
   return window['go']['main']['App']['DownloadFile'](arg1);
 }
 
+export function ExportShare(arg1,arg2) {
+  return window['go']['main']['App']['ExportShare'](arg1,arg2);
+}
+
+export function ExtractRange(arg1,arg2,arg3,arg4) {
+  return window['go']['main']['App']['ExtractRange'](arg1,arg2,arg3,arg4);
+}
+
 export function GetConfig() {
   return window['go']['main']['App']['GetConfig']();
 }
@@ -22,14 +42,86 @@ export function GetFilesMetadata() {
   return window['go']['main']['App']['GetFilesMetadata']();
 }
 
+export function GetPreview(arg1) {
+  return window['go']['main']['App']['GetPreview'](arg1);
+}
+
+export function GetProfile() {
+  return window['go']['main']['App']['GetProfile']();
+}
+
+export function GetScrubReport() {
+  return window['go']['main']['App']['GetScrubReport']();
+}
+
+export function GetSecretsStatus() {
+  return window['go']['main']['App']['GetSecretsStatus']();
+}
+
+export function GetStorageStats() {
+  return window['go']['main']['App']['GetStorageStats']();
+}
+
+export function GetStreamURL(arg1) {
+  return window['go']['main']['App']['GetStreamURL'](arg1);
+}
+
+export function GetTransferHistory(arg1) {
+  return window['go']['main']['App']['GetTransferHistory'](arg1);
+}
+
+export function GetTrash() {
+  return window['go']['main']['App']['GetTrash']();
+}
+
+export function ImportShare(arg1) {
+  return window['go']['main']['App']['ImportShare'](arg1);
+}
+
+export function ListFiles(arg1) {
+  return window['go']['main']['App']['ListFiles'](arg1);
+}
+
+export function ListProfiles() {
+  return window['go']['main']['App']['ListProfiles']();
+}
+
 export function Minimize() {
   return window['go']['main']['App']['Minimize']();
 }
 
+export function OffloadDirectory(arg1) {
+  return window['go']['main']['App']['OffloadDirectory'](arg1);
+}
+
 export function OffloadFile(arg1) {
   return window['go']['main']['App']['OffloadFile'](arg1);
 }
 
+export function OpenProfile(arg1) {
+  return window['go']['main']['App']['OpenProfile'](arg1);
+}
+
+export function RepairReplicas() {
+  return window['go']['main']['App']['RepairReplicas']();
+}
+
+export function RestoreFromTrash(arg1) {
+  return window['go']['main']['App']['RestoreFromTrash'](arg1);
+}
+
+export function ScrubFiles() {
+  return window['go']['main']['App']['ScrubFiles']();
+}
+
+export function SearchContent(arg1) {
+  return window['go']['main']['App']['SearchContent'](arg1);
+}
+
+export function SearchFiles(arg1) {
+  return window['go']['main']['App']['SearchFiles'](arg1);
+}
+
 export function SelectDirectory() {
   return window['go']['main']['App']['SelectDirectory']();
 }
@@ -38,18 +130,42 @@ export function SelectFile() {
   return window['go']['main']['App']['SelectFile']();
 }
 
+export function SetAttributes(arg1,arg2) {
+  return window['go']['main']['App']['SetAttributes'](arg1,arg2);
+}
+
+export function SetTags(arg1,arg2) {
+  return window['go']['main']['App']['SetTags'](arg1,arg2);
+}
+
+export function SwitchProfile(arg1) {
+  return window['go']['main']['App']['SwitchProfile'](arg1);
+}
+
+export function TestConnection(arg1,arg2) {
+  return window['go']['main']['App']['TestConnection'](arg1,arg2);
+}
+
 export function ToggleFullscreen() {
   return window['go']['main']['App']['ToggleFullscreen']();
 }
 
-export function UpdateConfig(arg1) {
-  return window['go']['main']['App']['UpdateConfig'](arg1);
+export function UnlockSecrets(arg1) {
+  return window['go']['main']['App']['UnlockSecrets'](arg1);
+}
+
+export function UpdateConfig(arg1,arg2) {
+  return window['go']['main']['App']['UpdateConfig'](arg1,arg2);
 }
 
 export function UpdateDescription(arg1, arg2) {
   return window['go']['main']['App']['UpdateDescription'](arg1, arg2);
 }
 
+export function UploadDirectory(arg1) {
+  return window['go']['main']['App']['UploadDirectory'](arg1);
+}
+
 export function UploadFile(arg1) {
   return window['go']['main']['App']['UploadFile'](arg1);
 }
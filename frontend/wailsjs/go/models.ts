@@ -4,6 +4,29 @@ export namespace config {
 	    bot_token: string;
 	    chat_id: string;
 	    sync_folder: string;
+	    offload_rules: policy.Rule[];
+	    max_local_bytes: number;
+	    mount_point: string;
+	    gateway_addr: string;
+	    webdav_addr: string;
+	    webdav_user: string;
+	    webdav_password: string;
+	    s3_addr: string;
+	    s3_access_key: string;
+	    s3_secret_key: string;
+	    bot_allowed_users: number[];
+	    targets: Target[];
+	    placement: string;
+	    folder_targets: Record<string, string>;
+	    replication_factor: number;
+	    erasure_data_shards: number;
+	    erasure_parity_shards: number;
+	    scrub_mode: string;
+	    gc_grace_days: number;
+	    trash_retention_days: number;
+	    content_index: boolean;
+	    chunk_size: number;
+	    concurrency: number;
 	
 	    static createFrom(source: any = {}) {
 	        return new Config(source);
@@ -14,26 +37,189 @@ export namespace config {
 	        this.bot_token = source["bot_token"];
 	        this.chat_id = source["chat_id"];
 	        this.sync_folder = source["sync_folder"];
+	        this.offload_rules = this.convertValues(source["offload_rules"], policy.Rule);
+	        this.max_local_bytes = source["max_local_bytes"];
+	        this.mount_point = source["mount_point"];
+	        this.gateway_addr = source["gateway_addr"];
+	        this.webdav_addr = source["webdav_addr"];
+	        this.webdav_user = source["webdav_user"];
+	        this.webdav_password = source["webdav_password"];
+	        this.s3_addr = source["s3_addr"];
+	        this.s3_access_key = source["s3_access_key"];
+	        this.s3_secret_key = source["s3_secret_key"];
+	        this.bot_allowed_users = source["bot_allowed_users"];
+	        this.targets = this.convertValues(source["targets"], Target);
+	        this.placement = source["placement"];
+	        this.folder_targets = source["folder_targets"];
+	        this.replication_factor = source["replication_factor"];
+	        this.erasure_data_shards = source["erasure_data_shards"];
+	        this.erasure_parity_shards = source["erasure_parity_shards"];
+	        this.scrub_mode = source["scrub_mode"];
+	        this.gc_grace_days = source["gc_grace_days"];
+	        this.trash_retention_days = source["trash_retention_days"];
+	        this.content_index = source["content_index"];
+	        this.chunk_size = source["chunk_size"];
+	        this.concurrency = source["concurrency"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Target {
+	    name: string;
+	    bot_token?: string;
+	    chat_id: string;
+	    max_bytes?: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Target(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.bot_token = source["bot_token"];
+	        this.chat_id = source["chat_id"];
+	        this.max_bytes = source["max_bytes"];
 	    }
 	}
 
 }
 
+export namespace metadata {
+	
+	export enum SortField {
+	    name = "name",
+	    size = "size",
+	    uploaded_at = "uploaded_at",
+	    state = "state",
+	}
+	export class ListOptions {
+	    sort?: SortField;
+	    desc?: boolean;
+	    cursor?: string;
+	    limit?: number;
+	    state?: model.FileState;
+	    folder?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ListOptions(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.sort = source["sort"];
+	        this.desc = source["desc"];
+	        this.cursor = source["cursor"];
+	        this.limit = source["limit"];
+	        this.state = source["state"];
+	        this.folder = source["folder"];
+	    }
+	}
+	export class ListPage {
+	    files: model.FileRecord[];
+	    next_cursor?: string;
+	    total: number;
+	    states: Record<string, number>;
+	    bytes: Record<string, number>;
+	
+	    static createFrom(source: any = {}) {
+	        return new ListPage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = this.convertValues(source["files"], model.FileRecord);
+	        this.next_cursor = source["next_cursor"];
+	        this.total = source["total"];
+	        this.states = source["states"];
+	        this.bytes = source["bytes"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
 export namespace model {
 	
 	export enum FileState {
 	    local = "local",
 	    cloud = "cloud",
+	    trash = "trash",
+	}
+	export enum PreviewKind {
+	    image = "image",
+	    text = "text",
+	}
+	export class ChunkLocation {
+	    target?: string;
+	    file_id: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ChunkLocation(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.target = source["target"];
+	        this.file_id = source["file_id"];
+	    }
 	}
 	export class FileRecord {
 	    name: string;
 	    state: FileState;
 	    description: string;
+	    tags?: string[];
+	    attributes?: Record<string, string>;
 	    size: number;
 	    checksum: string;
 	    // Go type: time
 	    uploaded_at: any;
 	    chunk_ids: string[];
+	    chunk_size?: number;
+	    chunk_targets?: string[];
+	    replicas?: ChunkLocation[][];
+	    chunk_checksums?: string[];
+	    data_shards?: number;
+	    parity_shards?: number;
+	    parity_chunks?: ChunkLocation[];
+	    parity_checksums?: string[];
+	    // Go type: time
+	    trashed_at?: any;
+	    preview?: Preview;
+	    etag?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new FileRecord(source);
@@ -44,10 +230,373 @@ export namespace model {
 	        this.name = source["name"];
 	        this.state = source["state"];
 	        this.description = source["description"];
+	        this.tags = source["tags"];
+	        this.attributes = source["attributes"];
 	        this.size = source["size"];
 	        this.checksum = source["checksum"];
 	        this.uploaded_at = this.convertValues(source["uploaded_at"], null);
 	        this.chunk_ids = source["chunk_ids"];
+	        this.chunk_size = source["chunk_size"];
+	        this.chunk_targets = source["chunk_targets"];
+	        this.replicas = this.convertValues(source["replicas"], ChunkLocation);
+	        this.chunk_checksums = source["chunk_checksums"];
+	        this.data_shards = source["data_shards"];
+	        this.parity_shards = source["parity_shards"];
+	        this.parity_chunks = this.convertValues(source["parity_chunks"], ChunkLocation);
+	        this.parity_checksums = source["parity_checksums"];
+	        this.trashed_at = this.convertValues(source["trashed_at"], null);
+	        this.preview = this.convertValues(source["preview"], Preview);
+	        this.etag = source["etag"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class Preview {
+	    kind: PreviewKind;
+	    mime: string;
+	    file_id: string;
+	    size: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new Preview(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.kind = source["kind"];
+	        this.mime = source["mime"];
+	        this.file_id = source["file_id"];
+	        this.size = source["size"];
+	    }
+	}
+
+}
+
+export namespace policy {
+	
+	export class Rule {
+	    folder?: string;
+	    extension?: string;
+	    min_size?: number;
+	    min_idle_days?: number;
+	    action: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Rule(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.folder = source["folder"];
+	        this.extension = source["extension"];
+	        this.min_size = source["min_size"];
+	        this.min_idle_days = source["min_idle_days"];
+	        this.action = source["action"];
+	    }
+	}
+
+}
+
+export namespace secrets {
+	
+	export class Status {
+	    backend: string;
+	    exists: boolean;
+	    locked: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Status(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.backend = source["backend"];
+	        this.exists = source["exists"];
+	        this.locked = source["locked"];
+	    }
+	}
+
+}
+
+export namespace telegram {
+	
+	export class AccessReport {
+	    bot_id: number;
+	    bot_name: string;
+	    chat_title: string;
+	    chat_type: string;
+	    status: string;
+	    can_post: boolean;
+	    can_pin: boolean;
+	    can_delete: boolean;
+	    missing: string[];
+	    problems: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new AccessReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.bot_id = source["bot_id"];
+	        this.bot_name = source["bot_name"];
+	        this.chat_title = source["chat_title"];
+	        this.chat_type = source["chat_type"];
+	        this.status = source["status"];
+	        this.can_post = source["can_post"];
+	        this.can_pin = source["can_pin"];
+	        this.can_delete = source["can_delete"];
+	        this.missing = source["missing"];
+	        this.problems = source["problems"];
+	    }
+	}
+	export class DayTransfer {
+	    day: string;
+	    uploaded: number;
+	    downloaded: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new DayTransfer(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.day = source["day"];
+	        this.uploaded = source["uploaded"];
+	        this.downloaded = source["downloaded"];
+	    }
+	}
+	export class DirResult {
+	    done: string[];
+	    failed?: Record<string, string>;
+	
+	    static createFrom(source: any = {}) {
+	        return new DirResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.done = source["done"];
+	        this.failed = source["failed"];
+	    }
+	}
+	export class FileHealth {
+	    name: string;
+	    status: string;
+	    missing?: number[];
+	    corrupt?: number[];
+	
+	    static createFrom(source: any = {}) {
+	        return new FileHealth(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.status = source["status"];
+	        this.missing = source["missing"];
+	        this.corrupt = source["corrupt"];
+	    }
+	}
+	export class GCReport {
+	    dry_run: boolean;
+	    scanned: number;
+	    referenced: number;
+	    pending: number;
+	    orphans: SentMessage[];
+	    failed: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new GCReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.dry_run = source["dry_run"];
+	        this.scanned = source["scanned"];
+	        this.referenced = source["referenced"];
+	        this.pending = source["pending"];
+	        this.orphans = this.convertValues(source["orphans"], SentMessage);
+	        this.failed = source["failed"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class GrowthPoint {
+	    day: string;
+	    files: number;
+	    bytes: number;
+	    total_bytes: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new GrowthPoint(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.day = source["day"];
+	        this.files = source["files"];
+	        this.bytes = source["bytes"];
+	        this.total_bytes = source["total_bytes"];
+	    }
+	}
+	export class RepairReport {
+	    checked: number;
+	    dropped: number;
+	    repaired: number;
+	    lost: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RepairReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.checked = source["checked"];
+	        this.dropped = source["dropped"];
+	        this.repaired = source["repaired"];
+	        this.lost = source["lost"];
+	    }
+	}
+	export class ScrubReport {
+	    mode: string;
+	    // Go type: time
+	    started_at: any;
+	    // Go type: time
+	    finished_at: any;
+	    checked: number;
+	    verified: number;
+	    files: FileHealth[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ScrubReport(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.mode = source["mode"];
+	        this.started_at = this.convertValues(source["started_at"], null);
+	        this.finished_at = this.convertValues(source["finished_at"], null);
+	        this.checked = source["checked"];
+	        this.verified = source["verified"];
+	        this.files = this.convertValues(source["files"], FileHealth);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SentMessage {
+	    bot: string;
+	    chat_id: string;
+	    message_id: number;
+	    file_id?: string;
+	    // Go type: time
+	    sent_at: any;
+	
+	    static createFrom(source: any = {}) {
+	        return new SentMessage(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.bot = source["bot"];
+	        this.chat_id = source["chat_id"];
+	        this.message_id = source["message_id"];
+	        this.file_id = source["file_id"];
+	        this.sent_at = this.convertValues(source["sent_at"], null);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice && a.map) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class StorageStats {
+	    files: number;
+	    bytes: number;
+	    states: Record<string, number>;
+	    state_bytes: Record<string, number>;
+	    chunks: number;
+	    stored_bytes: number;
+	    duplicate_bytes: number;
+	    largest: model.FileRecord[];
+	    growth: GrowthPoint[];
+	
+	    static createFrom(source: any = {}) {
+	        return new StorageStats(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.files = source["files"];
+	        this.bytes = source["bytes"];
+	        this.states = source["states"];
+	        this.state_bytes = source["state_bytes"];
+	        this.chunks = source["chunks"];
+	        this.stored_bytes = source["stored_bytes"];
+	        this.duplicate_bytes = source["duplicate_bytes"];
+	        this.largest = this.convertValues(source["largest"], model.FileRecord);
+	        this.growth = this.convertValues(source["growth"], GrowthPoint);
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+	"tstore/internal/config"
+)
+
+const DefaultMaxBytes = 1 << 30
+
+type FetchFn func(ctx context.Context) (io.ReadCloser, error)
+
+type call struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// ChunkCache keeps downloaded chunks on disk and evicts the least recently
+// used ones once the total size exceeds maxBytes.
+type ChunkCache struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	inflight map[string]*call
+}
+
+func NewChunkCache(dir string, maxBytes int64) (*ChunkCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &ChunkCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		inflight: make(map[string]*call),
+	}, nil
+}
+
+func NewDefaultChunkCache() (*ChunkCache, error) {
+	cfgPath, err := config.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(filepath.Dir(cfgPath), "cache", "chunks")
+	return NewChunkCache(dir, DefaultMaxBytes)
+}
+
+func (c *ChunkCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get returns the cached chunk for key, fetching it at most once when
+// several readers ask for the same missing chunk concurrently. The shared
+// fetch is not tied to any one reader, so a reader that gives up does not
+// fail the others waiting on the same chunk.
+func (c *ChunkCache) Get(ctx context.Context, key string, fetch FetchFn) ([]byte, error) {
+	p := c.path(key)
+	if data, err := os.ReadFile(p); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(p, now, now)
+		return data, nil
+	}
+
+	c.mu.Lock()
+	cl, ok := c.inflight[key]
+	if !ok {
+		cl = &call{done: make(chan struct{})}
+		c.inflight[key] = cl
+		go c.run(context.WithoutCancel(ctx), key, p, cl, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-cl.done:
+		return cl.data, cl.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *ChunkCache) run(ctx context.Context, key, p string, cl *call, fetch FetchFn) {
+	cl.data, cl.err = c.fill(ctx, p, fetch)
+
+	c.mu.Lock()
+	delete(c.inflight, key)
+	c.mu.Unlock()
+
+	close(cl.done)
+}
+
+func (c *ChunkCache) fill(ctx context.Context, p string, fetch FetchFn) ([]byte, error) {
+	rc, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read chunk: %w", err)
+	}
+
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return nil, fmt.Errorf("write cache entry: %w", err)
+	}
+	if err := os.Rename(tmp, p); err != nil {
+		os.Remove(tmp)
+		return nil, fmt.Errorf("commit cache entry: %w", err)
+	}
+
+	// The chunk is fetched; a cache that cannot be trimmed must not fail
+	// the read.
+	if err := c.evict(); err != nil {
+		log.Printf("evict cache entries: %v", err)
+	}
+
+	return data, nil
+}
+
+func (c *ChunkCache) evict() error {
+	if c.maxBytes <= 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		total int64
+		infos []os.FileInfo
+	)
+	for _, e := range entries {
+		if filepath.Ext(e.Name()) == ".tmp" {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		total += info.Size()
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ModTime().Before(infos[j].ModTime())
+	})
+	for _, info := range infos {
+		if total <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, info.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		total -= info.Size()
+	}
+
+	return nil
+}
+
+func (c *ChunkCache) Clear() error {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Remove(filepath.Join(c.dir, e.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChunkCache_GetCachesAndEvicts(t *testing.T) {
+	c, err := NewChunkCache(t.TempDir(), 8)
+	if err != nil {
+		t.Fatalf("NewChunkCache: %v", err)
+	}
+
+	ctx := context.Background()
+	fetches := map[string]int{}
+	fetch := func(key, data string) FetchFn {
+		return func(ctx context.Context) (io.ReadCloser, error) {
+			fetches[key]++
+			return io.NopCloser(bytes.NewBufferString(data)), nil
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := c.Get(ctx, "a", fetch("a", "aaaa"))
+		if err != nil {
+			t.Fatalf("Get(a): %v", err)
+		}
+		if string(got) != "aaaa" {
+			t.Errorf("Get(a) = %q; want %q", got, "aaaa")
+		}
+	}
+	if fetches["a"] != 1 {
+		t.Errorf("fetches[a] = %d; want 1", fetches["a"])
+	}
+
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(c.path("a"), old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	if _, err := c.Get(ctx, "b", fetch("b", "bbbb")); err != nil {
+		t.Fatalf("Get(b): %v", err)
+	}
+	if _, err := c.Get(ctx, "c", fetch("c", "cccc")); err != nil {
+		t.Fatalf("Get(c): %v", err)
+	}
+
+	if _, err := os.Stat(c.path("a")); !os.IsNotExist(err) {
+		t.Errorf("expected least recently used entry to be evicted, Stat err = %v", err)
+	}
+	if _, err := os.Stat(c.path("c")); err != nil {
+		t.Errorf("expected newest entry to be cached: %v", err)
+	}
+}
+
+func TestChunkCache_GetSucceedsWhenEvictionFails(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can list a directory without read permission")
+	}
+	dir := t.TempDir()
+	c, err := NewChunkCache(dir, 8)
+	if err != nil {
+		t.Fatalf("NewChunkCache: %v", err)
+	}
+	// Entries can still be written, but eviction cannot list them.
+	if err := os.Chmod(dir, 0o300); err != nil {
+		t.Fatalf("Chmod: %v", err)
+	}
+	defer os.Chmod(dir, 0o700)
+
+	got, err := c.Get(context.Background(), "a", func(ctx context.Context) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewBufferString("aaaa")), nil
+	})
+	if err != nil || string(got) != "aaaa" {
+		t.Errorf("Get = %q, %v; want %q", got, err, "aaaa")
+	}
+}
+
+func TestChunkCache_WaiterSurvivesFirstCallerCancel(t *testing.T) {
+	c, err := NewChunkCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewChunkCache: %v", err)
+	}
+
+	var once sync.Once
+	started := make(chan struct{})
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		once.Do(func() { close(started) })
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		return io.NopCloser(bytes.NewBufferString("aaaa")), nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "a", fetch)
+		firstErr <- err
+	}()
+	<-started
+
+	type result struct {
+		data []byte
+		err  error
+	}
+	second := make(chan result, 1)
+	go func() {
+		data, err := c.Get(context.Background(), "a", fetch)
+		second <- result{data, err}
+	}()
+
+	cancel()
+	if err := <-firstErr; err != context.Canceled {
+		t.Errorf("first Get err = %v; want %v", err, context.Canceled)
+	}
+	close(release)
+
+	got := <-second
+	if got.err != nil || string(got.data) != "aaaa" {
+		t.Errorf("second Get = %q, %v; want %q", got.data, got.err, "aaaa")
+	}
+}
@@ -4,56 +4,153 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"tstore/internal/policy"
 )
 
+// Target is an extra bot and chat that chunks can be stored in. An empty
+// BotToken means the primary bot.
+type Target struct {
+	Name     string `json:"name"`
+	BotToken string `json:"bot_token,omitempty"`
+	ChatID   string `json:"chat_id"`
+	MaxBytes int64  `json:"max_bytes,omitempty"`
+}
+
 type Config struct {
-	BotToken   string `json:"bot_token"`
-	ChatID     string `json:"chat_id"`
-	SyncFolder string `json:"sync_folder"`
+	BotToken            string            `json:"bot_token"`
+	ChatID              string            `json:"chat_id"`
+	SyncFolder          string            `json:"sync_folder"`
+	OffloadRules        []policy.Rule     `json:"offload_rules"`
+	MaxLocalBytes       int64             `json:"max_local_bytes"`
+	MountPoint          string            `json:"mount_point"`
+	GatewayAddr         string            `json:"gateway_addr"`
+	WebDAVAddr          string            `json:"webdav_addr"`
+	WebDAVUser          string            `json:"webdav_user"`
+	WebDAVPassword      string            `json:"webdav_password"`
+	S3Addr              string            `json:"s3_addr"`
+	S3AccessKey         string            `json:"s3_access_key"`
+	S3SecretKey         string            `json:"s3_secret_key"`
+	BotAllowedUsers     []int64           `json:"bot_allowed_users"`
+	Targets             []Target          `json:"targets"`
+	Placement           string            `json:"placement"`
+	FolderTargets       map[string]string `json:"folder_targets"`
+	ReplicationFactor   int               `json:"replication_factor"`
+	ErasureDataShards   int               `json:"erasure_data_shards"`
+	ErasureParityShards int               `json:"erasure_parity_shards"`
+	ScrubMode           string            `json:"scrub_mode"`
+	GCGraceDays         int               `json:"gc_grace_days"`
+	TrashRetentionDays  int               `json:"trash_retention_days"`
+	ContentIndex        bool              `json:"content_index"`
+	ChunkSize           int64             `json:"chunk_size"`
+	Concurrency         int               `json:"concurrency"`
 }
 
+// ConfigPath returns the config.json of the active profile. Metadata,
+// caches and logs are kept in the same directory.
 func ConfigPath() (string, error) {
-	dir, err := os.UserConfigDir()
+	path, err := configPath()
 	if err != nil {
 		return "", err
 	}
-
-	cfgDir := filepath.Join(dir, "tstore")
-	if err := os.MkdirAll(cfgDir, 0o700); err != nil {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
 		return "", err
 	}
+	return path, nil
+}
 
-	return filepath.Join(cfgDir, "config.json"), nil
+// configPath is ConfigPath without creating the profile directory.
+func configPath() (string, error) {
+	dir, err := ProfileDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
 }
 
+// LoadConfig returns the effective config: the defaults, overlaid with
+// config.json, then TSTORE_* environment variables, then -set flags.
 func LoadConfig() (*Config, error) {
+	cfg, err := loadFile()
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOverrides(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// PeekConfig returns the effective config like LoadConfig but never writes:
+// a missing config.json yields the defaults and is not created.
+func PeekConfig() (*Config, error) {
+	cfg, err := peekFile()
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOverrides(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// peekFile reads config.json over the defaults, or returns the defaults
+// when it does not exist.
+func peekFile() (*Config, error) {
+	path, err := configPath()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := readFile(path)
+	if os.IsNotExist(err) {
+		cfg = Defaults()
+		resetLayers(cfg)
+		return cfg, nil
+	}
+	return cfg, err
+}
+
+// loadFile reads config.json over the defaults, creating the file when it
+// does not exist yet.
+func loadFile() (*Config, error) {
 	path, err := ConfigPath()
 	if err != nil {
 		return nil, err
 	}
 
-	f, err := os.Open(path)
+	cfg, err := readFile(path)
 	if os.IsNotExist(err) {
-		cfg := &Config{}
+		cfg = Defaults()
+		resetLayers(cfg)
 		return cfg, SaveConfig(cfg)
-	} else if err != nil {
+	}
+	return cfg, err
+}
+
+// readFile reads the config.json at path over the defaults.
+func readFile(path string) (*Config, error) {
+	cfg := Defaults()
+	f, err := os.Open(path)
+	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
-	var cfg Config
-	if err := json.NewDecoder(f).Decode(&cfg); err != nil {
+	if err := json.NewDecoder(f).Decode(cfg); err != nil {
 		return nil, err
 	}
+	resetLayers(cfg)
 
-	return &cfg, nil
+	return cfg, nil
 }
 
+// SaveConfig writes cfg to config.json. Settings overridden by the
+// environment or flags keep their file values.
 func SaveConfig(cfg *Config) error {
 	path, err := ConfigPath()
 	if err != nil {
 		return err
 	}
+	cfg = withFileValues(cfg)
 
 	tmp := path + ".tmp"
 	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
@@ -69,5 +166,9 @@ func SaveConfig(cfg *Config) error {
 	}
 	f.Close()
 
-	return os.Rename(tmp, path)
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	setFileLayer(cfg)
+	return nil
 }
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// EnvPrefix starts the environment variables that override settings:
+// TSTORE_CHAT_ID sets chat_id. String settings take the value as is, all
+// others are parsed as JSON, e.g. TSTORE_BOT_ALLOWED_USERS='[42]'.
+const EnvPrefix = "TSTORE_"
+
+const (
+	DefaultChunkSize   = 5 << 20
+	DefaultConcurrency = 1
+)
+
+// Defaults returns the settings used for anything config.json, the
+// environment and flags leave unset.
+func Defaults() *Config {
+	return &Config{
+		ChunkSize:   DefaultChunkSize,
+		Concurrency: DefaultConcurrency,
+	}
+}
+
+var (
+	layerMu sync.Mutex
+	// flagOverrides are the -set key=value flags.
+	flagOverrides map[string]string
+	// fileLayer is config.json as last read or written. overridden holds
+	// the keys the environment or flags replaced on top of it, with the
+	// source of each.
+	fileLayer  *Config
+	overridden map[string]string
+)
+
+// fieldIndex maps JSON keys to Config field indexes.
+var fieldIndex = func() map[string]int {
+	fields := make(map[string]int)
+	t := reflect.TypeOf(Config{})
+	for i := range t.NumField() {
+		key, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[key] = i
+	}
+	return fields
+}()
+
+// Keys returns every setting's JSON key, sorted.
+func Keys() []string {
+	keys := make([]string, 0, len(fieldIndex))
+	for key := range fieldIndex {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SetFlagOverrides sets the values given with -set, keyed by JSON key. They
+// take precedence over the environment.
+func SetFlagOverrides(values map[string]string) error {
+	for key, value := range values {
+		if err := setField(Defaults(), key, value); err != nil {
+			return err
+		}
+	}
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	flagOverrides = values
+	return nil
+}
+
+// Overridden returns where each setting replaced by the last load came
+// from: "env" or "flag".
+func Overridden() map[string]string {
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	sources := make(map[string]string, len(overridden))
+	for key, source := range overridden {
+		sources[key] = source
+	}
+	return sources
+}
+
+func isOverridden(key string) bool {
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	_, ok := overridden[key]
+	return ok
+}
+
+func setField(cfg *Config, key, value string) error {
+	i, ok := fieldIndex[key]
+	if !ok {
+		return fmt.Errorf("unknown setting %q", key)
+	}
+	field := reflect.ValueOf(cfg).Elem().Field(i)
+	if field.Kind() == reflect.String {
+		field.SetString(value)
+		return nil
+	}
+	fresh := reflect.New(field.Type())
+	if err := json.Unmarshal([]byte(value), fresh.Interface()); err != nil {
+		return fmt.Errorf("invalid value for %s: %w", key, err)
+	}
+	field.Set(fresh.Elem())
+	return nil
+}
+
+// applyOverrides applies TSTORE_* variables and then -set flags to cfg.
+func applyOverrides(cfg *Config) error {
+	sources := make(map[string]string)
+	for _, key := range Keys() {
+		value, ok := os.LookupEnv(EnvPrefix + strings.ToUpper(key))
+		if !ok {
+			continue
+		}
+		if err := setField(cfg, key, value); err != nil {
+			return fmt.Errorf("%s%s: %w", EnvPrefix, strings.ToUpper(key), err)
+		}
+		sources[key] = "env"
+	}
+
+	layerMu.Lock()
+	flags := flagOverrides
+	layerMu.Unlock()
+	for key, value := range flags {
+		if err := setField(cfg, key, value); err != nil {
+			return fmt.Errorf("-set %s: %w", key, err)
+		}
+		sources[key] = "flag"
+	}
+
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	overridden = sources
+	return nil
+}
+
+// resetLayers records cfg as freshly read from config.json, with nothing
+// overridden yet.
+func resetLayers(cfg *Config) {
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	fileLayer = cfg.clone()
+	overridden = nil
+}
+
+func setFileLayer(cfg *Config) {
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	fileLayer = cfg.clone()
+}
+
+// withFileValues returns cfg with every overridden setting put back to its
+// config.json value, so an override never ends up in the file.
+func withFileValues(cfg *Config) *Config {
+	layerMu.Lock()
+	defer layerMu.Unlock()
+	if len(overridden) == 0 || fileLayer == nil {
+		return cfg
+	}
+
+	out := cfg.clone()
+	dst := reflect.ValueOf(out).Elem()
+	src := reflect.ValueOf(fileLayer).Elem()
+	for key := range overridden {
+		i := fieldIndex[key]
+		dst.Field(i).Set(src.Field(i))
+	}
+	return out
+}
+
+func (c *Config) clone() *Config {
+	out := *c
+	out.OffloadRules = slices.Clone(c.OffloadRules)
+	out.BotAllowedUsers = slices.Clone(c.BotAllowedUsers)
+	out.Targets = slices.Clone(c.Targets)
+	out.FolderTargets = maps.Clone(c.FolderTargets)
+	return &out
+}
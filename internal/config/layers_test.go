@@ -0,0 +1,124 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_Layers(t *testing.T) {
+	dir := t.TempDir()
+	if err := SetConfigDir(dir); err != nil {
+		t.Fatalf("SetConfigDir: %v", err)
+	}
+	t.Cleanup(func() {
+		configDir = ""
+		SetFlagOverrides(nil)
+	})
+
+	path, err := ConfigPath()
+	if err != nil {
+		t.Fatalf("ConfigPath: %v", err)
+	}
+	if !strings.HasPrefix(path, dir) {
+		t.Fatalf("ConfigPath = %s; want it under %s", path, dir)
+	}
+	file := `{"chat_id": "-100", "sync_folder": "/data", "concurrency": 2}`
+	if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	t.Setenv("TSTORE_CHAT_ID", "-200")
+	t.Setenv("TSTORE_BOT_ALLOWED_USERS", "[42]")
+	t.Setenv("TSTORE_CONCURRENCY", "3")
+	if err := SetFlagOverrides(map[string]string{"concurrency": "4"}); err != nil {
+		t.Fatalf("SetFlagOverrides: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.ChunkSize != DefaultChunkSize {
+		t.Errorf("ChunkSize = %d; want the default", cfg.ChunkSize)
+	}
+	if cfg.SyncFolder != "/data" {
+		t.Errorf("SyncFolder = %q; want the file value", cfg.SyncFolder)
+	}
+	if cfg.ChatID != "-200" || len(cfg.BotAllowedUsers) != 1 || cfg.BotAllowedUsers[0] != 42 {
+		t.Errorf("env overrides not applied: chat_id %q, bot_allowed_users %v", cfg.ChatID, cfg.BotAllowedUsers)
+	}
+	if cfg.Concurrency != 4 {
+		t.Errorf("Concurrency = %d; want the flag to beat the environment", cfg.Concurrency)
+	}
+	if src := Overridden(); src["chat_id"] != "env" || src["concurrency"] != "flag" || len(src) != 3 {
+		t.Errorf("Overridden = %v", src)
+	}
+
+	cfg.SyncFolder = "/elsewhere"
+	if err := SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	os.Unsetenv("TSTORE_CHAT_ID")
+	os.Unsetenv("TSTORE_BOT_ALLOWED_USERS")
+	os.Unsetenv("TSTORE_CONCURRENCY")
+	SetFlagOverrides(nil)
+	saved, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if saved.SyncFolder != "/elsewhere" || saved.ChatID != "-100" || saved.Concurrency != 2 || saved.BotAllowedUsers != nil {
+		t.Errorf("saved config = %+v; want the edit kept and the overrides left out", saved)
+	}
+
+	if err := SetFlagOverrides(map[string]string{"colour": "red"}); err == nil {
+		t.Error("expected an error for an unknown setting")
+	}
+	t.Setenv("TSTORE_CHUNK_SIZE", "big")
+	if _, err := LoadConfig(); err == nil || !strings.Contains(err.Error(), "TSTORE_CHUNK_SIZE") {
+		t.Errorf("LoadConfig with a malformed variable = %v", err)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := Defaults().Validate(); err != nil {
+		t.Errorf("defaults are invalid: %v", err)
+	}
+
+	cfg := Defaults()
+	cfg.BotToken = "not a token"
+	cfg.ChatID = "my chat"
+	cfg.ChunkSize = 50 << 20
+	cfg.Concurrency = 0
+	cfg.WebDAVAddr = "localhost"
+	cfg.Targets = []Target{{Name: "a", ChatID: "-1"}, {Name: "a", ChatID: "-2"}}
+	cfg.FolderTargets = map[string]string{"photos": "b"}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate succeeded; want errors")
+	}
+	for _, want := range []string{"bot_token", "chat_id", "chunk_size", "concurrency", "webdav_addr", "webdav_user", "duplicate storage target", `unknown storage target "b"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Validate error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestConfig_ValidateWithoutSecrets(t *testing.T) {
+	cfg := Defaults()
+	cfg.WebDAVAddr = "127.0.0.1:8080"
+	cfg.WebDAVUser = "dav"
+	cfg.S3Addr = "127.0.0.1:9000"
+	cfg.S3AccessKey = "s3"
+	if err := cfg.ValidateWithoutSecrets(); err != nil {
+		t.Errorf("ValidateWithoutSecrets: %v", err)
+	}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Validate succeeded without webdav_password and s3_secret_key")
+	}
+
+	cfg.WebDAVUser = ""
+	if err := cfg.ValidateWithoutSecrets(); err == nil || !strings.Contains(err.Error(), "webdav_user") {
+		t.Errorf("ValidateWithoutSecrets without webdav_user = %v", err)
+	}
+}
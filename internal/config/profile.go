@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// DefaultProfile is the profile whose files live directly in the tstore
+// config directory, where they were before profiles existed.
+const DefaultProfile = "default"
+
+var profileNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ConfigDirEnv overrides the directory tstore keeps its profiles in.
+const ConfigDirEnv = "TSTORE_CONFIG_DIR"
+
+var (
+	profileMu sync.RWMutex
+	profile   = DefaultProfile
+	configDir string
+)
+
+// ValidProfileName reports whether name can be used as a profile name:
+// up to 32 lower-case letters, digits, '-' and '_'.
+func ValidProfileName(name string) bool {
+	return profileNameRe.MatchString(name)
+}
+
+// SetProfile selects the profile ConfigPath and everything stored next to
+// it refer to. It is meant to be called once at startup; separate profiles
+// run in separate processes.
+func SetProfile(name string) error {
+	if !ValidProfileName(name) {
+		return fmt.Errorf("invalid profile name %q", name)
+	}
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	profile = name
+	return nil
+}
+
+func Profile() string {
+	profileMu.RLock()
+	defer profileMu.RUnlock()
+	return profile
+}
+
+// SetConfigDir replaces the tstore directory under the user config
+// directory, and any TSTORE_CONFIG_DIR, with dir.
+func SetConfigDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	profileMu.Lock()
+	defer profileMu.Unlock()
+	configDir = abs
+	return nil
+}
+
+// ConfigDir returns the directory holding every profile.
+func ConfigDir() (string, error) {
+	return baseDir()
+}
+
+// ProfileDir returns the directory of the active profile, which need not
+// exist yet.
+func ProfileDir() (string, error) {
+	base, err := baseDir()
+	if err != nil {
+		return "", err
+	}
+	return profileDir(base, Profile()), nil
+}
+
+func baseDir() (string, error) {
+	profileMu.RLock()
+	dir := configDir
+	profileMu.RUnlock()
+	if dir != "" {
+		return dir, nil
+	}
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return filepath.Abs(dir)
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tstore"), nil
+}
+
+func profileDir(base, name string) string {
+	if name == DefaultProfile {
+		return base
+	}
+	return filepath.Join(base, "profiles", name)
+}
+
+// ListProfiles returns the default profile and every profile that has been
+// created, sorted by name.
+func ListProfiles() ([]string, error) {
+	base, err := baseDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(base, "profiles"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	names := []string{DefaultProfile}
+	for _, e := range entries {
+		if e.IsDir() && ValidProfileName(e.Name()) && e.Name() != DefaultProfile {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
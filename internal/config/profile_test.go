@@ -0,0 +1,59 @@
+package config
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("HOME", home)
+	t.Cleanup(func() { SetProfile(DefaultProfile) })
+
+	path, err := ConfigPath()
+	if err != nil {
+		t.Fatalf("ConfigPath: %v", err)
+	}
+	if want := filepath.Join(home, "tstore", "config.json"); path != want {
+		t.Errorf("default ConfigPath = %s; want %s", path, want)
+	}
+
+	for _, name := range []string{"", "Work", "../up", "a b", strings.Repeat("x", 33)} {
+		if err := SetProfile(name); err == nil {
+			t.Errorf("SetProfile(%q) succeeded; want error", name)
+		}
+	}
+	if err := SetProfile("work"); err != nil {
+		t.Fatalf("SetProfile: %v", err)
+	}
+	path, err = ConfigPath()
+	if err != nil {
+		t.Fatalf("ConfigPath: %v", err)
+	}
+	if want := filepath.Join(home, "tstore", "profiles", "work", "config.json"); path != want {
+		t.Errorf("work ConfigPath = %s; want %s", path, want)
+	}
+	if err := SaveConfig(&Config{ChatID: "-100"}); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	SetProfile(DefaultProfile)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.ChatID != "" {
+		t.Errorf("default profile sees the work profile's chat %q", cfg.ChatID)
+	}
+
+	names, err := ListProfiles()
+	if err != nil {
+		t.Fatalf("ListProfiles: %v", err)
+	}
+	if want := []string{"default", "work"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("ListProfiles = %v; want %v", names, want)
+	}
+}
@@ -0,0 +1,224 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"tstore/internal/secrets"
+)
+
+// secretFields maps the names secrets are stored under to the fields that
+// hold them once loaded.
+func (c *Config) secretFields() map[string]*string {
+	fields := map[string]*string{
+		"bot_token":       &c.BotToken,
+		"webdav_password": &c.WebDAVPassword,
+		"s3_secret_key":   &c.S3SecretKey,
+	}
+	for i := range c.Targets {
+		fields["targets/"+c.Targets[i].Name+"/bot_token"] = &c.Targets[i].BotToken
+	}
+	return fields
+}
+
+// HasSecrets reports whether any secret is set in cfg, as it is in a
+// config.json written before secrets moved to a secret store.
+func (c *Config) HasSecrets() bool {
+	for _, field := range c.secretFields() {
+		if *field != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// ClearSecrets blanks every secret field.
+func (c *Config) ClearSecrets() {
+	for _, field := range c.secretFields() {
+		*field = ""
+	}
+}
+
+// ClearStoredSecrets blanks the secret fields that were not set by an
+// environment variable or -set flag, which need no secret store.
+func (c *Config) ClearStoredSecrets() {
+	for name, field := range c.secretFields() {
+		if !isOverridden(secretKey(name)) {
+			*field = ""
+		}
+	}
+}
+
+func (c *Config) withoutSecrets() *Config {
+	clone := c.clone()
+	clone.ClearSecrets()
+	return clone
+}
+
+// Redacted returns a copy of c with every secret that is set replaced, for
+// printing.
+func (c *Config) Redacted() *Config {
+	clone := c.clone()
+	for _, field := range clone.secretFields() {
+		if *field != "" {
+			*field = "REDACTED"
+		}
+	}
+	return clone
+}
+
+// RedactedLocked is Redacted for a config loaded while its secret store
+// was locked. Blank secrets that would have come from the store are shown
+// as "(vault locked)" rather than as unset.
+func (c *Config) RedactedLocked() *Config {
+	clone := c.Redacted()
+	for name, field := range clone.secretFields() {
+		if *field == "" && !isOverridden(secretKey(name)) {
+			*field = "(vault locked)"
+		}
+	}
+	return clone
+}
+
+// secretKey returns the setting a secret name belongs to.
+func secretKey(name string) string {
+	key, _, _ := strings.Cut(name, "/")
+	return key
+}
+
+// LoadConfigWithSecrets loads config.json and fills its secrets in from
+// store. Secrets still written in the file are moved into store first and
+// stripped from it. Environment and flag overrides apply last, so a token
+// given in TSTORE_BOT_TOKEN wins over the stored one and is never stored.
+func LoadConfigWithSecrets(store secrets.Store) (*Config, error) {
+	cfg, err := loadFile()
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.HasSecrets() {
+		for name, field := range cfg.secretFields() {
+			if *field == "" {
+				continue
+			}
+			if err := store.Set(name, *field); err != nil {
+				return nil, fmt.Errorf("migrating %s: %w", name, err)
+			}
+		}
+		if err := SaveConfig(cfg.withoutSecrets()); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, field := range cfg.secretFields() {
+		if *field != "" {
+			continue
+		}
+		value, err := store.Get(name)
+		if errors.Is(err, secrets.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", name, err)
+		}
+		*field = value
+	}
+
+	if err := applyOverrides(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// PeekConfigWithSecrets is PeekConfig with the secrets store holds filled
+// in. Nothing is written: secrets still in config.json stay there. While
+// store is locked the secrets it holds stay blank and locked is true.
+func PeekConfigWithSecrets(store secrets.Store) (cfg *Config, locked bool, err error) {
+	cfg, err = peekFile()
+	if err != nil {
+		return nil, false, err
+	}
+	for name, field := range cfg.secretFields() {
+		if *field != "" {
+			continue
+		}
+		value, err := store.Get(name)
+		if errors.Is(err, secrets.ErrNotFound) {
+			continue
+		} else if errors.Is(err, secrets.ErrLocked) {
+			locked = true
+			continue
+		} else if err != nil {
+			return nil, false, fmt.Errorf("reading %s: %w", name, err)
+		}
+		*field = value
+	}
+	if err := applyOverrides(cfg); err != nil {
+		return nil, false, err
+	}
+	return cfg, locked, nil
+}
+
+// SaveConfigWithSecrets writes cfg to config.json with its secrets kept in
+// store instead. Empty secret fields are deleted from store, as are the
+// tokens of targets that were removed or renamed, and overridden ones are
+// left alone.
+func SaveConfigWithSecrets(cfg *Config, store secrets.Store) error {
+	fields := cfg.secretFields()
+	for name, field := range fields {
+		if isOverridden(secretKey(name)) {
+			continue
+		}
+		var err error
+		if *field == "" {
+			err = store.Delete(name)
+		} else {
+			err = store.Set(name, *field)
+		}
+		if err != nil {
+			return fmt.Errorf("storing %s: %w", name, err)
+		}
+	}
+
+	if !isOverridden("targets") {
+		stale, err := targetSecrets(store)
+		if err != nil {
+			return err
+		}
+		for _, name := range stale {
+			if _, ok := fields[name]; ok {
+				continue
+			}
+			if err := store.Delete(name); err != nil {
+				return fmt.Errorf("deleting %s: %w", name, err)
+			}
+		}
+	}
+	return SaveConfig(cfg.withoutSecrets())
+}
+
+// targetSecrets returns the names of the target tokens that may be in
+// store: those of the targets in config.json as last read or written, and
+// every stored one when store can list its contents.
+func targetSecrets(store secrets.Store) ([]string, error) {
+	var names []string
+	layerMu.Lock()
+	if fileLayer != nil {
+		for _, t := range fileLayer.Targets {
+			names = append(names, "targets/"+t.Name+"/bot_token")
+		}
+	}
+	layerMu.Unlock()
+
+	if l, ok := store.(secrets.Lister); ok {
+		stored, err := l.Names()
+		if err != nil {
+			return nil, fmt.Errorf("listing secrets: %w", err)
+		}
+		for _, name := range stored {
+			if strings.HasPrefix(name, "targets/") {
+				names = append(names, name)
+			}
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,149 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tstore/internal/secrets"
+)
+
+func TestLoadConfigWithSecrets_MigratesPlaintext(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	legacy := &Config{
+		BotToken: "123:ABC",
+		ChatID:   "-100",
+		Targets:  []Target{{Name: "archive", BotToken: "456:DEF", ChatID: "-200"}, {Name: "spare", ChatID: "-300"}},
+	}
+	if err := SaveConfig(legacy); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	path, err := ConfigPath()
+	if err != nil {
+		t.Fatalf("ConfigPath: %v", err)
+	}
+	vault := secrets.NewVault(filepath.Join(filepath.Dir(path), "secrets.vault"))
+	if _, err := LoadConfigWithSecrets(vault); err == nil {
+		t.Fatal("expected an error loading secrets from a locked vault")
+	}
+	if err := vault.Unlock("pw"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+
+	cfg, err := LoadConfigWithSecrets(vault)
+	if err != nil {
+		t.Fatalf("LoadConfigWithSecrets: %v", err)
+	}
+	if cfg.BotToken != "123:ABC" || cfg.Targets[0].BotToken != "456:DEF" || cfg.Targets[1].BotToken != "" {
+		t.Errorf("loaded config = %+v; want the original secrets", cfg)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if strings.Contains(string(data), "123:ABC") || strings.Contains(string(data), "456:DEF") {
+		t.Errorf("config.json still holds a token after migration:\n%s", data)
+	}
+
+	cfg.BotToken = "789:GHI"
+	cfg.Targets[0].BotToken = ""
+	if err := SaveConfigWithSecrets(cfg, vault); err != nil {
+		t.Fatalf("SaveConfigWithSecrets: %v", err)
+	}
+	if cfg.BotToken != "789:GHI" {
+		t.Error("SaveConfigWithSecrets cleared the caller's config")
+	}
+	reloaded, err := LoadConfigWithSecrets(vault)
+	if err != nil {
+		t.Fatalf("LoadConfigWithSecrets: %v", err)
+	}
+	if reloaded.BotToken != "789:GHI" || reloaded.Targets[0].BotToken != "" {
+		t.Errorf("reloaded config = %+v; want the new token and no target token", reloaded)
+	}
+}
+
+// mapStore is a Store that, like the OS keyring, cannot list its contents.
+type mapStore map[string]string
+
+func (m mapStore) Get(name string) (string, error) {
+	value, ok := m[name]
+	if !ok {
+		return "", secrets.ErrNotFound
+	}
+	return value, nil
+}
+
+func (m mapStore) Set(name, value string) error {
+	m[name] = value
+	return nil
+}
+
+func (m mapStore) Delete(name string) error {
+	delete(m, name)
+	return nil
+}
+
+func TestSaveConfigWithSecrets_DropsStaleTargetTokens(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	vault := secrets.NewVault(filepath.Join(t.TempDir(), "secrets.vault"))
+	if err := vault.Unlock("pw"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	// A token left behind by an earlier version, which only a store that
+	// can list its contents can find.
+	vault.Set("targets/orphan/bot_token", "000:ZZZ")
+
+	for _, store := range []secrets.Store{vault, mapStore{}} {
+		cfg := &Config{
+			BotToken: "123:ABC",
+			ChatID:   "-100",
+			Targets:  []Target{{Name: "archive", BotToken: "456:DEF", ChatID: "-200"}, {Name: "spare", BotToken: "789:GHI", ChatID: "-300"}},
+		}
+		if err := SaveConfigWithSecrets(cfg, store); err != nil {
+			t.Fatalf("SaveConfigWithSecrets: %v", err)
+		}
+
+		// Rename archive and drop spare.
+		cfg.Targets = []Target{{Name: "cold", BotToken: "456:DEF", ChatID: "-200"}}
+		if err := SaveConfigWithSecrets(cfg, store); err != nil {
+			t.Fatalf("SaveConfigWithSecrets: %v", err)
+		}
+
+		for name, want := range map[string]string{
+			"targets/cold/bot_token":    "456:DEF",
+			"targets/archive/bot_token": "",
+			"targets/spare/bot_token":   "",
+			"targets/orphan/bot_token":  "",
+		} {
+			if got, _ := store.Get(name); got != want {
+				t.Errorf("%T: %s = %q; want %q", store, name, got, want)
+			}
+		}
+	}
+}
+
+func TestClearStoredSecrets_KeepsOverrides(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	if err := SaveConfig(&Config{ChatID: "-100", S3SecretKey: "plain"}); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+
+	t.Setenv("TSTORE_BOT_TOKEN", "123:ENV")
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	cfg.ClearStoredSecrets()
+	if cfg.BotToken != "123:ENV" {
+		t.Errorf("BotToken = %q; want the TSTORE_BOT_TOKEN value kept", cfg.BotToken)
+	}
+	if cfg.S3SecretKey != "" {
+		t.Errorf("S3SecretKey = %q; want it cleared", cfg.S3SecretKey)
+	}
+}
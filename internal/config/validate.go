@@ -0,0 +1,142 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"tstore/internal/ingestion"
+)
+
+const (
+	MinChunkSize = 64 << 10
+	// MaxChunkSize is the largest file the Bot API lets a bot download.
+	MaxChunkSize   = 20 << 20
+	MaxConcurrency = 16
+)
+
+var (
+	botTokenRe = regexp.MustCompile(`^[0-9]+:[A-Za-z0-9_-]+$`)
+	usernameRe = regexp.MustCompile(`^@[A-Za-z][A-Za-z0-9_]{3,}$`)
+)
+
+func validChatID(id string) bool {
+	if _, err := strconv.ParseInt(id, 10, 64); err == nil {
+		return true
+	}
+	return usernameRe.MatchString(id)
+}
+
+// Validate checks every setting on its own and against the others. Empty
+// settings are allowed where a feature is simply off; whether the sync
+// folder exists and the bot can reach the chat is checked when saving.
+func (c *Config) Validate() error {
+	return c.validate(true)
+}
+
+// ValidateWithoutSecrets is Validate for a config whose stored secrets
+// could not be read, as while the secret vault is locked: a feature is not
+// failed for missing the secret it needs.
+func (c *Config) ValidateWithoutSecrets() error {
+	return c.validate(false)
+}
+
+func (c *Config) validate(withSecrets bool) error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.BotToken != "" && !botTokenRe.MatchString(c.BotToken) {
+		fail("bot_token is not a bot token")
+	}
+	if c.ChatID != "" && !validChatID(c.ChatID) {
+		fail("invalid chat_id %q", c.ChatID)
+	}
+	if c.ChunkSize < MinChunkSize || c.ChunkSize > MaxChunkSize {
+		fail("chunk_size %d must be between %d and %d bytes", c.ChunkSize, MinChunkSize, MaxChunkSize)
+	}
+	if c.Concurrency < 1 || c.Concurrency > MaxConcurrency {
+		fail("concurrency %d must be between 1 and %d", c.Concurrency, MaxConcurrency)
+	}
+	for i, rule := range c.OffloadRules {
+		if err := rule.Validate(); err != nil {
+			fail("invalid offload rule %d: %w", i, err)
+		}
+	}
+	if c.MaxLocalBytes < 0 {
+		fail("invalid max_local_bytes %d", c.MaxLocalBytes)
+	}
+
+	for _, a := range []struct{ key, addr string }{
+		{"gateway_addr", c.GatewayAddr},
+		{"webdav_addr", c.WebDAVAddr},
+		{"s3_addr", c.S3Addr},
+	} {
+		if a.addr == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(a.addr); err != nil {
+			fail("invalid %s %q: %w", a.key, a.addr, err)
+		}
+	}
+	if c.WebDAVAddr != "" && (c.WebDAVUser == "" || withSecrets && c.WebDAVPassword == "") {
+		fail("webdav_user and webdav_password are required when webdav_addr is set")
+	}
+	if c.S3Addr != "" && (c.S3AccessKey == "" || withSecrets && c.S3SecretKey == "") {
+		fail("s3_access_key and s3_secret_key are required when s3_addr is set")
+	}
+	for _, id := range c.BotAllowedUsers {
+		if id <= 0 {
+			fail("invalid user ID %d in bot_allowed_users", id)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, t := range c.Targets {
+		if t.Name == "" || t.ChatID == "" {
+			fail("storage target %d needs a name and chat_id", i)
+			continue
+		}
+		if seen[t.Name] {
+			fail("duplicate storage target %q", t.Name)
+		}
+		seen[t.Name] = true
+		if !validChatID(t.ChatID) {
+			fail("invalid chat_id %q for storage target %q", t.ChatID, t.Name)
+		}
+		if t.BotToken != "" && !botTokenRe.MatchString(t.BotToken) {
+			fail("bot_token of storage target %q is not a bot token", t.Name)
+		}
+		if t.MaxBytes < 0 {
+			fail("invalid max_bytes %d for storage target %q", t.MaxBytes, t.Name)
+		}
+	}
+	for _, folder := range slices.Sorted(maps.Keys(c.FolderTargets)) {
+		if target := c.FolderTargets[folder]; !seen[target] {
+			fail("folder_targets sends %q to unknown storage target %q", folder, target)
+		}
+	}
+	if c.ReplicationFactor < 0 || c.ReplicationFactor > max(len(c.Targets), 1) {
+		fail("replication_factor %d needs at least as many storage targets", c.ReplicationFactor)
+	}
+	if c.ErasureDataShards != 0 || c.ErasureParityShards != 0 {
+		if _, err := ingestion.NewErasure(c.ErasureDataShards, c.ErasureParityShards); err != nil {
+			fail("%w", err)
+		}
+		if c.ReplicationFactor > 1 {
+			fail("erasure coding and replication_factor > 1 cannot be combined")
+		}
+	}
+	if c.TrashRetentionDays < 0 {
+		fail("invalid trash_retention_days %d", c.TrashRetentionDays)
+	}
+	if c.GCGraceDays < 0 {
+		fail("invalid gc_grace_days %d", c.GCGraceDays)
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,431 @@
+package davfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+
+	"golang.org/x/net/webdav"
+)
+
+type Files interface {
+	PutFile(ctx context.Context, filePath, name, chatID string, onProgress telegram.ProgressFn) (*model.FileRecord, error)
+	Open(ctx context.Context, rec *model.FileRecord) (telegram.Content, error)
+	DeleteFile(ctx context.Context, name, chatID string) error
+	RenameFile(ctx context.Context, oldName, newName, chatID string) error
+	DeleteDirectory(ctx context.Context, dir, chatID string, onProgress telegram.DirProgressFn) (*telegram.DirResult, error)
+	RenameDirectory(ctx context.Context, oldDir, newDir, chatID string, onProgress telegram.DirProgressFn) (*telegram.DirResult, error)
+}
+
+// FileSystem maps WebDAV paths onto record names. Directories are implied
+// by record names; empty ones created through MKCOL only live in memory
+// until a file is stored below them.
+type FileSystem struct {
+	store      metadata.Store
+	files      Files
+	chatID     string
+	stagingDir string
+
+	mu   sync.Mutex
+	dirs map[string]bool
+}
+
+var _ webdav.FileSystem = (*FileSystem)(nil)
+
+func NewFileSystem(store metadata.Store, files Files, chatID, stagingDir string) (*FileSystem, error) {
+	if err := os.MkdirAll(stagingDir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileSystem{
+		store:      store,
+		files:      files,
+		chatID:     chatID,
+		stagingDir: stagingDir,
+		dirs:       make(map[string]bool),
+	}, nil
+}
+
+func clean(name string) string {
+	return strings.Trim(path.Clean("/"+name), "/")
+}
+
+func (fsys *FileSystem) isDir(recs []*model.FileRecord, name string) bool {
+	if name == "" || metadata.IsDir(recs, name) {
+		return true
+	}
+
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	return fsys.dirs[name]
+}
+
+func (fsys *FileSystem) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	name = clean(name)
+	recs, err := fsys.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	if name == "" || fsys.isDir(recs, name) {
+		return os.ErrExist
+	}
+	if _, err := fsys.store.Get(ctx, name); err == nil {
+		return os.ErrExist
+	}
+	if !fsys.isDir(recs, parent(name)) {
+		return os.ErrNotExist
+	}
+
+	fsys.mu.Lock()
+	fsys.dirs[name] = true
+	fsys.mu.Unlock()
+	return nil
+}
+
+func parent(name string) string {
+	if dir := path.Dir(name); dir != "." {
+		return dir
+	}
+	return ""
+}
+
+func (fsys *FileSystem) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	name = clean(name)
+	if rec, err := fsys.store.Get(ctx, name); err == nil {
+		return newFileInfo(rec), nil
+	} else if !errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+
+	recs, err := fsys.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if fsys.isDir(recs, name) {
+		return dirInfo(name), nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (fsys *FileSystem) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	name = clean(name)
+
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if name == "" {
+			return nil, os.ErrPermission
+		}
+		recs, err := fsys.store.List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if metadata.IsDir(recs, name) {
+			return nil, os.ErrPermission
+		}
+		if !fsys.isDir(recs, parent(name)) {
+			return nil, os.ErrNotExist
+		}
+		return fsys.create(ctx, name)
+	}
+
+	rec, err := fsys.store.Get(ctx, name)
+	if err == nil {
+		content, err := fsys.files.Open(ctx, rec)
+		if err != nil {
+			return nil, err
+		}
+		return &readFile{Content: content, info: newFileInfo(rec)}, nil
+	} else if !errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+
+	recs, err := fsys.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !fsys.isDir(recs, name) {
+		return nil, os.ErrNotExist
+	}
+
+	prefix := ""
+	if name != "" {
+		prefix = name + "/"
+	}
+	var infos []fs.FileInfo
+	seen := make(map[string]bool)
+	for _, e := range metadata.ListDir(recs, prefix) {
+		seen[e.Name] = true
+		if e.IsDir() {
+			infos = append(infos, dirInfo(prefix+e.Name))
+		} else {
+			infos = append(infos, newFileInfo(e.Record))
+		}
+	}
+	fsys.mu.Lock()
+	for dir := range fsys.dirs {
+		if parent(dir) == name && !seen[path.Base(dir)] {
+			infos = append(infos, dirInfo(dir))
+		}
+	}
+	fsys.mu.Unlock()
+
+	return &dirFile{info: dirInfo(name), entries: infos}, nil
+}
+
+func (fsys *FileSystem) create(ctx context.Context, name string) (webdav.File, error) {
+	dir, err := os.MkdirTemp(fsys.stagingDir, "put-")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filepath.Join(dir, path.Base(name)))
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &writeFile{File: f, fsys: fsys, ctx: ctx, name: name, dir: dir}, nil
+}
+
+func (fsys *FileSystem) commit(ctx context.Context, stagedPath, name string) error {
+	if _, err := fsys.files.PutFile(ctx, stagedPath, name, fsys.chatID, nil); err != nil {
+		return err
+	}
+	fsys.forgetDirs(name)
+	return nil
+}
+
+func (fsys *FileSystem) forgetDirs(name string) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	for dir := parent(name); dir != ""; dir = parent(dir) {
+		delete(fsys.dirs, dir)
+	}
+}
+
+func (fsys *FileSystem) under(ctx context.Context, name string) ([]*model.FileRecord, error) {
+	recs, err := fsys.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*model.FileRecord
+	for _, rec := range recs {
+		if name == "" || strings.HasPrefix(rec.Name, name+"/") {
+			out = append(out, rec)
+		}
+	}
+	return out, nil
+}
+
+func (fsys *FileSystem) RemoveAll(ctx context.Context, name string) error {
+	name = clean(name)
+	if name == "" {
+		return os.ErrPermission
+	}
+
+	if _, err := fsys.store.Get(ctx, name); err == nil {
+		return fsys.files.DeleteFile(ctx, name, fsys.chatID)
+	}
+
+	recs, err := fsys.under(ctx, name)
+	if err != nil {
+		return err
+	}
+	if len(recs) > 0 {
+		if err := dirError(fsys.files.DeleteDirectory(ctx, name, fsys.chatID, nil)); err != nil {
+			return err
+		}
+	}
+
+	fsys.mu.Lock()
+	for dir := range fsys.dirs {
+		if dir == name || strings.HasPrefix(dir, name+"/") {
+			delete(fsys.dirs, dir)
+		}
+	}
+	fsys.mu.Unlock()
+
+	return nil
+}
+
+func (fsys *FileSystem) Rename(ctx context.Context, oldName, newName string) error {
+	oldName, newName = clean(oldName), clean(newName)
+	if oldName == "" || newName == "" {
+		return os.ErrPermission
+	}
+
+	if _, err := fsys.store.Get(ctx, oldName); err == nil {
+		return fsys.files.RenameFile(ctx, oldName, newName, fsys.chatID)
+	}
+
+	recs, err := fsys.under(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	if len(recs) > 0 {
+		if err := dirError(fsys.files.RenameDirectory(ctx, oldName, newName, fsys.chatID, nil)); err != nil {
+			return err
+		}
+	}
+
+	fsys.mu.Lock()
+	for dir := range fsys.dirs {
+		if dir == oldName || strings.HasPrefix(dir, oldName+"/") {
+			delete(fsys.dirs, dir)
+			fsys.dirs[newName+strings.TrimPrefix(dir, oldName)] = true
+		}
+	}
+	fsys.mu.Unlock()
+
+	return nil
+}
+
+// dirError reports the files a directory operation could not handle. The
+// files it did handle stay where they are now.
+func dirError(result *telegram.DirResult, err error) error {
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(result.Failed)) {
+		errs = append(errs, fmt.Errorf("%s: %s", name, result.Failed[name]))
+	}
+	return errors.Join(errs...)
+}
+
+type fileInfo struct {
+	name     string
+	size     int64
+	modTime  time.Time
+	dir      bool
+	checksum string
+}
+
+func newFileInfo(rec *model.FileRecord) *fileInfo {
+	return &fileInfo{
+		name:     path.Base(rec.Name),
+		size:     rec.Size,
+		modTime:  rec.UploadedAt,
+		checksum: rec.Checksum,
+	}
+}
+
+func dirInfo(name string) *fileInfo {
+	return &fileInfo{name: path.Base("/" + name), dir: true}
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() any           { return nil }
+
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.dir {
+		return os.ModeDir | 0o755
+	}
+	return 0o644
+}
+
+// ContentType keeps PROPFIND from downloading the first chunk of every
+// cloud file just to sniff it.
+func (fi *fileInfo) ContentType(ctx context.Context) (string, error) {
+	if ctype := mime.TypeByExtension(path.Ext(fi.name)); ctype != "" {
+		return ctype, nil
+	}
+	return "application/octet-stream", nil
+}
+
+func (fi *fileInfo) ETag(ctx context.Context) (string, error) {
+	if fi.checksum == "" {
+		return "", webdav.ErrNotImplemented
+	}
+	return `"` + fi.checksum + `"`, nil
+}
+
+type readFile struct {
+	telegram.Content
+	info *fileInfo
+}
+
+func (f *readFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *readFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *readFile) Write(p []byte) (int, error) {
+	return 0, os.ErrPermission
+}
+
+type dirFile struct {
+	info    *fileInfo
+	entries []fs.FileInfo
+	pos     int
+}
+
+func (f *dirFile) Close() error                                 { return nil }
+func (f *dirFile) Read(p []byte) (int, error)                   { return 0, os.ErrInvalid }
+func (f *dirFile) Write(p []byte) (int, error)                  { return 0, os.ErrInvalid }
+func (f *dirFile) Seek(offset int64, whence int) (int64, error) { return 0, os.ErrInvalid }
+func (f *dirFile) Stat() (fs.FileInfo, error)                   { return f.info, nil }
+
+func (f *dirFile) Readdir(count int) ([]fs.FileInfo, error) {
+	rest := f.entries[f.pos:]
+	if count <= 0 {
+		f.pos = len(f.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(rest) {
+		count = len(rest)
+	}
+	f.pos += count
+	return rest[:count], nil
+}
+
+type writeFile struct {
+	*os.File
+	fsys *FileSystem
+	ctx  context.Context
+	name string
+	dir  string
+}
+
+func (f *writeFile) Readdir(count int) ([]fs.FileInfo, error) {
+	return nil, os.ErrInvalid
+}
+
+func (f *writeFile) Stat() (fs.FileInfo, error) {
+	info, err := f.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{name: path.Base(f.name), size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+func (f *writeFile) Close() error {
+	defer os.RemoveAll(f.dir)
+
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	return f.fsys.commit(f.ctx, f.File.Name(), f.name)
+}
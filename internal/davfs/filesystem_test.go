@@ -0,0 +1,232 @@
+package davfs
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+)
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+type fakeFiles struct {
+	store    metadata.Store
+	contents map[string][]byte
+	dirOps   int
+}
+
+func (f *fakeFiles) PutFile(ctx context.Context, filePath, name, chatID string, onProgress telegram.ProgressFn) (*model.FileRecord, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	f.store.Delete(ctx, name)
+	rec := &model.FileRecord{Name: name, State: model.StateCloud, Size: int64(len(data)), UploadedAt: time.Now()}
+	if err := f.store.Create(ctx, rec); err != nil {
+		return nil, err
+	}
+	f.contents[name] = data
+	return rec, nil
+}
+
+func (f *fakeFiles) Open(ctx context.Context, rec *model.FileRecord) (telegram.Content, error) {
+	return nopCloser{bytes.NewReader(f.contents[rec.Name])}, nil
+}
+
+func (f *fakeFiles) DeleteFile(ctx context.Context, name, chatID string) error {
+	delete(f.contents, name)
+	return f.store.Delete(ctx, name)
+}
+
+func (f *fakeFiles) RenameFile(ctx context.Context, oldName, newName, chatID string) error {
+	rec, err := f.store.Get(ctx, oldName)
+	if err != nil {
+		return err
+	}
+	f.store.Delete(ctx, oldName)
+	rec.Name = newName
+	f.contents[newName] = f.contents[oldName]
+	delete(f.contents, oldName)
+	return f.store.Create(ctx, rec)
+}
+
+func (f *fakeFiles) DeleteDirectory(ctx context.Context, dir, chatID string, onProgress telegram.DirProgressFn) (*telegram.DirResult, error) {
+	return f.eachUnder(ctx, dir, func(name string) error {
+		return f.DeleteFile(ctx, name, chatID)
+	})
+}
+
+func (f *fakeFiles) RenameDirectory(ctx context.Context, oldDir, newDir, chatID string, onProgress telegram.DirProgressFn) (*telegram.DirResult, error) {
+	return f.eachUnder(ctx, oldDir, func(name string) error {
+		return f.RenameFile(ctx, name, newDir+strings.TrimPrefix(name, oldDir), chatID)
+	})
+}
+
+func (f *fakeFiles) eachUnder(ctx context.Context, dir string, op func(name string) error) (*telegram.DirResult, error) {
+	f.dirOps++
+	recs, err := f.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := &telegram.DirResult{}
+	for _, rec := range metadata.Under(recs, dir) {
+		if err := op(rec.Name); err != nil {
+			return nil, err
+		}
+		result.Done = append(result.Done, rec.Name)
+	}
+	return result, nil
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *fakeFiles) {
+	t.Helper()
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	files := &fakeFiles{store: store, contents: make(map[string][]byte)}
+	fsys, err := NewFileSystem(store, files, "1", filepath.Join(tmp, "staging"))
+	if err != nil {
+		t.Fatalf("NewFileSystem: %v", err)
+	}
+
+	srv := httptest.NewServer(NewServer("", "user", "secret", fsys).Handler())
+	t.Cleanup(srv.Close)
+	return srv, files
+}
+
+func do(t *testing.T, srv *httptest.Server, method, path string, body string, headers map[string]string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.SetBasicAuth("user", "secret")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestWebDAV_RequiresAuth(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp, err := srv.Client().Get(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d; want 401", resp.StatusCode)
+	}
+}
+
+func TestWebDAV_PutListGetMoveDelete(t *testing.T) {
+	srv, files := newTestServer(t)
+
+	if resp := do(t, srv, "MKCOL", "/docs", "", nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MKCOL status = %d; want 201", resp.StatusCode)
+	}
+	if resp := do(t, srv, http.MethodPut, "/docs/a.txt", "hello world", nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("PUT status = %d; want 201", resp.StatusCode)
+	}
+	if got := string(files.contents["docs/a.txt"]); got != "hello world" {
+		t.Errorf("uploaded content = %q; want %q", got, "hello world")
+	}
+
+	resp := do(t, srv, "PROPFIND", "/docs/", "", map[string]string{"Depth": "1"})
+	if resp.StatusCode != http.StatusMultiStatus {
+		t.Fatalf("PROPFIND status = %d; want 207", resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "/docs/a.txt") {
+		t.Errorf("PROPFIND body does not list a.txt: %s", body)
+	}
+
+	resp = do(t, srv, http.MethodGet, "/docs/a.txt", "", map[string]string{"Range": "bytes=6-10"})
+	body, _ = io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "world" {
+		t.Errorf("ranged GET = %d %q; want 206 %q", resp.StatusCode, body, "world")
+	}
+
+	if resp := do(t, srv, http.MethodPut, "/docs/a.txt", "replaced", nil); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("overwrite PUT status = %d; want 201", resp.StatusCode)
+	}
+	if got := string(files.contents["docs/a.txt"]); got != "replaced" {
+		t.Errorf("overwritten content = %q; want %q", got, "replaced")
+	}
+	if len(files.contents) != 1 {
+		t.Errorf("stored files = %d; want 1", len(files.contents))
+	}
+
+	resp = do(t, srv, "MOVE", "/docs/a.txt", "", map[string]string{"Destination": srv.URL + "/b.txt"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MOVE status = %d; want 201", resp.StatusCode)
+	}
+	if _, ok := files.contents["b.txt"]; !ok {
+		t.Errorf("expected record renamed to b.txt, have %v", files.contents)
+	}
+
+	if resp := do(t, srv, http.MethodDelete, "/b.txt", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d; want 204", resp.StatusCode)
+	}
+	if len(files.contents) != 0 {
+		t.Errorf("expected no stored files after DELETE, have %v", files.contents)
+	}
+}
+
+func TestWebDAV_MoveAndDeleteDirectory(t *testing.T) {
+	srv, files := newTestServer(t)
+
+	for _, dir := range []string{"/docs", "/docs/sub"} {
+		if resp := do(t, srv, "MKCOL", dir, "", nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("MKCOL %s status = %d; want 201", dir, resp.StatusCode)
+		}
+	}
+	for _, name := range []string{"/docs/a.txt", "/docs/sub/b.txt"} {
+		if resp := do(t, srv, http.MethodPut, name, name, nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("PUT %s status = %d; want 201", name, resp.StatusCode)
+		}
+	}
+
+	resp := do(t, srv, "MOVE", "/docs", "", map[string]string{"Destination": srv.URL + "/moved"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("MOVE status = %d; want 201", resp.StatusCode)
+	}
+	for _, name := range []string{"moved/a.txt", "moved/sub/b.txt"} {
+		if _, ok := files.contents[name]; !ok {
+			t.Errorf("%s missing after MOVE, have %v", name, files.contents)
+		}
+	}
+
+	if resp := do(t, srv, http.MethodDelete, "/moved", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE status = %d; want 204", resp.StatusCode)
+	}
+	if len(files.contents) != 0 {
+		t.Errorf("expected no stored files after DELETE, have %v", files.contents)
+	}
+	if files.dirOps != 2 {
+		t.Errorf("ran %d directory operations; want one each for MOVE and DELETE", files.dirOps)
+	}
+}
@@ -0,0 +1,77 @@
+package davfs
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+
+	"golang.org/x/net/webdav"
+)
+
+type Server struct {
+	addr     string
+	user     string
+	password string
+	handler  *webdav.Handler
+	srv      *http.Server
+}
+
+func NewServer(addr, user, password string, fsys webdav.FileSystem) *Server {
+	s := &Server{
+		addr:     addr,
+		user:     user,
+		password: password,
+		handler: &webdav.Handler{
+			FileSystem: fsys,
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					log.Printf("webdav %s %s: %v", r.Method, r.URL.Path, err)
+				}
+			},
+		},
+	}
+	s.srv = &http.Server{Addr: addr, Handler: s.Handler()}
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(user), []byte(s.user)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(s.password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="tstore"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		s.handler.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) Start() error {
+	if s.user == "" || s.password == "" {
+		return errors.New("webdav requires a user and password")
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen on %q: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("webdav server stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
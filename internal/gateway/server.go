@@ -0,0 +1,163 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"tstore/internal/metadata"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+)
+
+const sniffLen = 512
+
+// Files opens the content of a record, from the sync folder when the file
+// is local and from its chunks otherwise.
+type Files interface {
+	Open(ctx context.Context, rec *model.FileRecord) (telegram.Content, error)
+}
+
+type Server struct {
+	addr  string
+	store metadata.Store
+	files Files
+	srv   *http.Server
+}
+
+func New(addr string, store metadata.Store, files Files) *Server {
+	s := &Server{
+		addr:  addr,
+		store: store,
+		files: files,
+	}
+	s.srv = &http.Server{Addr: addr, Handler: s.Handler()}
+	return s
+}
+
+func ValidateAddr(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if !isLoopback(host) {
+		return fmt.Errorf("host %q is not a loopback address", host)
+	}
+	return nil
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s *Server) Start() error {
+	if err := ValidateAddr(s.addr); err != nil {
+		return fmt.Errorf("invalid gateway address %q: %w", s.addr, err)
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen on %q: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("gateway stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// URL returns the stable address under which name is served.
+func (s *Server) URL(name string) string {
+	segments := strings.Split(name, "/")
+	for i, seg := range segments {
+		segments[i] = url.PathEscape(seg)
+	}
+	return fmt.Sprintf("http://%s/files/%s", s.addr, strings.Join(segments, "/"))
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /files/{name...}", s.serveFile)
+	return rejectForeignHosts(mux)
+}
+
+// rejectForeignHosts guards against DNS rebinding: browsers will happily
+// send requests for attacker-controlled names to 127.0.0.1.
+func rejectForeignHosts(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !isLoopback(strings.Trim(host, "[]")) {
+			http.Error(w, "forbidden host", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	rec, err := s.store.Get(r.Context(), name)
+	if errors.Is(err, model.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	content, err := s.files.Open(r.Context(), rec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer content.Close()
+
+	ctype := mime.TypeByExtension(path.Ext(rec.Name))
+	if ctype == "" {
+		ctype, err = sniff(content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", ctype)
+	if rec.Checksum != "" {
+		w.Header().Set("ETag", `"`+rec.Checksum+`"`)
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	http.ServeContent(w, r, path.Base(rec.Name), rec.UploadedAt, content)
+}
+
+func sniff(content io.ReadSeeker) (string, error) {
+	buf := make([]byte, sniffLen)
+	n, err := io.ReadFull(content, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", fmt.Errorf("sniff content type: %w", err)
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
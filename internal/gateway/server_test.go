@@ -0,0 +1,132 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+)
+
+type fakeChunks struct {
+	size int64
+	data []string
+}
+
+func (f *fakeChunks) ReadChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error) {
+	if index >= len(f.data) {
+		return nil, fmt.Errorf("chunk %d out of range", index)
+	}
+	return []byte(f.data[index]), nil
+}
+
+func (f *fakeChunks) ChunkSizeOf(rec *model.FileRecord) int64 {
+	return f.size
+}
+
+func (f *fakeChunks) Open(ctx context.Context, rec *model.FileRecord) (telegram.Content, error) {
+	return telegram.NewFileReader(ctx, f, rec), nil
+}
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+
+	recs := []*model.FileRecord{
+		{Name: "doc.pdf", State: model.StateCloud, Size: 10, Checksum: "abc", UploadedAt: time.Now()},
+		{Name: "notes", State: model.StateCloud, Size: 10, Checksum: "def", UploadedAt: time.Now()},
+	}
+	for _, rec := range recs {
+		if err := store.Create(context.Background(), rec); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	chunks := &fakeChunks{size: 4, data: []string{"0123", "4567", "89"}}
+	return New("127.0.0.1:0", store, chunks)
+}
+
+func TestServer_RangeRequest(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://127.0.0.1/files/doc.pdf", nil)
+	req.Header.Set("Range", "bytes=3-6")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusPartialContent)
+	}
+	body, _ := io.ReadAll(rec.Body)
+	if string(body) != "3456" {
+		t.Errorf("body = %q; want %q", body, "3456")
+	}
+	if got := rec.Header().Get("Content-Type"); got != "application/pdf" {
+		t.Errorf("Content-Type = %q; want application/pdf", got)
+	}
+	if got := rec.Header().Get("ETag"); got != `"abc"` {
+		t.Errorf("ETag = %q; want %q", got, `"abc"`)
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 3-6/10" {
+		t.Errorf("Content-Range = %q; want %q", got, "bytes 3-6/10")
+	}
+}
+
+func TestServer_SniffAndConditional(t *testing.T) {
+	s := newTestServer(t)
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/files/notes", nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want 200", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q; want sniffed text/plain", got)
+	}
+	if body := rec.Body.String(); body != "0123456789" {
+		t.Errorf("body = %q; want full content", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/files/notes", nil)
+	req.Header.Set("If-None-Match", `"def"`)
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("status = %d; want 304", rec.Code)
+	}
+}
+
+func TestServer_NotFoundAndForeignHost(t *testing.T) {
+	s := newTestServer(t)
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://127.0.0.1/files/missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("missing file status = %d; want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "http://evil.example/files/notes", nil))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("foreign host status = %d; want 403", rec.Code)
+	}
+}
+
+func TestServer_URL(t *testing.T) {
+	s := New("127.0.0.1:8765", nil, nil)
+	if got, want := s.URL("dir/my file.mp4"), "http://127.0.0.1:8765/files/dir/my%20file.mp4"; got != want {
+		t.Errorf("URL = %q; want %q", got, want)
+	}
+}
@@ -0,0 +1,281 @@
+package index
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"tstore/internal/config"
+	"tstore/pkg/model"
+)
+
+// ContentIndex maps the words in stored files to the files containing them.
+// Entries are keyed by content checksum rather than name, so renames, trash
+// and restore need no bookkeeping, identical files are indexed once, and
+// offloaded files stay searchable. Search resolves checksums back to
+// records.
+type ContentIndex struct {
+	path string
+	mu   sync.Mutex
+	// terms is the inverted index: word to checksum to occurrences.
+	terms map[string]map[string]int
+	// docs lists the words of each checksum, to remove it again.
+	docs map[string][]string
+	// changes counts modifications, so callers can tell whether a copy they
+	// made is still current.
+	changes uint64
+}
+
+type indexFile struct {
+	Terms map[string]map[string]int `json:"terms"`
+	// Docs holds every indexed checksum, including those without words.
+	Docs []string `json:"docs"`
+}
+
+func NewContentIndex(path string) (*ContentIndex, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+
+	ix := &ContentIndex{
+		path:  path,
+		terms: make(map[string]map[string]int),
+		docs:  make(map[string][]string),
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return ix, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := ix.Load(context.Background(), f); err != nil {
+		return nil, fmt.Errorf("load content index: %w", err)
+	}
+	return ix, nil
+}
+
+func NewDefaultContentIndex() (*ContentIndex, error) {
+	cfgPath, err := config.ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	return NewContentIndex(filepath.Join(filepath.Dir(cfgPath), "content-index.json"))
+}
+
+func (ix *ContentIndex) Path() string {
+	return ix.path
+}
+
+// Load replaces the index with one read from reader, such as a backup.
+func (ix *ContentIndex) Load(ctx context.Context, reader io.ReadCloser) error {
+	defer reader.Close()
+
+	var file indexFile
+	if err := json.NewDecoder(reader).Decode(&file); err != nil {
+		return err
+	}
+
+	docs := make(map[string][]string, len(file.Docs))
+	for _, sum := range file.Docs {
+		docs[sum] = nil
+	}
+	for word, postings := range file.Terms {
+		for sum := range postings {
+			docs[sum] = append(docs[sum], word)
+		}
+	}
+	if file.Terms == nil {
+		file.Terms = make(map[string]map[string]int)
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.terms, ix.docs = file.Terms, docs
+	ix.changes++
+	return nil
+}
+
+// Save writes the index to its path.
+func (ix *ContentIndex) Save() error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.save()
+}
+
+func (ix *ContentIndex) save() error {
+	file := indexFile{Terms: ix.terms, Docs: make([]string, 0, len(ix.docs))}
+	for sum := range ix.docs {
+		file.Docs = append(file.Docs, sum)
+	}
+	sort.Strings(file.Docs)
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	tmp := ix.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, ix.path)
+}
+
+// Len returns the number of indexed checksums.
+func (ix *ContentIndex) Len() int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return len(ix.docs)
+}
+
+// Changes returns a counter that grows whenever the index is modified.
+func (ix *ContentIndex) Changes() uint64 {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.changes
+}
+
+// Has reports whether content with checksum has been indexed.
+func (ix *ContentIndex) Has(checksum string) bool {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	_, ok := ix.docs[checksum]
+	return ok
+}
+
+// IndexFile extracts the words of r, the content of a file named name with
+// the given checksum, and adds them to the index. Files that are not
+// Indexable and content already indexed are skipped.
+func (ix *ContentIndex) IndexFile(checksum, name string, r io.Reader) error {
+	if !Indexable(name) || ix.Has(checksum) {
+		return nil
+	}
+
+	text, err := Extract(name, r)
+	if err != nil {
+		return fmt.Errorf("extract text of %q: %w", name, err)
+	}
+	counts := make(map[string]int)
+	for _, w := range Tokenize(text) {
+		counts[w]++
+	}
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	words := make([]string, 0, len(counts))
+	for w, n := range counts {
+		if ix.terms[w] == nil {
+			ix.terms[w] = make(map[string]int)
+		}
+		ix.terms[w][checksum] = n
+		words = append(words, w)
+	}
+	ix.docs[checksum] = words
+	ix.changes++
+	return ix.save()
+}
+
+// Prune drops every checksum that keep does not contain, such as content
+// whose last record has been purged, and returns how many it dropped.
+func (ix *ContentIndex) Prune(keep map[string]bool) (int, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	dropped := 0
+	for sum, words := range ix.docs {
+		if keep[sum] {
+			continue
+		}
+		for _, w := range words {
+			delete(ix.terms[w], sum)
+			if len(ix.terms[w]) == 0 {
+				delete(ix.terms, w)
+			}
+		}
+		delete(ix.docs, sum)
+		dropped++
+	}
+	if dropped == 0 {
+		return 0, nil
+	}
+	ix.changes++
+	return dropped, ix.save()
+}
+
+// Search returns the records among recs whose content contains every word
+// of query, most occurrences first. A word ending in "*" matches any word
+// with that prefix.
+func (ix *ContentIndex) Search(recs []*model.FileRecord, query string) []*model.FileRecord {
+	var scores map[string]int
+	for _, field := range strings.Fields(query) {
+		prefix := strings.HasSuffix(field, "*")
+		words := Tokenize(field)
+		if len(words) == 0 {
+			continue
+		}
+
+		for i, w := range words {
+			matches := ix.lookup(w, prefix && i == len(words)-1)
+			if scores == nil {
+				scores = matches
+				continue
+			}
+			for sum := range scores {
+				if n, ok := matches[sum]; ok {
+					scores[sum] += n
+				} else {
+					delete(scores, sum)
+				}
+			}
+		}
+	}
+	if len(scores) == 0 {
+		return nil
+	}
+
+	var out []*model.FileRecord
+	for _, rec := range recs {
+		if scores[rec.Checksum] > 0 {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		si, sj := scores[out[i].Checksum], scores[out[j].Checksum]
+		if si != sj {
+			return si > sj
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// lookup returns the occurrences of word, or of every word starting with it,
+// per checksum.
+func (ix *ContentIndex) lookup(word string, prefix bool) map[string]int {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	out := make(map[string]int)
+	add := func(postings map[string]int) {
+		for sum, n := range postings {
+			out[sum] += n
+		}
+	}
+	if !prefix {
+		add(ix.terms[word])
+		return out
+	}
+	for w, postings := range ix.terms {
+		if strings.HasPrefix(w, word) {
+			add(postings)
+		}
+	}
+	return out
+}
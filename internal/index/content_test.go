@@ -0,0 +1,95 @@
+package index
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tstore/pkg/model"
+)
+
+func names(recs []*model.FileRecord) []string {
+	var out []string
+	for _, rec := range recs {
+		out = append(out, rec.Name)
+	}
+	return out
+}
+
+func TestExtract(t *testing.T) {
+	page := `<html><head><style>body{color:red}</style></head>
+<body><h1>Launch &amp; plan</h1><script>var secret = 1;</script></body></html>`
+	text, err := Extract("page.html", strings.NewReader(page))
+	if err != nil {
+		t.Fatalf("Extract: %v", err)
+	}
+	if got := Tokenize(text); !reflect.DeepEqual(got, []string{"launch", "plan"}) {
+		t.Errorf("html words = %v; want [launch plan]", got)
+	}
+
+	if text, _ := Extract("blob.txt", strings.NewReader("a\x00b")); text != "" {
+		t.Errorf("binary content extracted as %q", text)
+	}
+	if Indexable("photo.jpg") || !Indexable("src/Main.GO") || !Indexable("Makefile") {
+		t.Error("Indexable misjudged a file type")
+	}
+}
+
+func TestContentIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	ix, err := NewContentIndex(path)
+	if err != nil {
+		t.Fatalf("NewContentIndex: %v", err)
+	}
+
+	files := map[string]string{
+		"a": "The quarterly budget, budget and more budget.",
+		"b": "Budget review notes for the quarter.",
+		"c": "Unrelated shopping list",
+	}
+	for sum, text := range files {
+		if err := ix.IndexFile(sum, sum+".md", strings.NewReader(text)); err != nil {
+			t.Fatalf("IndexFile(%s): %v", sum, err)
+		}
+	}
+	if err := ix.IndexFile("d", "d.png", strings.NewReader("budget")); err != nil || ix.Has("d") {
+		t.Errorf("non-text file indexed: %v", err)
+	}
+
+	recs := []*model.FileRecord{
+		{Name: "notes/review.md", Checksum: "b"},
+		{Name: "plan.md", Checksum: "a"},
+		{Name: "copy-of-plan.md", Checksum: "a"},
+		{Name: "list.md", Checksum: "c"},
+	}
+	for query, want := range map[string][]string{
+		"budget":        {"copy-of-plan.md", "plan.md", "notes/review.md"},
+		"BUDGET review": {"notes/review.md"},
+		"quarter*":      {"copy-of-plan.md", "notes/review.md", "plan.md"},
+		"quarter":       {"notes/review.md"},
+		"missing":       nil,
+		"":              nil,
+	} {
+		if got := names(ix.Search(recs, query)); !reflect.DeepEqual(got, want) {
+			t.Errorf("Search(%q) = %v; want %v", query, got, want)
+		}
+	}
+
+	if n, err := ix.Prune(map[string]bool{"a": true, "c": true}); err != nil || n != 1 {
+		t.Fatalf("Prune = %d, %v; want 1", n, err)
+	}
+	reopened, err := NewContentIndex(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	if reopened.Len() != 2 || reopened.Has("b") {
+		t.Errorf("reopened index has %d entries; want a and c", reopened.Len())
+	}
+	if got := names(reopened.Search(recs, "budget")); !reflect.DeepEqual(got, []string{"copy-of-plan.md", "plan.md"}) {
+		t.Errorf("Search after prune = %v", got)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("temporary file left behind")
+	}
+}
@@ -0,0 +1,106 @@
+package index
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// MaxExtractBytes caps how much of a file is read for indexing. Words past
+// it are not searchable.
+const MaxExtractBytes = 4 << 20
+
+var htmlExts = map[string]bool{".html": true, ".htm": true, ".xhtml": true}
+
+var textExts = map[string]bool{
+	".txt": true, ".text": true, ".log": true, ".csv": true, ".tsv": true,
+	".md": true, ".markdown": true, ".rst": true, ".org": true, ".tex": true,
+	".json": true, ".yaml": true, ".yml": true, ".toml": true, ".ini": true,
+	".xml": true, ".svg": true, ".css": true, ".scss": true, ".sql": true,
+	".go": true, ".py": true, ".rb": true, ".rs": true, ".java": true,
+	".kt": true, ".swift": true, ".c": true, ".h": true, ".cc": true,
+	".cpp": true, ".hpp": true, ".cs": true, ".js": true, ".jsx": true,
+	".ts": true, ".tsx": true, ".mjs": true, ".php": true, ".lua": true,
+	".sh": true, ".bash": true, ".zsh": true, ".ps1": true, ".r": true,
+	".scala": true, ".hs": true, ".ex": true, ".exs": true, ".erl": true,
+	".clj": true, ".dart": true, ".vue": true, ".svelte": true,
+	".proto": true, ".graphql": true, ".mk": true, ".cmake": true,
+}
+
+var textNames = map[string]bool{
+	"makefile": true, "dockerfile": true, "readme": true, "license": true,
+}
+
+// Indexable reports whether the indexer extracts text from files named
+// name: plain text, Markdown, HTML and source code, judged by extension.
+func Indexable(name string) bool {
+	base := strings.ToLower(path.Base(name))
+	ext := path.Ext(base)
+	return textExts[ext] || htmlExts[ext] || textNames[base]
+}
+
+// Extract returns the text of an indexable file, with markup stripped from
+// HTML. Content with NUL bytes is taken to be binary and yields nothing.
+func Extract(name string, r io.Reader) (string, error) {
+	data, err := io.ReadAll(io.LimitReader(r, MaxExtractBytes))
+	if err != nil {
+		return "", err
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return "", nil
+	}
+	if htmlExts[strings.ToLower(path.Ext(name))] {
+		return htmlText(data), nil
+	}
+	return strings.ToValidUTF8(string(data), " "), nil
+}
+
+// htmlText keeps the text nodes of an HTML document, skipping scripts and
+// styles.
+func htmlText(data []byte) string {
+	var b strings.Builder
+	z := html.NewTokenizer(bytes.NewReader(data))
+	skip := 0
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return b.String()
+		case html.StartTagToken:
+			if name, _ := z.TagName(); isRawTag(name) {
+				skip++
+			}
+		case html.EndTagToken:
+			if name, _ := z.TagName(); isRawTag(name) && skip > 0 {
+				skip--
+			}
+		case html.TextToken:
+			if skip == 0 {
+				b.Write(z.Text())
+				b.WriteByte(' ')
+			}
+		}
+	}
+}
+
+func isRawTag(name []byte) bool {
+	return string(name) == "script" || string(name) == "style"
+}
+
+// Tokenize splits text into lower-cased words of letters and digits.
+// Single characters and very long runs, such as encoded data, are dropped.
+func Tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	out := words[:0]
+	for _, w := range words {
+		if n := len([]rune(w)); n >= 2 && n <= 64 {
+			out = append(out, w)
+		}
+	}
+	return out
+}
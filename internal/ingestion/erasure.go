@@ -0,0 +1,96 @@
+package ingestion
+
+import (
+	"fmt"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+// Erasure groups chunks into stripes of DataShards data chunks protected by
+// ParityShards parity chunks, any ParityShards of which may be lost. The
+// last stripe of a file may be short: its missing data chunks are treated
+// as zeros and never stored.
+type Erasure struct {
+	DataShards   int
+	ParityShards int
+	enc          reedsolomon.Encoder
+}
+
+func NewErasure(dataShards, parityShards int) (*Erasure, error) {
+	if dataShards < 1 || parityShards < 1 || dataShards+parityShards > 256 {
+		return nil, fmt.Errorf("invalid erasure layout %d+%d", dataShards, parityShards)
+	}
+
+	enc, err := reedsolomon.New(dataShards, parityShards)
+	if err != nil {
+		return nil, err
+	}
+	return &Erasure{DataShards: dataShards, ParityShards: parityShards, enc: enc}, nil
+}
+
+// Encode returns the parity chunks for a stripe of up to DataShards data
+// chunks. Every parity chunk is as long as the longest data chunk.
+func (e *Erasure) Encode(data [][]byte) ([][]byte, error) {
+	if len(data) == 0 || len(data) > e.DataShards {
+		return nil, fmt.Errorf("stripe has %d data chunks; want 1 to %d", len(data), e.DataShards)
+	}
+
+	size := 0
+	for _, d := range data {
+		size = max(size, len(d))
+	}
+
+	shards := make([][]byte, e.DataShards+e.ParityShards)
+	for i := range shards {
+		shards[i] = make([]byte, size)
+		if i < len(data) {
+			copy(shards[i], data[i])
+		}
+	}
+	if err := e.enc.Encode(shards); err != nil {
+		return nil, err
+	}
+	return shards[e.DataShards:], nil
+}
+
+// Reconstruct fills in the nil entries of data from the surviving data and
+// parity chunks of a stripe. sizes holds the real length of each data
+// chunk, zero for chunks past the end of the file; parity entries are nil
+// when unavailable.
+func (e *Erasure) Reconstruct(data, parity [][]byte, sizes []int) error {
+	if len(data) != e.DataShards || len(sizes) != e.DataShards || len(parity) != e.ParityShards {
+		return fmt.Errorf("stripe shape does not match erasure layout %d+%d", e.DataShards, e.ParityShards)
+	}
+
+	size := 0
+	for _, n := range sizes {
+		size = max(size, n)
+	}
+
+	shards := make([][]byte, e.DataShards+e.ParityShards)
+	for i, d := range data {
+		switch {
+		case sizes[i] == 0:
+			shards[i] = make([]byte, size)
+		case d != nil:
+			shards[i] = make([]byte, size)
+			copy(shards[i], d)
+		}
+	}
+	for i, p := range parity {
+		if p != nil && len(p) != size {
+			return fmt.Errorf("parity chunk %d has %d bytes; want %d", i, len(p), size)
+		}
+		shards[e.DataShards+i] = p
+	}
+
+	if err := e.enc.ReconstructData(shards); err != nil {
+		return err
+	}
+	for i := range data {
+		if data[i] == nil && sizes[i] > 0 {
+			data[i] = shards[i][:sizes[i]]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package ingestion
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestErasure_ReconstructShortStripe(t *testing.T) {
+	e, err := NewErasure(4, 2)
+	if err != nil {
+		t.Fatalf("NewErasure: %v", err)
+	}
+
+	// A final stripe with only two real chunks, the second one short.
+	data := [][]byte{[]byte("abcdef"), []byte("ghi")}
+	parity, err := e.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if len(parity) != 2 || len(parity[0]) != 6 {
+		t.Fatalf("parity shape = %d x %d; want 2 x 6", len(parity), len(parity[0]))
+	}
+
+	got := [][]byte{nil, nil, nil, nil}
+	if err := e.Reconstruct(got, parity, []int{6, 3, 0, 0}); err != nil {
+		t.Fatalf("Reconstruct: %v", err)
+	}
+	if !bytes.Equal(got[0], data[0]) || !bytes.Equal(got[1], data[1]) {
+		t.Errorf("reconstructed = %q; want %q", got[:2], data)
+	}
+}
+
+func TestErasure_ToleratesParityShardsLost(t *testing.T) {
+	e, err := NewErasure(3, 2)
+	if err != nil {
+		t.Fatalf("NewErasure: %v", err)
+	}
+	data := [][]byte{[]byte("1111"), []byte("2222"), []byte("3333")}
+	parity, err := e.Encode(data)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	sizes := []int{4, 4, 4}
+
+	got := [][]byte{nil, data[1], nil}
+	if err := e.Reconstruct(got, parity, sizes); err != nil {
+		t.Fatalf("Reconstruct with two data chunks lost: %v", err)
+	}
+	if !bytes.Equal(got[0], data[0]) || !bytes.Equal(got[2], data[2]) {
+		t.Errorf("reconstructed = %q", got)
+	}
+
+	got = [][]byte{nil, data[1], nil}
+	if err := e.Reconstruct(got, [][]byte{parity[0], nil}, sizes); err == nil {
+		t.Error("expected an error with more than ParityShards chunks lost")
+	}
+
+	if _, err := NewErasure(0, 2); err == nil {
+		t.Error("expected an error for zero data shards")
+	}
+}
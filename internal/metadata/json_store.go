@@ -7,7 +7,9 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
+	"time"
 	"tstore/internal/config"
 	"tstore/pkg/model"
 )
@@ -18,6 +20,7 @@ type JSONStore struct {
 	path    string
 	mu      sync.Mutex
 	records map[string]*model.FileRecord
+	trash   []*model.FileRecord
 }
 
 func NewJSONStore(path string) (*JSONStore, error) {
@@ -64,18 +67,17 @@ func (s *JSONStore) load() error {
 		return err
 	}
 
-	for _, rec := range list {
-		s.records[rec.Name] = rec
-	}
+	s.records, s.trash = splitTrash(list)
 
 	return nil
 }
 
 func (s *JSONStore) save() error {
-	list := make([]*model.FileRecord, 0, len(s.records))
+	list := make([]*model.FileRecord, 0, len(s.records)+len(s.trash))
 	for _, rec := range s.records {
 		list = append(list, rec)
 	}
+	list = append(list, s.trash...)
 
 	data, err := json.MarshalIndent(list, "", "  ")
 	if err != nil {
@@ -154,6 +156,25 @@ func (s *JSONStore) List(ctx context.Context) ([]*model.FileRecord, error) {
 	return out, nil
 }
 
+func (s *JSONStore) ListFiles(ctx context.Context, opts ListOptions) (*ListPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recs := make([]*model.FileRecord, 0, len(s.records))
+	for _, rec := range s.records {
+		recs = append(recs, rec)
+	}
+	page, err := Page(recs, opts)
+	if err != nil {
+		return nil, err
+	}
+	for i, rec := range page.Files {
+		copyRec := *rec
+		page.Files[i] = &copyRec
+	}
+	return page, nil
+}
+
 func (s *JSONStore) Path() string {
 	return s.path
 }
@@ -170,11 +191,107 @@ func (s *JSONStore) Load(ctx context.Context, reader io.ReadCloser) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.records = make(map[string]*model.FileRecord, len(list))
+	s.records, s.trash = splitTrash(list)
+	return nil
+}
+
+// splitTrash separates trashed records, which share the metadata file and
+// its backups with live ones but may reuse their names, both with live
+// records and with each other.
+func splitTrash(list []*model.FileRecord) (records map[string]*model.FileRecord, trash []*model.FileRecord) {
+	records = make(map[string]*model.FileRecord, len(list))
 	for _, rec := range list {
 		copyRec := *rec
-		s.records[copyRec.Name] = &copyRec
+		if copyRec.State == model.StateTrash {
+			trash = append(trash, &copyRec)
+		} else {
+			records[copyRec.Name] = &copyRec
+		}
 	}
+	return records, trash
+}
 
-	return nil
+func (s *JSONStore) Trash(ctx context.Context, name string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec, exists := s.records[name]
+	if !exists {
+		return ErrNotFound
+	}
+
+	delete(s.records, name)
+	rec.State = model.StateTrash
+	rec.TrashedAt = &at
+	s.trash = append(s.trash, rec)
+	return s.save()
+}
+
+// trashedAt is when rec was trashed, or the zero time for records trashed
+// before that was recorded.
+func trashedAt(rec *model.FileRecord) time.Time {
+	if rec.TrashedAt == nil {
+		return time.Time{}
+	}
+	return *rec.TrashedAt
+}
+
+// findTrash returns the index of the most recently trashed record called
+// name, or -1.
+func (s *JSONStore) findTrash(name string) int {
+	found := -1
+	for i, rec := range s.trash {
+		if rec.Name == name && (found < 0 || !trashedAt(rec).Before(trashedAt(s.trash[found]))) {
+			found = i
+		}
+	}
+	return found
+}
+
+func (s *JSONStore) Restore(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.findTrash(name)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if _, exists := s.records[name]; exists {
+		return ErrAlreadyExists
+	}
+
+	rec := s.trash[i]
+	s.trash = slices.Delete(s.trash, i, i+1)
+	rec.State = model.StateCloud
+	rec.TrashedAt = nil
+	s.records[name] = rec
+	return s.save()
+}
+
+func (s *JSONStore) Purge(ctx context.Context, name string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := slices.IndexFunc(s.trash, func(rec *model.FileRecord) bool {
+		return rec.Name == name && trashedAt(rec).Equal(at)
+	})
+	if i < 0 {
+		return ErrNotFound
+	}
+
+	s.trash = slices.Delete(s.trash, i, i+1)
+	return s.save()
+}
+
+func (s *JSONStore) ListTrash(ctx context.Context) ([]*model.FileRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := make([]*model.FileRecord, 0, len(s.trash))
+	for _, rec := range s.trash {
+		copyRec := *rec
+		out = append(out, &copyRec)
+	}
+
+	return out, nil
 }
@@ -122,3 +122,119 @@ func TestJSONStore_CRUD_List_Persistence(t *testing.T) {
 		t.Errorf("On reopen, record = %+v; want %+v", got1p, &rec1Updated)
 	}
 }
+
+func TestJSONStore_Trash(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "metadata.json")
+	store, err := NewJSONStore(storePath)
+	if err != nil {
+		t.Fatalf("NewJSONStore failed: %v", err)
+	}
+
+	ctx := context.Background()
+	at := time.Date(2025, 5, 6, 15, 30, 0, 0, time.UTC)
+	rec := &model.FileRecord{Name: "a.txt", State: model.StateLocal, Size: 3, ChunkIds: []string{"c1"}}
+	if err := store.Create(ctx, rec); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if err := store.Trash(ctx, "a.txt", at); err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	if _, err := store.Get(ctx, "a.txt"); err != ErrNotFound {
+		t.Errorf("Get after Trash: got %v; want ErrNotFound", err)
+	}
+	if list, _ := store.List(ctx); len(list) != 0 {
+		t.Errorf("List after Trash = %d records; want 0", len(list))
+	}
+
+	// The name is free again, and trashed records survive a reload.
+	if err := store.Create(ctx, rec); err != nil {
+		t.Fatalf("Create over trashed name failed: %v", err)
+	}
+	store, err = NewJSONStore(storePath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	trash, _ := store.ListTrash(ctx)
+	if len(trash) != 1 || trash[0].State != model.StateTrash || trash[0].TrashedAt == nil || !trash[0].TrashedAt.Equal(at) {
+		t.Fatalf("ListTrash = %+v; want a.txt trashed at %v", trash, at)
+	}
+
+	if err := store.Restore(ctx, "a.txt"); err != ErrAlreadyExists {
+		t.Errorf("Restore over live record: got %v; want ErrAlreadyExists", err)
+	}
+	if err := store.Delete(ctx, "a.txt"); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if err := store.Restore(ctx, "a.txt"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	got, err := store.Get(ctx, "a.txt")
+	if err != nil || got.State != model.StateCloud || got.TrashedAt != nil {
+		t.Errorf("Get after Restore = %+v, %v; want a cloud record", got, err)
+	}
+
+	if err := store.Trash(ctx, "a.txt", at); err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	if err := store.Purge(ctx, "a.txt", at); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	if err := store.Purge(ctx, "a.txt", at); err != ErrNotFound {
+		t.Errorf("second Purge: got %v; want ErrNotFound", err)
+	}
+}
+
+func TestJSONStore_TrashSameNameTwice(t *testing.T) {
+	storePath := filepath.Join(t.TempDir(), "meta.json")
+	store, err := NewJSONStore(storePath)
+	if err != nil {
+		t.Fatalf("NewJSONStore failed: %v", err)
+	}
+	ctx := context.Background()
+
+	first := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	second := first.Add(time.Hour)
+	for _, tt := range []struct {
+		at    time.Time
+		chunk string
+	}{{first, "old"}, {second, "new"}} {
+		if err := store.Create(ctx, &model.FileRecord{Name: "a.txt", State: model.StateCloud, ChunkIds: []string{tt.chunk}}); err != nil {
+			t.Fatalf("Create failed: %v", err)
+		}
+		if err := store.Trash(ctx, "a.txt", tt.at); err != nil {
+			t.Fatalf("Trash failed: %v", err)
+		}
+	}
+
+	// Both trashed records, and so both sets of chunks, survive a reload.
+	store, err = NewJSONStore(storePath)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	trash, _ := store.ListTrash(ctx)
+	if len(trash) != 2 {
+		t.Fatalf("ListTrash = %d records; want 2", len(trash))
+	}
+
+	if err := store.Purge(ctx, "a.txt", first); err != nil {
+		t.Fatalf("Purge failed: %v", err)
+	}
+	trash, _ = store.ListTrash(ctx)
+	if len(trash) != 1 || trash[0].ChunkIds[0] != "new" {
+		t.Fatalf("ListTrash after purging the older record = %+v; want the newer one", trash)
+	}
+
+	if err := store.Create(ctx, &model.FileRecord{Name: "a.txt", State: model.StateCloud, ChunkIds: []string{"old"}}); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := store.Trash(ctx, "a.txt", first); err != nil {
+		t.Fatalf("Trash failed: %v", err)
+	}
+	if err := store.Restore(ctx, "a.txt"); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if got, _ := store.Get(ctx, "a.txt"); got == nil || got.ChunkIds[0] != "new" {
+		t.Errorf("Restore brought back %+v; want the most recently trashed record", got)
+	}
+}
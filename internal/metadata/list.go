@@ -0,0 +1,195 @@
+package metadata
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"tstore/pkg/model"
+)
+
+type SortField string
+
+const (
+	SortName     SortField = "name"
+	SortSize     SortField = "size"
+	SortUploaded SortField = "uploaded_at"
+	SortState    SortField = "state"
+)
+
+var AllSortFields = []struct {
+	Value  SortField
+	TSName string
+}{
+	{SortName, "name"},
+	{SortSize, "size"},
+	{SortUploaded, "uploaded_at"},
+	{SortState, "state"},
+}
+
+// DefaultPageSize applies when ListOptions.Limit is not set, and
+// MaxPageSize caps it.
+const (
+	DefaultPageSize = 200
+	MaxPageSize     = 5000
+)
+
+var ErrInvalidCursor = errors.New("invalid list cursor")
+
+// ListOptions selects a page of records. Ties in the sort field are broken
+// by name, so the order is total and stable across pages.
+type ListOptions struct {
+	Sort SortField `json:"sort,omitempty"`
+	Desc bool      `json:"desc,omitempty"`
+	// Cursor is the NextCursor of the previous page; empty starts at the
+	// beginning. It must be used with the same sort and filters.
+	Cursor string `json:"cursor,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+	// State and Folder filter the records. Folder includes everything
+	// below it, not just its direct children.
+	State  model.FileState `json:"state,omitempty"`
+	Folder string          `json:"folder,omitempty"`
+}
+
+// ListPage is one page of records. Total counts every record matching the
+// filters. States and Bytes break the records in Folder down by state,
+// ignoring the State filter, so one call can fill a summary as well as the
+// list.
+type ListPage struct {
+	Files      []*model.FileRecord       `json:"files"`
+	NextCursor string                    `json:"next_cursor,omitempty"`
+	Total      int                       `json:"total"`
+	States     map[model.FileState]int   `json:"states"`
+	Bytes      map[model.FileState]int64 `json:"bytes"`
+}
+
+// cursor holds the sort keys of the last record of a page. Keeping the keys
+// rather than a position means records added or removed between calls
+// neither repeat nor skip others.
+type cursor struct {
+	Sort     SortField       `json:"s"`
+	Desc     bool            `json:"d,omitempty"`
+	Name     string          `json:"n"`
+	Size     int64           `json:"z,omitempty"`
+	Uploaded int64           `json:"u,omitempty"`
+	State    model.FileState `json:"t,omitempty"`
+}
+
+func (o ListOptions) Validate() error {
+	switch o.Sort {
+	case "", SortName, SortSize, SortUploaded, SortState:
+	default:
+		return fmt.Errorf("unknown sort field %q", o.Sort)
+	}
+	if o.Limit < 0 {
+		return fmt.Errorf("invalid limit %d", o.Limit)
+	}
+	return nil
+}
+
+// Page applies opts to recs, which it may reorder. It is the reference
+// implementation of Store.ListFiles for stores without a better index.
+func Page(recs []*model.FileRecord, opts ListOptions) (*ListPage, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Sort == "" {
+		opts.Sort = SortName
+	}
+	limit := opts.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	var after *model.FileRecord
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil || c.Sort != opts.Sort || c.Desc != opts.Desc {
+			return nil, ErrInvalidCursor
+		}
+		after = &model.FileRecord{Name: c.Name, Size: c.Size, UploadedAt: time.Unix(0, c.Uploaded), State: c.State}
+	}
+
+	prefix := strings.Trim(opts.Folder, "/") + "/"
+	page := &ListPage{
+		Files:  []*model.FileRecord{},
+		States: make(map[model.FileState]int),
+		Bytes:  make(map[model.FileState]int64),
+	}
+	matched := recs[:0]
+	for _, rec := range recs {
+		if prefix != "/" && !strings.HasPrefix(rec.Name, prefix) {
+			continue
+		}
+		page.States[rec.State]++
+		page.Bytes[rec.State] += rec.Size
+		if opts.State != "" && rec.State != opts.State {
+			continue
+		}
+		page.Total++
+		if after == nil || compareRecords(rec, after, opts.Sort, opts.Desc) > 0 {
+			matched = append(matched, rec)
+		}
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return compareRecords(matched[i], matched[j], opts.Sort, opts.Desc) < 0
+	})
+	if len(matched) > limit {
+		matched = matched[:limit]
+		page.NextCursor = encodeCursor(matched[limit-1], opts)
+	}
+	page.Files = append(page.Files, matched...)
+	return page, nil
+}
+
+// compareRecords orders a and b by field, then by name.
+func compareRecords(a, b *model.FileRecord, field SortField, desc bool) int {
+	c := 0
+	switch field {
+	case SortSize:
+		c = cmp.Compare(a.Size, b.Size)
+	case SortUploaded:
+		c = cmp.Compare(a.UploadedAt.UnixNano(), b.UploadedAt.UnixNano())
+	case SortState:
+		c = strings.Compare(string(a.State), string(b.State))
+	}
+	if c == 0 {
+		c = strings.Compare(a.Name, b.Name)
+	}
+	if desc {
+		return -c
+	}
+	return c
+}
+
+func encodeCursor(rec *model.FileRecord, opts ListOptions) string {
+	c := cursor{Sort: opts.Sort, Desc: opts.Desc, Name: rec.Name}
+	switch opts.Sort {
+	case SortSize:
+		c.Size = rec.Size
+	case SortUploaded:
+		c.Uploaded = rec.UploadedAt.UnixNano()
+	case SortState:
+		c.State = rec.State
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (*cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,92 @@
+package metadata
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+	"tstore/pkg/model"
+)
+
+func TestJSONStore_ListFiles(t *testing.T) {
+	store, err := NewJSONStore(filepath.Join(t.TempDir(), "metadata.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	ctx := context.Background()
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, rec := range []*model.FileRecord{
+		{Name: "docs/a.txt", State: model.StateLocal, Size: 30},
+		{Name: "docs/b.txt", State: model.StateCloud, Size: 10},
+		{Name: "docs/sub/c.txt", State: model.StateCloud, Size: 30},
+		{Name: "docsx.txt", State: model.StateLocal, Size: 5},
+		{Name: "e.txt", State: model.StateCloud, Size: 20},
+	} {
+		rec.UploadedAt = base.Add(time.Duration(i) * time.Hour)
+		if err := store.Create(ctx, rec); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	// Walk every page and collect the names.
+	walk := func(opts ListOptions) []string {
+		var names []string
+		for {
+			page, err := store.ListFiles(ctx, opts)
+			if err != nil {
+				t.Fatalf("ListFiles(%+v): %v", opts, err)
+			}
+			if len(page.Files) > opts.Limit && opts.Limit > 0 {
+				t.Fatalf("page of %d exceeds limit %d", len(page.Files), opts.Limit)
+			}
+			for _, rec := range page.Files {
+				names = append(names, rec.Name)
+			}
+			if page.NextCursor == "" {
+				return names
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+
+	for _, tc := range []struct {
+		opts ListOptions
+		want []string
+	}{
+		{ListOptions{}, []string{"docs/a.txt", "docs/b.txt", "docs/sub/c.txt", "docsx.txt", "e.txt"}},
+		{ListOptions{Sort: SortSize, Desc: true, Limit: 2}, []string{"docs/sub/c.txt", "docs/a.txt", "e.txt", "docs/b.txt", "docsx.txt"}},
+		{ListOptions{Sort: SortUploaded, Desc: true, Limit: 1}, []string{"e.txt", "docsx.txt", "docs/sub/c.txt", "docs/b.txt", "docs/a.txt"}},
+		{ListOptions{Sort: SortState, Limit: 3}, []string{"docs/b.txt", "docs/sub/c.txt", "e.txt", "docs/a.txt", "docsx.txt"}},
+		{ListOptions{Folder: "docs/", State: model.StateCloud, Limit: 1}, []string{"docs/b.txt", "docs/sub/c.txt"}},
+	} {
+		if got := walk(tc.opts); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ListFiles(%+v) = %v; want %v", tc.opts, got, tc.want)
+		}
+	}
+
+	page, err := store.ListFiles(ctx, ListOptions{Folder: "docs", State: model.StateCloud, Limit: 1})
+	if err != nil {
+		t.Fatalf("ListFiles: %v", err)
+	}
+	if page.Total != 2 || page.States[model.StateLocal] != 1 || page.Bytes[model.StateCloud] != 40 {
+		t.Errorf("summary = total %d, states %v, bytes %v", page.Total, page.States, page.Bytes)
+	}
+
+	// Removing the last record of a page does not disturb the next one.
+	if err := store.Delete(ctx, "docs/b.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	next, err := store.ListFiles(ctx, ListOptions{Folder: "docs", State: model.StateCloud, Limit: 1, Cursor: page.NextCursor})
+	if err != nil || len(next.Files) != 1 || next.Files[0].Name != "docs/sub/c.txt" {
+		t.Errorf("page after delete = %v, %v; want docs/sub/c.txt", next, err)
+	}
+
+	if _, err := store.ListFiles(ctx, ListOptions{Sort: SortSize, Cursor: page.NextCursor}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("cursor reused with another sort: err = %v; want ErrInvalidCursor", err)
+	}
+	if _, err := store.ListFiles(ctx, ListOptions{Sort: "owner"}); err == nil {
+		t.Error("expected an error for an unknown sort field")
+	}
+}
@@ -0,0 +1,287 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"tstore/pkg/model"
+	"unicode"
+)
+
+// A Query is a list of terms that must all match. Terms are separated by
+// spaces; values containing spaces can be double-quoted, and a leading "-"
+// negates a term.
+//
+//	name:*.pdf              glob on the full name or the base name
+//	tag:work                has the tag
+//	attr:project=apollo     attribute equals; attr:project only checks presence
+//	size:>10MB size:1K..2M  size range, in bytes or with K, M, G, T suffixes
+//	uploaded:>=2024-01-01   upload date range, also a..b
+//	state:cloud             file state
+//	desc:"meeting notes"    description contains the text
+//	report                  name or description contains the word
+//
+// Text matching is case-insensitive.
+type Query struct {
+	terms []queryTerm
+}
+
+type queryTerm struct {
+	negate bool
+	match  func(rec *model.FileRecord) bool
+}
+
+func ParseQuery(s string) (*Query, error) {
+	words, err := splitQuery(s)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	for _, w := range words {
+		negate := false
+		if len(w) > 1 && w[0] == '-' {
+			negate, w = true, w[1:]
+		}
+		match, err := parseTerm(w)
+		if err != nil {
+			return nil, err
+		}
+		q.terms = append(q.terms, queryTerm{negate: negate, match: match})
+	}
+	return q, nil
+}
+
+func (q *Query) Match(rec *model.FileRecord) bool {
+	for _, t := range q.terms {
+		if t.match(rec) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// Search returns the records matching query, sorted by name.
+func Search(recs []*model.FileRecord, query string) ([]*model.FileRecord, error) {
+	q, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []*model.FileRecord
+	for _, rec := range recs {
+		if q.Match(rec) {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+func splitQuery(s string) ([]string, error) {
+	var (
+		words  []string
+		cur    strings.Builder
+		quoted bool
+		inWord bool
+	)
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inWord = true
+		case unicode.IsSpace(r) && !quoted:
+			if inWord {
+				words = append(words, cur.String())
+				cur.Reset()
+				inWord = false
+			}
+		default:
+			cur.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote in query %q", s)
+	}
+	if inWord {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+func parseTerm(w string) (func(*model.FileRecord) bool, error) {
+	key, value, ok := strings.Cut(w, ":")
+	if !ok {
+		text := strings.ToLower(w)
+		return func(rec *model.FileRecord) bool {
+			return strings.Contains(strings.ToLower(rec.Name), text) ||
+				strings.Contains(strings.ToLower(rec.Description), text)
+		}, nil
+	}
+	if value == "" {
+		return nil, fmt.Errorf("empty value for %q in query", key)
+	}
+
+	switch strings.ToLower(key) {
+	case "name":
+		pattern := strings.ToLower(value)
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %w", value, err)
+		}
+		return func(rec *model.FileRecord) bool {
+			name := strings.ToLower(rec.Name)
+			full, _ := path.Match(pattern, name)
+			base, _ := path.Match(pattern, path.Base(name))
+			return full || base
+		}, nil
+
+	case "tag":
+		tag := NormalizeTag(value)
+		return func(rec *model.FileRecord) bool {
+			for _, t := range rec.Tags {
+				if t == tag {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case "attr":
+		k, v, hasValue := strings.Cut(value, "=")
+		return func(rec *model.FileRecord) bool {
+			got, ok := rec.Attributes[k]
+			return ok && (!hasValue || strings.EqualFold(got, v))
+		}, nil
+
+	case "size":
+		lo, hi, err := parseRange(value, parseSize)
+		if err != nil {
+			return nil, fmt.Errorf("invalid size %q: %w", value, err)
+		}
+		return func(rec *model.FileRecord) bool {
+			return rec.Size >= lo && rec.Size <= hi
+		}, nil
+
+	case "uploaded":
+		lo, hi, err := parseRange(value, parseDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid date %q: %w", value, err)
+		}
+		return func(rec *model.FileRecord) bool {
+			t := rec.UploadedAt.Unix()
+			return t >= lo && t <= hi
+		}, nil
+
+	case "state":
+		state := model.FileState(strings.ToLower(value))
+		return func(rec *model.FileRecord) bool { return rec.State == state }, nil
+
+	case "desc":
+		text := strings.ToLower(value)
+		return func(rec *model.FileRecord) bool {
+			return strings.Contains(strings.ToLower(rec.Description), text)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown query field %q", key)
+}
+
+// parseRange reads "a..b", ">a", ">=a", "<b", "<=b" or a single value. An
+// open end is unbounded.
+func parseRange(s string, parse func(string, bool) (int64, error)) (lo, hi int64, err error) {
+	lo, hi = -1<<63, 1<<63-1
+	if a, b, ok := strings.Cut(s, ".."); ok {
+		if a != "" {
+			if lo, err = parse(a, false); err != nil {
+				return 0, 0, err
+			}
+		}
+		if b != "" {
+			if hi, err = parse(b, true); err != nil {
+				return 0, 0, err
+			}
+		}
+		return lo, hi, nil
+	}
+
+	switch {
+	case strings.HasPrefix(s, ">="):
+		lo, err = parse(s[2:], false)
+	case strings.HasPrefix(s, ">"):
+		lo, err = parse(s[1:], true)
+		lo++
+	case strings.HasPrefix(s, "<="):
+		hi, err = parse(s[2:], true)
+	case strings.HasPrefix(s, "<"):
+		hi, err = parse(s[1:], false)
+		hi--
+	default:
+		if lo, err = parse(s, false); err == nil {
+			hi, err = parse(s, true)
+		}
+	}
+	return lo, hi, err
+}
+
+var sizeUnits = map[string]int64{
+	"":  1,
+	"B": 1,
+	"K": 1 << 10, "KB": 1 << 10,
+	"M": 1 << 20, "MB": 1 << 20,
+	"G": 1 << 30, "GB": 1 << 30,
+	"T": 1 << 40, "TB": 1 << 40,
+}
+
+func parseSize(s string, _ bool) (int64, error) {
+	i := strings.IndexFunc(s, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+	if i < 0 {
+		i = len(s)
+	}
+	unit, ok := sizeUnits[strings.ToUpper(s[i:])]
+	if !ok {
+		return 0, fmt.Errorf("unknown unit %q", s[i:])
+	}
+	n, err := strconv.ParseFloat(s[:i], 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// parseDate reads a YYYY-MM-DD date in local time. As the upper end of a
+// range it means the end of that day.
+func parseDate(s string, end bool) (int64, error) {
+	t, err := time.ParseInLocation(time.DateOnly, s, time.Local)
+	if err != nil {
+		return 0, err
+	}
+	if end {
+		return t.AddDate(0, 0, 1).Unix() - 1, nil
+	}
+	return t.Unix(), nil
+}
+
+// NormalizeTag lower-cases and trims a tag so lookups are case-insensitive.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+// NormalizeTags normalizes, de-duplicates and sorts tags, dropping empty
+// ones.
+func NormalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var out []string
+	for _, t := range tags {
+		t = NormalizeTag(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
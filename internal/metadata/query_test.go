@@ -0,0 +1,78 @@
+package metadata
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"tstore/pkg/model"
+)
+
+func TestSearch(t *testing.T) {
+	day := func(s string) time.Time {
+		d, _ := time.ParseInLocation(time.DateOnly, s, time.Local)
+		return d.Add(12 * time.Hour)
+	}
+	recs := []*model.FileRecord{
+		{
+			Name: "docs/Report.pdf", State: model.StateCloud, Size: 3 << 20,
+			Description: "Quarterly report", UploadedAt: day("2024-03-10"),
+			Tags: []string{"work"}, Attributes: map[string]string{"project": "Apollo"},
+		},
+		{
+			Name: "photos/cat.jpg", State: model.StateLocal, Size: 200 << 10,
+			Description: "meeting notes scan", UploadedAt: day("2024-01-05"),
+			Tags: []string{"home", "pets"},
+		},
+		{
+			Name: "notes.txt", State: model.StateLocal, Size: 10,
+			UploadedAt: day("2023-12-31"),
+		},
+	}
+
+	for query, want := range map[string][]string{
+		"":                       {"docs/Report.pdf", "notes.txt", "photos/cat.jpg"},
+		"name:*.pdf":             {"docs/Report.pdf"},
+		"name:photos/*":          {"photos/cat.jpg"},
+		"tag:Work":               {"docs/Report.pdf"},
+		"-tag:work":              {"notes.txt", "photos/cat.jpg"},
+		"attr:project":           {"docs/Report.pdf"},
+		"attr:project=apollo":    {"docs/Report.pdf"},
+		"attr:project=gemini":    nil,
+		"size:>1MB":              {"docs/Report.pdf"},
+		"size:100K..1M":          {"photos/cat.jpg"},
+		"size:<=10":              {"notes.txt"},
+		"uploaded:2024-01-05":    {"photos/cat.jpg"},
+		"uploaded:>=2024-01-01":  {"docs/Report.pdf", "photos/cat.jpg"},
+		"uploaded:..2023-12-31":  {"notes.txt"},
+		"state:local size:>1K":   {"photos/cat.jpg"},
+		`desc:"meeting notes"`:   {"photos/cat.jpg"},
+		"report":                 {"docs/Report.pdf"},
+		`"quarterly report" pdf`: {"docs/Report.pdf"},
+	} {
+		got, err := Search(recs, query)
+		if err != nil {
+			t.Errorf("Search(%q): %v", query, err)
+			continue
+		}
+		var names []string
+		for _, r := range got {
+			names = append(names, r.Name)
+		}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("Search(%q) = %v; want %v", query, names, want)
+		}
+	}
+
+	for _, query := range []string{"size:lots", "uploaded:yesterday", "color:red", `desc:"open`, "name:[", "tag:"} {
+		if _, err := ParseQuery(query); err == nil {
+			t.Errorf("ParseQuery(%q) succeeded; want error", query)
+		}
+	}
+}
+
+func TestNormalizeTags(t *testing.T) {
+	got := NormalizeTags([]string{" Work", "home", "work", "", "HOME"})
+	if want := []string{"home", "work"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("NormalizeTags = %v; want %v", got, want)
+	}
+}
@@ -3,6 +3,7 @@ package metadata
 import (
 	"context"
 	"io"
+	"time"
 	"tstore/pkg/model"
 )
 
@@ -12,8 +13,21 @@ type Store interface {
 	Update(ctx context.Context, rec *model.FileRecord) error
 	Delete(ctx context.Context, id string) error
 	List(ctx context.Context) ([]*model.FileRecord, error)
+	// ListFiles returns one page of live records; see ListOptions. Stores
+	// without an index of their own can use Page.
+	ListFiles(ctx context.Context, opts ListOptions) (*ListPage, error)
 	Load(ctx context.Context, reader io.ReadCloser) error
 	Path() string
+
+	// Trash moves a record out of the namespace into the trash, next to any
+	// trashed records of the same name. Restore moves the most recently
+	// trashed one back and fails with ErrAlreadyExists if the name has been
+	// reused. Purge forgets the trashed record with that name and trash
+	// time for good.
+	Trash(ctx context.Context, name string, at time.Time) error
+	Restore(ctx context.Context, name string) error
+	Purge(ctx context.Context, name string, at time.Time) error
+	ListTrash(ctx context.Context) ([]*model.FileRecord, error)
 }
 
 var ErrNotFound = model.ErrNotFound
@@ -0,0 +1,87 @@
+package metadata
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"tstore/pkg/model"
+)
+
+// Entry is a direct child of a directory. Directories only exist implicitly
+// as prefixes of slash-separated record names and have no Record.
+type Entry struct {
+	Name   string
+	Record *model.FileRecord
+}
+
+func (e Entry) IsDir() bool {
+	return e.Record == nil
+}
+
+func ListDir(recs []*model.FileRecord, prefix string) []Entry {
+	seen := make(map[string]bool)
+	var out []Entry
+	for _, rec := range recs {
+		if !strings.HasPrefix(rec.Name, prefix) {
+			continue
+		}
+		rest := rec.Name[len(prefix):]
+		if rest == "" {
+			continue
+		}
+
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			dir := rest[:i]
+			if !seen[dir] {
+				seen[dir] = true
+				out = append(out, Entry{Name: dir})
+			}
+			continue
+		}
+		if !seen[rest] {
+			seen[rest] = true
+			out = append(out, Entry{Name: rest, Record: rec})
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func IsDir(recs []*model.FileRecord, name string) bool {
+	prefix := strings.Trim(name, "/") + "/"
+	if prefix == "/" {
+		return true
+	}
+	for _, rec := range recs {
+		if strings.HasPrefix(rec.Name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Under returns the records below directory dir, sorted by name. The empty
+// dir is the root.
+func Under(recs []*model.FileRecord, dir string) []*model.FileRecord {
+	prefix := strings.Trim(dir, "/") + "/"
+	var out []*model.FileRecord
+	for _, rec := range recs {
+		if prefix == "/" || strings.HasPrefix(rec.Name, prefix) {
+			out = append(out, rec)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// CleanName turns a slash-separated path into a record name, rejecting
+// anything that would escape the sync folder.
+func CleanName(name string) (string, error) {
+	cleaned := strings.Trim(path.Clean("/"+strings.ReplaceAll(name, "\\", "/")), "/")
+	if cleaned == "" {
+		return "", fmt.Errorf("invalid name %q", name)
+	}
+	return cleaned, nil
+}
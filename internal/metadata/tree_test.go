@@ -0,0 +1,90 @@
+package metadata
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"tstore/pkg/model"
+)
+
+func TestListDir(t *testing.T) {
+	recs := []*model.FileRecord{
+		{Name: "b.txt"},
+		{Name: "docs/a.md"},
+		{Name: "docs/img/x.png"},
+		{Name: "a.txt"},
+	}
+
+	var got []string
+	for _, e := range ListDir(recs, "") {
+		got = append(got, fmt.Sprintf("%s:%v", e.Name, e.IsDir()))
+	}
+	want := []string{"a.txt:false", "b.txt:false", "docs:true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDir(root) = %v; want %v", got, want)
+	}
+
+	got = nil
+	for _, e := range ListDir(recs, "docs/") {
+		got = append(got, fmt.Sprintf("%s:%v", e.Name, e.IsDir()))
+	}
+	want = []string{"a.md:false", "img:true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ListDir(docs/) = %v; want %v", got, want)
+	}
+}
+
+func TestIsDir(t *testing.T) {
+	recs := []*model.FileRecord{{Name: "docs/a.md"}, {Name: "b.txt"}}
+
+	for name, want := range map[string]bool{
+		"/":       true,
+		"docs":    true,
+		"/docs/":  true,
+		"doc":     false,
+		"b.txt":   false,
+		"missing": false,
+	} {
+		if got := IsDir(recs, name); got != want {
+			t.Errorf("IsDir(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
+
+func TestUnder(t *testing.T) {
+	recs := []*model.FileRecord{{Name: "docs/b.md"}, {Name: "docs/sub/a.md"}, {Name: "docsx.txt"}, {Name: "c.txt"}}
+
+	names := func(recs []*model.FileRecord) []string {
+		var out []string
+		for _, r := range recs {
+			out = append(out, r.Name)
+		}
+		return out
+	}
+	if got, want := names(Under(recs, "/docs/")), []string{"docs/b.md", "docs/sub/a.md"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Under(docs) = %v; want %v", got, want)
+	}
+	if got := Under(recs, ""); len(got) != 4 {
+		t.Errorf("Under(root) = %v; want all 4 records", names(got))
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	for in, want := range map[string]string{
+		"a.txt":         "a.txt",
+		"/docs//a.md/":  "docs/a.md",
+		"docs/../b.txt": "b.txt",
+		`win\dir\c.txt`: "win/dir/c.txt",
+	} {
+		got, err := CleanName(in)
+		if err != nil || got != want {
+			t.Errorf("CleanName(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+
+	for _, in := range []string{"", "/", "..", "./"} {
+		if _, err := CleanName(in); err == nil {
+			t.Errorf("CleanName(%q) succeeded; want error", in)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package mount
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"tstore/pkg/model"
+)
+
+var ErrUnsupported = errors.New("mounting is only supported on Linux")
+
+type Source interface {
+	List(ctx context.Context) ([]*model.FileRecord, error)
+	Get(ctx context.Context, name string) (*model.FileRecord, error)
+}
+
+func inodeOf(path string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	if ino := h.Sum64(); ino > 1 {
+		return ino
+	}
+	return 2
+}
@@ -0,0 +1,208 @@
+//go:build linux
+
+package mount
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+
+	"github.com/hanwen/go-fuse/v2/fs"
+	"github.com/hanwen/go-fuse/v2/fuse"
+)
+
+type filesystem struct {
+	syncFolder string
+	store      Source
+	chunks     telegram.ChunkSource
+}
+
+type Mount struct {
+	server *fuse.Server
+}
+
+func New(dir, syncFolder string, store Source, chunks telegram.ChunkSource) (*Mount, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create mount point %q: %w", dir, err)
+	}
+
+	fsys := &filesystem{syncFolder: syncFolder, store: store, chunks: chunks}
+	root := &dirNode{fsys: fsys}
+
+	timeout := 5 * time.Second
+	server, err := fs.Mount(dir, root, &fs.Options{
+		EntryTimeout: &timeout,
+		AttrTimeout:  &timeout,
+		MountOptions: fuse.MountOptions{
+			FsName: "tstore",
+			Name:   "tstore",
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("mount %q: %w", dir, err)
+	}
+
+	return &Mount{server: server}, nil
+}
+
+func (m *Mount) Unmount() error {
+	if err := m.server.Unmount(); err != nil {
+		return err
+	}
+	m.server.Wait()
+	return nil
+}
+
+func toErrno(err error) syscall.Errno {
+	if errors.Is(err, model.ErrNotFound) {
+		return syscall.ENOENT
+	}
+	if errors.Is(err, context.Canceled) {
+		return syscall.EINTR
+	}
+	log.Printf("mount: %v", err)
+	return syscall.EIO
+}
+
+func fillFileAttr(rec *model.FileRecord, out *fuse.Attr) {
+	out.Mode = syscall.S_IFREG | 0o444
+	out.Size = uint64(rec.Size)
+	out.Blocks = (out.Size + 511) / 512
+	out.SetTimes(nil, &rec.UploadedAt, &rec.UploadedAt)
+}
+
+type dirNode struct {
+	fs.Inode
+	fsys   *filesystem
+	prefix string
+}
+
+var (
+	_ fs.NodeLookuper  = (*dirNode)(nil)
+	_ fs.NodeReaddirer = (*dirNode)(nil)
+	_ fs.NodeGetattrer = (*dirNode)(nil)
+)
+
+func (n *dirNode) entries(ctx context.Context) ([]metadata.Entry, syscall.Errno) {
+	recs, err := n.fsys.store.List(ctx)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+	return metadata.ListDir(recs, n.prefix), 0
+}
+
+func (n *dirNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	out.Mode = syscall.S_IFDIR | 0o555
+	return 0
+}
+
+func (n *dirNode) Readdir(ctx context.Context) (fs.DirStream, syscall.Errno) {
+	entries, errno := n.entries(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	list := make([]fuse.DirEntry, 0, len(entries))
+	for _, e := range entries {
+		mode := uint32(syscall.S_IFREG)
+		if e.IsDir() {
+			mode = syscall.S_IFDIR
+		}
+		list = append(list, fuse.DirEntry{
+			Name: e.Name,
+			Mode: mode,
+			Ino:  inodeOf(n.prefix + e.Name),
+		})
+	}
+	return fs.NewListDirStream(list), 0
+}
+
+func (n *dirNode) Lookup(ctx context.Context, name string, out *fuse.EntryOut) (*fs.Inode, syscall.Errno) {
+	entries, errno := n.entries(ctx)
+	if errno != 0 {
+		return nil, errno
+	}
+
+	path := n.prefix + name
+	for _, e := range entries {
+		if e.Name != name {
+			continue
+		}
+		if e.IsDir() {
+			out.Mode = syscall.S_IFDIR | 0o555
+			child := &dirNode{fsys: n.fsys, prefix: path + "/"}
+			return n.NewInode(ctx, child, fs.StableAttr{Mode: syscall.S_IFDIR, Ino: inodeOf(path)}), 0
+		}
+
+		fillFileAttr(e.Record, &out.Attr)
+		child := &fileNode{fsys: n.fsys, name: e.Record.Name}
+		return n.NewInode(ctx, child, fs.StableAttr{Mode: syscall.S_IFREG, Ino: inodeOf(path)}), 0
+	}
+
+	return nil, syscall.ENOENT
+}
+
+type fileNode struct {
+	fs.Inode
+	fsys *filesystem
+	name string
+}
+
+var (
+	_ fs.NodeGetattrer = (*fileNode)(nil)
+	_ fs.NodeOpener    = (*fileNode)(nil)
+	_ fs.NodeReader    = (*fileNode)(nil)
+)
+
+func (n *fileNode) Getattr(ctx context.Context, f fs.FileHandle, out *fuse.AttrOut) syscall.Errno {
+	rec, err := n.fsys.store.Get(ctx, n.name)
+	if err != nil {
+		return toErrno(err)
+	}
+	fillFileAttr(rec, &out.Attr)
+	return 0
+}
+
+func (n *fileNode) Open(ctx context.Context, flags uint32) (fs.FileHandle, uint32, syscall.Errno) {
+	if flags&(syscall.O_WRONLY|syscall.O_RDWR) != 0 {
+		return nil, 0, syscall.EROFS
+	}
+	return nil, fuse.FOPEN_KEEP_CACHE, 0
+}
+
+func (n *fileNode) Read(ctx context.Context, f fs.FileHandle, dest []byte, off int64) (fuse.ReadResult, syscall.Errno) {
+	rec, err := n.fsys.store.Get(ctx, n.name)
+	if err != nil {
+		return nil, toErrno(err)
+	}
+
+	var read int
+	if rec.State == model.StateLocal {
+		read, err = readLocal(filepath.Join(n.fsys.syncFolder, rec.Name), dest, off)
+	} else {
+		read, err = telegram.NewFileReader(ctx, n.fsys.chunks, rec).ReadAt(dest, off)
+	}
+	if err != nil && err != io.EOF {
+		return nil, toErrno(err)
+	}
+
+	return fuse.ReadResultData(dest[:read]), 0
+}
+
+func readLocal(path string, dest []byte, off int64) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.ReadAt(dest, off)
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+
+package mount
+
+import "tstore/internal/telegram"
+
+type Mount struct{}
+
+func New(dir, syncFolder string, store Source, chunks telegram.ChunkSource) (*Mount, error) {
+	return nil, ErrUnsupported
+}
+
+func (m *Mount) Unmount() error {
+	return nil
+}
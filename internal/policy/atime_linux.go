@@ -0,0 +1,14 @@
+package policy
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func accessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return latest(time.Unix(st.Atim.Unix()), info.ModTime())
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+
+package policy
+
+import (
+	"os"
+	"time"
+)
+
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
@@ -0,0 +1,14 @@
+package policy
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+func accessTime(info os.FileInfo) time.Time {
+	if attr, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return latest(time.Unix(0, attr.LastAccessTime.Nanoseconds()), info.ModTime())
+	}
+	return info.ModTime()
+}
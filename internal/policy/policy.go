@@ -0,0 +1,243 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"tstore/pkg/model"
+)
+
+type Action string
+
+const (
+	ActionOffload Action = "offload"
+	ActionPin     Action = "pin"
+)
+
+// Rule selects records by folder, extension, size and idle time. All set
+// conditions must hold for the rule to match; the first matching rule wins.
+type Rule struct {
+	Folder      string `json:"folder,omitempty"`
+	Extension   string `json:"extension,omitempty"`
+	MinSize     int64  `json:"min_size,omitempty"`
+	MinIdleDays int    `json:"min_idle_days,omitempty"`
+	Action      Action `json:"action"`
+}
+
+func (r Rule) Validate() error {
+	switch r.Action {
+	case ActionOffload, ActionPin:
+	default:
+		return fmt.Errorf("unknown action %q", r.Action)
+	}
+	if r.MinSize < 0 {
+		return fmt.Errorf("negative min_size %d", r.MinSize)
+	}
+	if r.MinIdleDays < 0 {
+		return fmt.Errorf("negative min_idle_days %d", r.MinIdleDays)
+	}
+	return nil
+}
+
+func (r Rule) Matches(rec *model.FileRecord, lastAccess, now time.Time) bool {
+	if r.Folder != "" {
+		folder := strings.Trim(filepath.ToSlash(r.Folder), "/")
+		dir := path.Dir(filepath.ToSlash(rec.Name))
+		if dir != folder && !strings.HasPrefix(dir, folder+"/") {
+			return false
+		}
+	}
+	if r.Extension != "" {
+		want := strings.ToLower(strings.TrimPrefix(r.Extension, "."))
+		got := strings.ToLower(strings.TrimPrefix(path.Ext(rec.Name), "."))
+		if want != got {
+			return false
+		}
+	}
+	if rec.Size < r.MinSize {
+		return false
+	}
+	// An unknown access time never counts as idle.
+	if r.MinIdleDays > 0 {
+		if lastAccess.IsZero() || now.Sub(lastAccess) < time.Duration(r.MinIdleDays)*24*time.Hour {
+			return false
+		}
+	}
+	return true
+}
+
+// latest returns the later of a file's access and modification times. On
+// filesystems mounted noatime, or where the access time cannot be read,
+// atime lags behind writes and would make new files look idle.
+func latest(atime, mtime time.Time) time.Time {
+	if atime.After(mtime) {
+		return atime
+	}
+	return mtime
+}
+
+func Match(rules []Rule, rec *model.FileRecord, lastAccess, now time.Time) (Action, bool) {
+	for _, r := range rules {
+		if r.Matches(rec, lastAccess, now) {
+			return r.Action, true
+		}
+	}
+	return "", false
+}
+
+type Lister interface {
+	List(ctx context.Context) ([]*model.FileRecord, error)
+}
+
+type ActionFn func(ctx context.Context, name string) error
+
+// Worker applies the rules. Offload and Download change one file without
+// backing up metadata; Backup runs once after a pass that changed any.
+type Worker struct {
+	Store         Lister
+	SyncFolder    string
+	Rules         []Rule
+	MaxLocalBytes int64
+	Offload       ActionFn
+	Download      ActionFn
+	Backup        func(ctx context.Context) error
+
+	now func() time.Time
+}
+
+func NewWorker(store Lister, syncFolder string, rules []Rule, maxLocalBytes int64, offload, download ActionFn, backup func(ctx context.Context) error) *Worker {
+	return &Worker{
+		Store:         store,
+		SyncFolder:    syncFolder,
+		Rules:         rules,
+		MaxLocalBytes: maxLocalBytes,
+		Offload:       offload,
+		Download:      download,
+		Backup:        backup,
+		now:           time.Now,
+	}
+}
+
+func (w *Worker) Enabled() bool {
+	return len(w.Rules) > 0 || w.MaxLocalBytes > 0
+}
+
+func (w *Worker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.RunOnce(ctx); err != nil {
+			log.Printf("offload policy run failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type candidate struct {
+	rec        *model.FileRecord
+	lastAccess time.Time
+}
+
+// RunOnce applies the rules to every record and then offloads the least
+// recently used unpinned local files until the local usage fits the target.
+// Metadata is backed up once at the end if any file changed, even when the
+// run stops early.
+func (w *Worker) RunOnce(ctx context.Context) (err error) {
+	changed := false
+	defer func() {
+		if !changed || w.Backup == nil {
+			return
+		}
+		if berr := w.Backup(context.WithoutCancel(ctx)); berr != nil {
+			err = errors.Join(err, fmt.Errorf("backup metadata: %w", berr))
+		}
+	}()
+
+	recs, err := w.Store.List(ctx)
+	if err != nil {
+		return fmt.Errorf("list records: %w", err)
+	}
+
+	now := w.now()
+	var (
+		localBytes int64
+		lru        []candidate
+	)
+	for _, rec := range recs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var lastAccess time.Time
+		if rec.State == model.StateLocal {
+			info, err := os.Stat(filepath.Join(w.SyncFolder, rec.Name))
+			if err != nil {
+				continue
+			}
+			lastAccess = accessTime(info)
+		}
+
+		action, matched := Match(w.Rules, rec, lastAccess, now)
+		switch {
+		case matched && action == ActionPin:
+			if rec.State == model.StateCloud {
+				if err := w.Download(ctx, rec.Name); err != nil {
+					log.Printf("policy download %q: %v", rec.Name, err)
+					continue
+				}
+				changed = true
+				localBytes += rec.Size
+			} else if rec.State == model.StateLocal {
+				localBytes += rec.Size
+			}
+
+		case matched && action == ActionOffload:
+			if rec.State == model.StateLocal {
+				if err := w.Offload(ctx, rec.Name); err != nil {
+					log.Printf("policy offload %q: %v", rec.Name, err)
+					localBytes += rec.Size
+				} else {
+					changed = true
+				}
+			}
+
+		case rec.State == model.StateLocal:
+			localBytes += rec.Size
+			lru = append(lru, candidate{rec: rec, lastAccess: lastAccess})
+		}
+	}
+
+	if w.MaxLocalBytes <= 0 || localBytes <= w.MaxLocalBytes {
+		return nil
+	}
+
+	sort.Slice(lru, func(i, j int) bool {
+		return lru[i].lastAccess.Before(lru[j].lastAccess)
+	})
+	for _, c := range lru {
+		if localBytes <= w.MaxLocalBytes {
+			break
+		}
+		if err := w.Offload(ctx, c.rec.Name); err != nil {
+			log.Printf("policy offload %q: %v", c.rec.Name, err)
+			continue
+		}
+		changed = true
+		localBytes -= c.rec.Size
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+	"tstore/pkg/model"
+)
+
+type fakeLister []*model.FileRecord
+
+func (l fakeLister) List(ctx context.Context) ([]*model.FileRecord, error) {
+	return l, nil
+}
+
+func TestRule_Matches(t *testing.T) {
+	now := time.Date(2025, 5, 6, 12, 0, 0, 0, time.UTC)
+	rec := &model.FileRecord{Name: "videos/2024/trip.MP4", Size: 100}
+
+	tests := []struct {
+		name       string
+		rule       Rule
+		lastAccess time.Time
+		want       bool
+	}{
+		{"empty rule", Rule{Action: ActionOffload}, time.Time{}, true},
+		{"folder prefix", Rule{Folder: "videos"}, time.Time{}, true},
+		{"folder exact", Rule{Folder: "videos/2024/"}, time.Time{}, true},
+		{"other folder", Rule{Folder: "vid"}, time.Time{}, false},
+		{"extension", Rule{Extension: ".mp4"}, time.Time{}, true},
+		{"other extension", Rule{Extension: "mkv"}, time.Time{}, false},
+		{"size ok", Rule{MinSize: 100}, time.Time{}, true},
+		{"too small", Rule{MinSize: 101}, time.Time{}, false},
+		{"idle", Rule{MinIdleDays: 3}, now.Add(-4 * 24 * time.Hour), true},
+		{"recently used", Rule{MinIdleDays: 3}, now.Add(-time.Hour), false},
+		{"unknown access time", Rule{MinIdleDays: 3}, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rule.Matches(rec, tt.lastAccess, now); got != tt.want {
+			t.Errorf("%s: Matches = %v; want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAccessTime_FallsBackToModTime(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "fresh.bin")
+	if err := os.WriteFile(p, []byte("x"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	// As on a noatime mount: written just now, last read long ago.
+	mtime := time.Now().Truncate(time.Second)
+	if err := os.Chtimes(p, mtime.Add(-90*24*time.Hour), mtime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+	info, err := os.Stat(p)
+	if err != nil {
+		t.Fatalf("stat: %v", err)
+	}
+
+	got := accessTime(info)
+	if !got.Equal(mtime) {
+		t.Errorf("accessTime = %v; want the modification time %v", got, mtime)
+	}
+	if (Rule{MinIdleDays: 30, Action: ActionOffload}).Matches(&model.FileRecord{Name: "fresh.bin"}, got, time.Now()) {
+		t.Error("a freshly written file matched an idle rule")
+	}
+}
+
+func TestWorker_RunOnce(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+
+	write := func(name string, size int, atime time.Time) {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, make([]byte, size), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		if err := os.Chtimes(p, atime, atime); err != nil {
+			t.Fatalf("chtimes %s: %v", name, err)
+		}
+	}
+	write("old.bin", 40, now.Add(-72*time.Hour))
+	write("new.bin", 40, now.Add(-time.Hour))
+	write("mid.bin", 40, now.Add(-24*time.Hour))
+	write("big.iso", 10, now)
+	write("keep.txt", 40, now.Add(-100*time.Hour))
+
+	recs := fakeLister{
+		{Name: "old.bin", State: model.StateLocal, Size: 40},
+		{Name: "new.bin", State: model.StateLocal, Size: 40},
+		{Name: "mid.bin", State: model.StateLocal, Size: 40},
+		{Name: "big.iso", State: model.StateLocal, Size: 10},
+		{Name: "keep.txt", State: model.StateLocal, Size: 40},
+		{Name: "cloud.txt", State: model.StateCloud, Size: 5},
+	}
+	rules := []Rule{
+		{Extension: "txt", Action: ActionPin},
+		{Extension: "iso", Action: ActionOffload},
+	}
+
+	var (
+		offloaded, downloaded []string
+		backups               int
+	)
+	w := NewWorker(recs, dir, rules, 100,
+		func(ctx context.Context, name string) error {
+			offloaded = append(offloaded, name)
+			return nil
+		},
+		func(ctx context.Context, name string) error {
+			downloaded = append(downloaded, name)
+			return nil
+		},
+		func(ctx context.Context) error {
+			backups++
+			return nil
+		},
+	)
+	w.now = func() time.Time { return now }
+
+	if err := w.RunOnce(context.Background()); err != nil {
+		t.Fatalf("RunOnce: %v", err)
+	}
+
+	// Pinned files still count towards the 100 byte target, so the two
+	// least recently used candidates have to go.
+	sort.Strings(offloaded)
+	if want := []string{"big.iso", "mid.bin", "old.bin"}; !reflect.DeepEqual(offloaded, want) {
+		t.Errorf("offloaded = %v; want %v", offloaded, want)
+	}
+	if want := []string{"cloud.txt"}; !reflect.DeepEqual(downloaded, want) {
+		t.Errorf("downloaded = %v; want %v", downloaded, want)
+	}
+	if backups != 1 {
+		t.Errorf("backed up metadata %d times; want once per run", backups)
+	}
+}
@@ -0,0 +1,147 @@
+package preview
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"tstore/internal/index"
+	"tstore/pkg/model"
+)
+
+const (
+	// ThumbnailSize bounds the width and height of image thumbnails.
+	ThumbnailSize = 256
+
+	// MaxImagePixels skips images too large to decode comfortably.
+	MaxImagePixels = 64 << 20
+
+	// Snippets keep at most this many lines and runes from the start of a
+	// text file.
+	SnippetLines = 20
+	SnippetRunes = 1000
+
+	snippetReadBytes = 16 << 10
+)
+
+var imageExts = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".gif": true}
+
+// A Preview is the generated content of a model.Preview before it is
+// stored.
+type Preview struct {
+	Kind model.PreviewKind
+	MIME string
+	Data []byte
+}
+
+// Generate builds a preview of the file at filePath, named name: a JPEG
+// thumbnail for JPEG, PNG and GIF images, or the opening lines of text
+// files. It returns nil for files it cannot preview.
+func Generate(filePath, name string) (*Preview, error) {
+	ext := strings.ToLower(path.Ext(name))
+	if !imageExts[ext] && !index.Indexable(name) {
+		return nil, nil
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if imageExts[ext] {
+		return thumbnail(f)
+	}
+	return snippet(f, name)
+}
+
+func thumbnail(f io.ReadSeeker) (*Preview, error) {
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode image header: %w", err)
+	}
+	if cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, nil
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scale(img, ThumbnailSize), &jpeg.Options{Quality: 75}); err != nil {
+		return nil, fmt.Errorf("encode thumbnail: %w", err)
+	}
+	return &Preview{Kind: model.PreviewImage, MIME: "image/jpeg", Data: buf.Bytes()}, nil
+}
+
+// scale shrinks img to fit in a size by size square, averaging the source
+// pixels behind each destination pixel. Transparent areas become white, as
+// JPEG has no alpha. Smaller images keep their size.
+func scale(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			w, h = size, max(1, h*size/b.Dx())
+		} else {
+			w, h = max(1, w*size/b.Dy()), size
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0 := b.Min.Y + y*b.Dy()/h
+		y1 := max(y0+1, b.Min.Y+(y+1)*b.Dy()/h)
+		for x := 0; x < w; x++ {
+			x0 := b.Min.X + x*b.Dx()/w
+			x1 := max(x0+1, b.Min.X+(x+1)*b.Dx()/w)
+
+			var r, g, bl, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					// Composite over white.
+					r += uint64(cr + 0xffff - ca)
+					g += uint64(cg + 0xffff - ca)
+					bl += uint64(cb + 0xffff - ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: 0xffff,
+			})
+		}
+	}
+	return dst
+}
+
+func snippet(r io.Reader, name string) (*Preview, error) {
+	text, err := index.Extract(name, io.LimitReader(r, snippetReadBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) > SnippetLines {
+		lines = lines[:SnippetLines]
+	}
+	text = strings.Join(lines, "\n")
+	if runes := []rune(text); len(runes) > SnippetRunes {
+		text = string(runes[:SnippetRunes])
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	return &Preview{Kind: model.PreviewText, MIME: "text/plain; charset=utf-8", Data: []byte(text)}, nil
+}
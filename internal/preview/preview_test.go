@@ -0,0 +1,77 @@
+package preview
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tstore/pkg/model"
+)
+
+func TestGenerate_Thumbnail(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 600, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 600; x++ {
+			img.Set(x, y, color.NRGBA{R: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	src := filepath.Join(t.TempDir(), "wide.png")
+	if err := os.WriteFile(src, buf.Bytes(), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	p, err := Generate(src, "photos/Wide.PNG")
+	if err != nil || p == nil {
+		t.Fatalf("Generate = %v, %v", p, err)
+	}
+	if p.Kind != model.PreviewImage || p.MIME != "image/jpeg" {
+		t.Errorf("preview = %s %s; want an image/jpeg image", p.Kind, p.MIME)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(p.Data))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if b := thumb.Bounds(); b.Dx() != ThumbnailSize || b.Dy() != ThumbnailSize/2 {
+		t.Errorf("thumbnail is %dx%d; want %dx%d", b.Dx(), b.Dy(), ThumbnailSize, ThumbnailSize/2)
+	}
+	if r, _, _, _ := thumb.At(10, 10).RGBA(); r>>8 < 190 {
+		t.Errorf("thumbnail lost its colour: red = %d", r>>8)
+	}
+}
+
+func TestGenerate_Snippet(t *testing.T) {
+	dir := t.TempDir()
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, "line")
+	}
+	src := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(src, []byte("\n"+strings.Join(lines, "\n")), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	p, err := Generate(src, "notes.md")
+	if err != nil || p == nil {
+		t.Fatalf("Generate = %v, %v", p, err)
+	}
+	if p.Kind != model.PreviewText || strings.Count(string(p.Data), "\n") != SnippetLines-1 {
+		t.Errorf("snippet = %q; want %d lines", p.Data, SnippetLines)
+	}
+
+	bin := filepath.Join(dir, "data.bin")
+	if err := os.WriteFile(bin, []byte{1, 2, 3}, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if p, err := Generate(bin, "data.bin"); p != nil || err != nil {
+		t.Errorf("Generate(data.bin) = %v, %v; want no preview", p, err)
+	}
+}
@@ -0,0 +1,865 @@
+package s3gw
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+)
+
+const (
+	maxListKeys = 1000
+	maxParts    = 10000
+)
+
+type Files interface {
+	PutFileWith(ctx context.Context, filePath, name, chatID string, opts telegram.PutOptions, onProgress telegram.ProgressFn) (*model.FileRecord, error)
+	PutStream(ctx context.Context, r io.Reader, size int64, name, chatID string, opts telegram.PutOptions, onProgress telegram.ProgressFn) (*model.FileRecord, error)
+	Open(ctx context.Context, rec *model.FileRecord) (telegram.Content, error)
+	DeleteFile(ctx context.Context, name, chatID string) error
+}
+
+type apiError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.code + ": " + e.message
+}
+
+var (
+	errNoSuchBucket   = &apiError{http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist"}
+	errNoSuchKey      = &apiError{http.StatusNotFound, "NoSuchKey", "The specified key does not exist"}
+	errNoSuchUpload   = &apiError{http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist"}
+	errBucketNotEmpty = &apiError{http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty"}
+	errBucketExists   = &apiError{http.StatusConflict, "BucketAlreadyOwnedByYou", "The bucket already exists"}
+	errInvalidBucket  = &apiError{http.StatusBadRequest, "InvalidBucketName", "The specified bucket is not valid"}
+	errInvalidPart    = &apiError{http.StatusBadRequest, "InvalidPart", "One or more of the specified parts could not be found"}
+	errInvalidOrder   = &apiError{http.StatusBadRequest, "InvalidPartOrder", "The list of parts was not in ascending order"}
+	errBadDigest      = &apiError{http.StatusBadRequest, "BadDigest", "The Content-MD5 you specified did not match what was received"}
+	errMalformedXML   = &apiError{http.StatusBadRequest, "MalformedXML", "The XML you provided was not well-formed"}
+	errNotImplemented = &apiError{http.StatusNotImplemented, "NotImplemented", "This operation is not supported"}
+)
+
+type multipartUpload struct {
+	bucket string
+	key    string
+	dir    string
+	// parts maps the part numbers staged so far to their hex MD5.
+	parts map[int]string
+}
+
+// Server exposes the store over a path-style S3 API. Buckets are the
+// top-level folders of the library and object keys the rest of the record
+// name.
+type Server struct {
+	addr       string
+	accessKey  string
+	secretKey  string
+	stagingDir string
+	store      metadata.Store
+	srv        *http.Server
+	now        func() time.Time
+
+	mu      sync.Mutex
+	files   Files
+	chatID  string
+	buckets map[string]bool
+	uploads map[string]*multipartUpload
+}
+
+func NewServer(addr, accessKey, secretKey, chatID, stagingDir string, store metadata.Store, files Files) (*Server, error) {
+	if err := os.MkdirAll(stagingDir, 0o700); err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		addr:       addr,
+		accessKey:  accessKey,
+		secretKey:  secretKey,
+		chatID:     chatID,
+		stagingDir: stagingDir,
+		store:      store,
+		files:      files,
+		now:        time.Now,
+		buckets:    make(map[string]bool),
+		uploads:    make(map[string]*multipartUpload),
+	}
+	if err := s.load(); err != nil {
+		return nil, fmt.Errorf("load s3 gateway state: %w", err)
+	}
+	s.srv = &http.Server{Addr: addr, Handler: s.Handler()}
+	return s, nil
+}
+
+// SetFiles points a running server at files and chatID, as after the config
+// changed without touching the server's own settings.
+func (s *Server) SetFiles(files Files, chatID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files, s.chatID = files, chatID
+}
+
+func (s *Server) target() (Files, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files, s.chatID
+}
+
+func (s *Server) Start() error {
+	if s.accessKey == "" || s.secretKey == "" {
+		return errors.New("s3 gateway requires an access key and secret key")
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("listen on %q: %w", s.addr, err)
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("s3 gateway stopped: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+func (s *Server) Handler() http.Handler {
+	return http.HandlerFunc(s.serve)
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if err := verifyRequest(r, s.accessKey, s.secretKey, s.now()); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	q := r.URL.Query()
+
+	var err error
+	switch {
+	case bucket == "" && r.Method == http.MethodGet:
+		err = s.listBuckets(w, r)
+
+	case key == "":
+		switch {
+		case r.Method == http.MethodGet && q.Has("location"):
+			err = s.getBucketLocation(w, r, bucket)
+		case r.Method == http.MethodGet && q.Get("list-type") == "2":
+			err = s.listObjectsV2(w, r, bucket)
+		case r.Method == http.MethodHead:
+			err = s.headBucket(w, r, bucket)
+		case r.Method == http.MethodPut:
+			err = s.createBucket(w, r, bucket)
+		case r.Method == http.MethodDelete:
+			err = s.deleteBucket(w, r, bucket)
+		default:
+			err = errNotImplemented
+		}
+
+	default:
+		switch {
+		case r.Method == http.MethodPut && q.Has("uploadId"):
+			err = s.uploadPart(w, r, bucket, key)
+		case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+			err = errNotImplemented
+		case r.Method == http.MethodPut:
+			err = s.putObject(w, r, bucket, key)
+		case r.Method == http.MethodGet, r.Method == http.MethodHead:
+			err = s.getObject(w, r, bucket, key)
+		case r.Method == http.MethodDelete && q.Has("uploadId"):
+			err = s.abortMultipartUpload(w, r, bucket, key)
+		case r.Method == http.MethodDelete:
+			err = s.deleteObject(w, r, bucket, key)
+		case r.Method == http.MethodPost && q.Has("uploads"):
+			err = s.createMultipartUpload(w, r, bucket, key)
+		case r.Method == http.MethodPost && q.Has("uploadId"):
+			err = s.completeMultipartUpload(w, r, bucket, key)
+		default:
+			err = errNotImplemented
+		}
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+	}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		log.Printf("s3 %s %s: %v", r.Method, r.URL.Path, err)
+		apiErr = &apiError{http.StatusInternalServerError, "InternalError", err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(apiErr.status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	writeXMLBody(w, struct {
+		XMLName  xml.Name `xml:"Error"`
+		Code     string
+		Message  string
+		Resource string
+	}{Code: apiErr.code, Message: apiErr.message, Resource: r.URL.Path})
+}
+
+func writeXML(w http.ResponseWriter, v any) error {
+	w.Header().Set("Content-Type", "application/xml")
+	writeXMLBody(w, v)
+	return nil
+}
+
+func writeXMLBody(w io.Writer, v any) {
+	io.WriteString(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("s3 encode response: %v", err)
+	}
+}
+
+func objectName(bucket, key string) (string, error) {
+	return metadata.CleanName(bucket + "/" + key)
+}
+
+// etag is the MD5-based entity tag S3 clients expect, falling back to the
+// SHA-256 checksum for files that did not come through the gateway.
+func etag(rec *model.FileRecord) string {
+	if rec.ETag != "" {
+		return `"` + rec.ETag + `"`
+	}
+	return `"` + rec.Checksum + `"`
+}
+
+// putFile uploads staged as name with tag as its ETag. Objects are stored
+// offloaded, only in the chat.
+func (s *Server) putFile(ctx context.Context, staged, name, tag string) (*model.FileRecord, error) {
+	files, chatID := s.target()
+	return files.PutFileWith(ctx, staged, name, chatID, telegram.PutOptions{ETag: tag, Offload: true}, nil)
+}
+
+func (s *Server) bucketExists(ctx context.Context, bucket string) (bool, error) {
+	s.mu.Lock()
+	known := s.buckets[bucket]
+	s.mu.Unlock()
+	if known {
+		return true, nil
+	}
+
+	recs, err := s.store.List(ctx)
+	if err != nil {
+		return false, err
+	}
+	return metadata.IsDir(recs, bucket), nil
+}
+
+func (s *Server) requireBucket(ctx context.Context, bucket string) error {
+	ok, err := s.bucketExists(ctx, bucket)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errNoSuchBucket
+	}
+	return nil
+}
+
+func (s *Server) listBuckets(w http.ResponseWriter, r *http.Request) error {
+	recs, err := s.store.List(r.Context())
+	if err != nil {
+		return err
+	}
+
+	created := make(map[string]time.Time)
+	for _, rec := range recs {
+		name, _, ok := strings.Cut(rec.Name, "/")
+		if !ok {
+			continue
+		}
+		if t, seen := created[name]; !seen || rec.UploadedAt.Before(t) {
+			created[name] = rec.UploadedAt
+		}
+	}
+	s.mu.Lock()
+	for name := range s.buckets {
+		if _, seen := created[name]; !seen {
+			created[name] = s.now()
+		}
+	}
+	s.mu.Unlock()
+
+	type bucketXML struct {
+		Name         string
+		CreationDate string
+	}
+	var list []bucketXML
+	for name, t := range created {
+		list = append(list, bucketXML{Name: name, CreationDate: t.UTC().Format(time.RFC3339)})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return writeXML(w, struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListAllMyBucketsResult"`
+		Owner   struct{ ID string }
+		Buckets []bucketXML `xml:"Buckets>Bucket"`
+	}{Buckets: list})
+}
+
+func (s *Server) getBucketLocation(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if err := s.requireBucket(r.Context(), bucket); err != nil {
+		return err
+	}
+	return writeXML(w, struct {
+		XMLName xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ LocationConstraint"`
+	}{})
+}
+
+func (s *Server) headBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if err := s.requireBucket(r.Context(), bucket); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) createBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	if _, err := metadata.CleanName(bucket); err != nil || strings.ContainsAny(bucket, `/\`) {
+		return errInvalidBucket
+	}
+	ok, err := s.bucketExists(r.Context(), bucket)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return errBucketExists
+	}
+
+	s.mu.Lock()
+	s.buckets[bucket] = true
+	err = s.saveBuckets()
+	if err != nil {
+		delete(s.buckets, bucket)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("save buckets: %w", err)
+	}
+
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) error {
+	recs, err := s.store.List(r.Context())
+	if err != nil {
+		return err
+	}
+	if metadata.IsDir(recs, bucket) {
+		return errBucketNotEmpty
+	}
+
+	s.mu.Lock()
+	known := s.buckets[bucket]
+	delete(s.buckets, bucket)
+	if known {
+		err = s.saveBuckets()
+	}
+	s.mu.Unlock()
+	if !known {
+		return errNoSuchBucket
+	}
+	if err != nil {
+		return fmt.Errorf("save buckets: %w", err)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+type objectXML struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int64
+	StorageClass string
+}
+
+type prefixXML struct {
+	Prefix string
+}
+
+func (s *Server) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) error {
+	ctx := r.Context()
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	q := r.URL.Query()
+	prefix := q.Get("prefix")
+	delimiter := q.Get("delimiter")
+	maxKeys := maxListKeys
+	if v := q.Get("max-keys"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return &apiError{http.StatusBadRequest, "InvalidArgument", "max-keys must be a non-negative integer"}
+		}
+		maxKeys = min(n, maxListKeys)
+	}
+	marker := q.Get("start-after")
+	if token := q.Get("continuation-token"); token != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(token)
+		if err != nil {
+			return &apiError{http.StatusBadRequest, "InvalidArgument", "The continuation token provided is incorrect"}
+		}
+		marker = string(decoded)
+	}
+
+	recs, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[string]*model.FileRecord)
+	var keys []string
+	for _, rec := range recs {
+		key, ok := strings.CutPrefix(rec.Name, bucket+"/")
+		if !ok || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		byKey[key] = rec
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var (
+		contents  []objectXML
+		prefixes  []prefixXML
+		last      string
+		truncated bool
+	)
+	for _, key := range keys {
+		if key <= marker || (delimiter != "" && strings.HasSuffix(marker, delimiter) && strings.HasPrefix(key, marker)) {
+			continue
+		}
+
+		entry := key
+		if delimiter != "" {
+			if i := strings.Index(key[len(prefix):], delimiter); i >= 0 {
+				entry = key[:len(prefix)+i+len(delimiter)]
+			}
+		}
+		if entry == last {
+			continue
+		}
+		if len(contents)+len(prefixes) >= maxKeys {
+			truncated = true
+			break
+		}
+
+		last = entry
+		if entry != key {
+			prefixes = append(prefixes, prefixXML{Prefix: entry})
+			continue
+		}
+		rec := byKey[key]
+		contents = append(contents, objectXML{
+			Key:          key,
+			LastModified: rec.UploadedAt.UTC().Format(time.RFC3339),
+			ETag:         etag(rec),
+			Size:         rec.Size,
+			StorageClass: "STANDARD",
+		})
+	}
+
+	var next string
+	if truncated {
+		next = base64.RawURLEncoding.EncodeToString([]byte(last))
+	}
+
+	return writeXML(w, struct {
+		XMLName               xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name                  string
+		Prefix                string
+		Delimiter             string `xml:",omitempty"`
+		StartAfter            string `xml:",omitempty"`
+		ContinuationToken     string `xml:",omitempty"`
+		NextContinuationToken string `xml:",omitempty"`
+		KeyCount              int
+		MaxKeys               int
+		IsTruncated           bool
+		Contents              []objectXML
+		CommonPrefixes        []prefixXML
+	}{
+		Name:                  bucket,
+		Prefix:                prefix,
+		Delimiter:             delimiter,
+		StartAfter:            q.Get("start-after"),
+		ContinuationToken:     q.Get("continuation-token"),
+		NextContinuationToken: next,
+		KeyCount:              len(contents) + len(prefixes),
+		MaxKeys:               maxKeys,
+		IsTruncated:           truncated,
+		Contents:              contents,
+		CommonPrefixes:        prefixes,
+	})
+}
+
+// stage writes body to a fresh staging directory, checking Content-MD5 when
+// the client sent one.
+func (s *Server) stage(r *http.Request, dir, name string) (string, string, error) {
+	p := filepath.Join(dir, name)
+	f, err := os.Create(p)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	h := md5.New()
+	if _, err := io.Copy(f, io.TeeReader(r.Body, h)); err != nil {
+		var apiErr *apiError
+		if errors.As(err, &apiErr) {
+			return "", "", apiErr
+		}
+		return "", "", fmt.Errorf("read request body: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return "", "", err
+	}
+
+	sum := h.Sum(nil)
+	if want := r.Header.Get("Content-MD5"); want != "" && want != base64.StdEncoding.EncodeToString(sum) {
+		return "", "", errBadDigest
+	}
+	return p, hex.EncodeToString(sum), nil
+}
+
+func (s *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	ctx := r.Context()
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+	name, err := objectName(bucket, key)
+	if err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidArgument", err.Error()}
+	}
+
+	dir, err := os.MkdirTemp(s.stagingDir, "put-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	staged, md5hex, err := s.stage(r, dir, path.Base(name))
+	if err != nil {
+		return err
+	}
+	rec, err := s.putFile(ctx, staged, name, md5hex)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("ETag", etag(rec))
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	ctx := r.Context()
+	name, err := objectName(bucket, key)
+	if err != nil {
+		return errNoSuchKey
+	}
+	rec, err := s.store.Get(ctx, name)
+	if errors.Is(err, model.ErrNotFound) {
+		if err := s.requireBucket(ctx, bucket); err != nil {
+			return err
+		}
+		return errNoSuchKey
+	} else if err != nil {
+		return err
+	}
+
+	files, _ := s.target()
+	content, err := files.Open(ctx, rec)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	ctype := mime.TypeByExtension(path.Ext(rec.Name))
+	if ctype == "" {
+		ctype = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", ctype)
+	w.Header().Set("ETag", etag(rec))
+	w.Header().Set("Accept-Ranges", "bytes")
+
+	http.ServeContent(w, r, "", rec.UploadedAt, content)
+	return nil
+}
+
+func (s *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	ctx := r.Context()
+	if err := s.requireBucket(ctx, bucket); err != nil {
+		return err
+	}
+
+	if name, err := objectName(bucket, key); err == nil {
+		if _, err := s.store.Get(ctx, name); err == nil {
+			files, chatID := s.target()
+			if err := files.DeleteFile(ctx, name, chatID); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	if err := s.requireBucket(r.Context(), bucket); err != nil {
+		return err
+	}
+	if _, err := objectName(bucket, key); err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidArgument", err.Error()}
+	}
+
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return err
+	}
+	id := hex.EncodeToString(raw[:])
+	dir := filepath.Join(s.stagingDir, "mpu-"+id)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	up := &multipartUpload{bucket: bucket, key: key, dir: dir, parts: make(map[int]string)}
+	s.mu.Lock()
+	err := saveUpload(up)
+	if err == nil {
+		s.uploads[id] = up
+	}
+	s.mu.Unlock()
+	if err != nil {
+		os.RemoveAll(dir)
+		return fmt.Errorf("save multipart upload: %w", err)
+	}
+
+	return writeXML(w, struct {
+		XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ InitiateMultipartUploadResult"`
+		Bucket   string
+		Key      string
+		UploadId string
+	}{Bucket: bucket, Key: key, UploadId: id})
+}
+
+func (s *Server) upload(id, bucket, key string) (*multipartUpload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	up, ok := s.uploads[id]
+	if !ok || up.bucket != bucket || up.key != key {
+		return nil, errNoSuchUpload
+	}
+	return up, nil
+}
+
+func partFile(n int) string {
+	return fmt.Sprintf("part-%05d", n)
+}
+
+func (s *Server) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	q := r.URL.Query()
+	up, err := s.upload(q.Get("uploadId"), bucket, key)
+	if err != nil {
+		return err
+	}
+	n, err := strconv.Atoi(q.Get("partNumber"))
+	if err != nil || n < 1 || n > maxParts {
+		return &apiError{http.StatusBadRequest, "InvalidArgument", "Part number must be an integer between 1 and 10000"}
+	}
+
+	_, sum, err := s.stage(r, up.dir, partFile(n))
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	up.parts[n] = sum
+	err = saveUpload(up)
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("save multipart upload: %w", err)
+	}
+
+	w.Header().Set("ETag", `"`+sum+`"`)
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// completeMultipartUpload streams the staged parts, in order, through the
+// uploader's chunking. The parts stay staged until then: their sizes are
+// the client's choice and do not line up with chunk boundaries.
+func (s *Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	ctx := r.Context()
+	id := r.URL.Query().Get("uploadId")
+	up, err := s.upload(id, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	var req struct {
+		Parts []struct {
+			PartNumber int
+			ETag       string
+		} `xml:"Part"`
+	}
+	if err := xml.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Parts) == 0 {
+		return errMalformedXML
+	}
+
+	name, err := objectName(bucket, key)
+	if err != nil {
+		return &apiError{http.StatusBadRequest, "InvalidArgument", err.Error()}
+	}
+
+	// The object's ETag is the MD5 of the concatenated part MD5s followed by
+	// the part count, as S3 reports for multipart uploads.
+	sums := md5.New()
+	content := &partReader{}
+	var size int64
+	prev := 0
+	for _, part := range req.Parts {
+		if part.PartNumber <= prev {
+			return errInvalidOrder
+		}
+		prev = part.PartNumber
+
+		s.mu.Lock()
+		sum, ok := up.parts[part.PartNumber]
+		s.mu.Unlock()
+		if !ok || strings.Trim(part.ETag, `"`) != sum {
+			return errInvalidPart
+		}
+		p := filepath.Join(up.dir, partFile(part.PartNumber))
+		info, err := os.Stat(p)
+		if err != nil {
+			return err
+		}
+		raw, _ := hex.DecodeString(sum)
+		sums.Write(raw)
+		size += info.Size()
+		content.paths = append(content.paths, p)
+	}
+	defer content.Close()
+
+	tag := fmt.Sprintf("%s-%d", hex.EncodeToString(sums.Sum(nil)), len(req.Parts))
+	files, chatID := s.target()
+	rec, err := files.PutStream(ctx, content, size, name, chatID, telegram.PutOptions{ETag: tag}, nil)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	content.Close()
+	os.RemoveAll(up.dir)
+
+	return writeXML(w, struct {
+		XMLName  xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ CompleteMultipartUploadResult"`
+		Location string
+		Bucket   string
+		Key      string
+		ETag     string
+	}{Location: "/" + bucket + "/" + key, Bucket: bucket, Key: key, ETag: etag(rec)})
+}
+
+// partReader reads the files in paths one after another, opening each only
+// once the one before it is done.
+type partReader struct {
+	paths []string
+	f     *os.File
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	for {
+		if r.f == nil {
+			if len(r.paths) == 0 {
+				return 0, io.EOF
+			}
+			f, err := os.Open(r.paths[0])
+			if err != nil {
+				return 0, err
+			}
+			r.f, r.paths = f, r.paths[1:]
+		}
+
+		n, err := r.f.Read(p)
+		if err == io.EOF {
+			r.f.Close()
+			r.f = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *partReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+func (s *Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) error {
+	id := r.URL.Query().Get("uploadId")
+	up, err := s.upload(id, bucket, key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	if err := os.RemoveAll(up.dir); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
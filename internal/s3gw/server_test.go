@@ -0,0 +1,434 @@
+package s3gw
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/internal/telegram"
+	"tstore/pkg/model"
+)
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+type fakeFiles struct {
+	store    metadata.Store
+	contents map[string][]byte
+	streamed []string
+}
+
+func (f *fakeFiles) PutFileWith(ctx context.Context, filePath, name, chatID string, opts telegram.PutOptions, onProgress telegram.ProgressFn) (*model.FileRecord, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return f.put(ctx, data, name, opts)
+}
+
+func (f *fakeFiles) PutStream(ctx context.Context, r io.Reader, size int64, name, chatID string, opts telegram.PutOptions, onProgress telegram.ProgressFn) (*model.FileRecord, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) != size {
+		return nil, fmt.Errorf("read %d bytes; want %d", len(data), size)
+	}
+	opts.Offload = true
+	f.streamed = append(f.streamed, name)
+	return f.put(ctx, data, name, opts)
+}
+
+func (f *fakeFiles) put(ctx context.Context, data []byte, name string, opts telegram.PutOptions) (*model.FileRecord, error) {
+	f.store.Delete(ctx, name)
+	sum := sha256.Sum256(data)
+	rec := &model.FileRecord{
+		Name:       name,
+		State:      model.StateCloud,
+		Size:       int64(len(data)),
+		Checksum:   hex.EncodeToString(sum[:]),
+		UploadedAt: time.Now(),
+		ETag:       opts.ETag,
+	}
+	if !opts.Offload {
+		rec.State = model.StateLocal
+	}
+	if err := f.store.Create(ctx, rec); err != nil {
+		return nil, err
+	}
+	f.contents[name] = data
+	return rec, nil
+}
+
+func (f *fakeFiles) Open(ctx context.Context, rec *model.FileRecord) (telegram.Content, error) {
+	return nopCloser{bytes.NewReader(f.contents[rec.Name])}, nil
+}
+
+func (f *fakeFiles) DeleteFile(ctx context.Context, name, chatID string) error {
+	delete(f.contents, name)
+	return f.store.Delete(ctx, name)
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *fakeFiles) {
+	t.Helper()
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	files := &fakeFiles{store: store, contents: make(map[string][]byte)}
+	s, err := NewServer("", testAccessKey, testSecretKey, "1", filepath.Join(tmp, "staging"), store, files)
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+	return srv, files
+}
+
+// do sends a request signed the way the AWS SDKs sign path-style requests.
+func do(t *testing.T, srv *httptest.Server, method, target, body string, headers map[string]string) (*http.Response, string) {
+	t.Helper()
+
+	r, err := http.NewRequest(method, srv.URL+target, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for k, v := range headers {
+		r.Header.Set(k, v)
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	sign(r, hex.EncodeToString(sum[:]))
+	return send(t, srv, r)
+}
+
+// sign sets the SigV4 headers on r and returns the signing context so that
+// streaming bodies can chain chunk signatures from the seed signature.
+func sign(r *http.Request, payloadHash string) (now time.Time, cred credential, seed string) {
+	now = time.Now().UTC()
+	r.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	cred = credential{accessKey: testAccessKey, date: now.Format("20060102"), region: "us-east-1", service: "s3"}
+	signed := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	canonical := sha256.Sum256([]byte(canonicalRequest(r, signed, payloadHash)))
+	stringToSign := strings.Join([]string{signAlgorithm, now.Format(amzDateFormat), cred.scope(), hex.EncodeToString(canonical[:])}, "\n")
+	seed = hex.EncodeToString(hmacSHA256(signingKey(testSecretKey, cred), []byte(stringToSign)))
+	r.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s,SignedHeaders=%s,Signature=%s",
+		signAlgorithm, testAccessKey, cred.scope(), strings.Join(signed, ";"), seed))
+	return now, cred, seed
+}
+
+// doStreaming PUTs chunks as a STREAMING-AWS4-HMAC-SHA256-PAYLOAD body, the
+// way minio-go does over plain HTTP. tamper corrupts the last data chunk
+// after it has been signed.
+func doStreaming(t *testing.T, srv *httptest.Server, target string, chunks []string, tamper bool) (*http.Response, string) {
+	t.Helper()
+
+	r, err := http.NewRequest(http.MethodPut, srv.URL+target, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	decoded := 0
+	for _, c := range chunks {
+		decoded += len(c)
+	}
+	r.Header.Set("Content-Encoding", "aws-chunked")
+	r.Header.Set("X-Amz-Decoded-Content-Length", fmt.Sprint(decoded))
+	now, cred, prev := sign(r, streamingPayload)
+
+	var body bytes.Buffer
+	key := signingKey(testSecretKey, cred)
+	for i, c := range append(chunks, "") {
+		sum := sha256.Sum256([]byte(c))
+		stringToSign := strings.Join([]string{signAlgorithm + "-PAYLOAD", now.Format(amzDateFormat), cred.scope(), prev, emptySHA256, hex.EncodeToString(sum[:])}, "\n")
+		prev = hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+		if tamper && i == len(chunks)-1 {
+			c = strings.ToUpper(c)
+		}
+		fmt.Fprintf(&body, "%x;chunk-signature=%s\r\n%s\r\n", len(c), prev, c)
+	}
+	r.Body = io.NopCloser(&body)
+	r.ContentLength = int64(body.Len())
+	return send(t, srv, r)
+}
+
+func send(t *testing.T, srv *httptest.Server, r *http.Request) (*http.Response, string) {
+	t.Helper()
+
+	resp, err := srv.Client().Do(r)
+	if err != nil {
+		t.Fatalf("%s %s: %v", r.Method, r.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	return resp, string(data)
+}
+
+func TestS3_RejectsUnsigned(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp, err := srv.Client().Get(srv.URL + "/")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("status = %d; want 403", resp.StatusCode)
+	}
+}
+
+func TestS3_PutGetListDelete(t *testing.T) {
+	srv, files := newTestServer(t)
+
+	if resp, body := do(t, srv, http.MethodPut, "/photos/2024/a.txt", "hello world", nil); resp.StatusCode != http.StatusNotFound {
+		t.Fatalf("PUT into missing bucket = %d %s; want 404", resp.StatusCode, body)
+	}
+	if resp, body := do(t, srv, http.MethodPut, "/photos", "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("CreateBucket = %d %s", resp.StatusCode, body)
+	}
+	helloSum := md5.Sum([]byte("hello world"))
+	helloETag := `"` + hex.EncodeToString(helloSum[:]) + `"`
+	for _, key := range []string{"2024/a.txt", "2024/b.txt", "2025/c.txt", "top.txt"} {
+		resp, body := do(t, srv, http.MethodPut, "/photos/"+key, "hello world", nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("PUT %s = %d %s", key, resp.StatusCode, body)
+		}
+		if got := resp.Header.Get("ETag"); got != helloETag {
+			t.Errorf("PUT %s ETag = %s; want the body MD5 %s", key, got, helloETag)
+		}
+	}
+	if got := string(files.contents["photos/2024/a.txt"]); got != "hello world" {
+		t.Errorf("stored content = %q; want %q", got, "hello world")
+	}
+	if rec, err := files.store.Get(context.Background(), "photos/2024/a.txt"); err != nil || rec.State != model.StateCloud {
+		t.Errorf("stored record = %+v, %v; want an offloaded one", rec, err)
+	}
+
+	resp, body := do(t, srv, http.MethodGet, "/photos/2024/a.txt", "", map[string]string{"Range": "bytes=6-10"})
+	if resp.StatusCode != http.StatusPartialContent || body != "world" {
+		t.Errorf("ranged GET = %d %q; want 206 %q", resp.StatusCode, body, "world")
+	}
+	if got := resp.Header.Get("ETag"); got != helloETag {
+		t.Errorf("GET ETag = %s; want %s", got, helloETag)
+	}
+
+	resp, _ = do(t, srv, http.MethodHead, "/photos/missing.txt", "", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("HEAD missing = %d; want 404", resp.StatusCode)
+	}
+
+	var list struct {
+		Contents       []struct{ Key string }
+		CommonPrefixes []struct{ Prefix string }
+		IsTruncated    bool
+	}
+	_, body = do(t, srv, http.MethodGet, "/photos?list-type=2&delimiter=%2F", "", nil)
+	if err := xml.Unmarshal([]byte(body), &list); err != nil {
+		t.Fatalf("decode list: %v\n%s", err, body)
+	}
+	if len(list.Contents) != 1 || list.Contents[0].Key != "top.txt" || len(list.CommonPrefixes) != 2 || list.CommonPrefixes[0].Prefix != "2024/" {
+		t.Errorf("delimited list = %+v", list)
+	}
+
+	var keys []string
+	token := ""
+	for {
+		target := "/photos?list-type=2&max-keys=2&prefix=20"
+		if token != "" {
+			target += "&continuation-token=" + token
+		}
+		var page struct {
+			Contents              []struct{ Key string }
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		_, body = do(t, srv, http.MethodGet, target, "", nil)
+		if err := xml.Unmarshal([]byte(body), &page); err != nil {
+			t.Fatalf("decode page: %v", err)
+		}
+		for _, c := range page.Contents {
+			keys = append(keys, c.Key)
+		}
+		if !page.IsTruncated {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+	if strings.Join(keys, ",") != "2024/a.txt,2024/b.txt,2025/c.txt" {
+		t.Errorf("paged keys = %v", keys)
+	}
+
+	if resp, _ := do(t, srv, http.MethodDelete, "/photos", "", nil); resp.StatusCode != http.StatusConflict {
+		t.Errorf("DeleteBucket on non-empty bucket = %d; want 409", resp.StatusCode)
+	}
+	if resp, _ := do(t, srv, http.MethodDelete, "/photos/top.txt", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Errorf("DELETE = %d; want 204", resp.StatusCode)
+	}
+	if _, ok := files.contents["photos/top.txt"]; ok {
+		t.Error("object still stored after DELETE")
+	}
+}
+
+func TestS3_StreamingSignedPut(t *testing.T) {
+	srv, files := newTestServer(t)
+
+	if resp, body := do(t, srv, http.MethodPut, "/photos", "", nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("CreateBucket = %d %s", resp.StatusCode, body)
+	}
+
+	chunks := []string{strings.Repeat("a", 65536), strings.Repeat("b", 1000)}
+	if resp, body := doStreaming(t, srv, "/photos/stream.bin", chunks, false); resp.StatusCode != http.StatusOK {
+		t.Fatalf("streaming PUT = %d %s", resp.StatusCode, body)
+	}
+	if got := string(files.contents["photos/stream.bin"]); got != strings.Join(chunks, "") {
+		t.Errorf("stored %d bytes; want the %d decoded bytes", len(got), len(strings.Join(chunks, "")))
+	}
+
+	if resp, _ := doStreaming(t, srv, "/photos/tampered.bin", chunks, true); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("tampered streaming PUT = %d; want 403", resp.StatusCode)
+	}
+	if _, ok := files.contents["photos/tampered.bin"]; ok {
+		t.Error("tampered object was stored")
+	}
+}
+
+func TestS3_MultipartUpload(t *testing.T) {
+	srv, files := newTestServer(t)
+	do(t, srv, http.MethodPut, "/bkt", "", nil)
+
+	var initiate struct{ UploadId string }
+	_, body := do(t, srv, http.MethodPost, "/bkt/big.bin?uploads", "", nil)
+	if err := xml.Unmarshal([]byte(body), &initiate); err != nil || initiate.UploadId == "" {
+		t.Fatalf("CreateMultipartUpload: %v %s", err, body)
+	}
+
+	parts := []string{"first-", "second-", "third"}
+	partSums := md5.New()
+	var complete strings.Builder
+	complete.WriteString("<CompleteMultipartUpload>")
+	for i, p := range parts {
+		resp, body := do(t, srv, http.MethodPut, fmt.Sprintf("/bkt/big.bin?partNumber=%d&uploadId=%s", i+1, initiate.UploadId), p, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("UploadPart %d = %d %s", i+1, resp.StatusCode, body)
+		}
+		sum := md5.Sum([]byte(p))
+		partSums.Write(sum[:])
+		if want := `"` + hex.EncodeToString(sum[:]) + `"`; resp.Header.Get("ETag") != want {
+			t.Errorf("part ETag = %s; want %s", resp.Header.Get("ETag"), want)
+		}
+		fmt.Fprintf(&complete, "<Part><PartNumber>%d</PartNumber><ETag>%s</ETag></Part>", i+1, resp.Header.Get("ETag"))
+	}
+	complete.WriteString("</CompleteMultipartUpload>")
+
+	bad := strings.Replace(complete.String(), "<PartNumber>3</PartNumber>", "<PartNumber>4</PartNumber>", 1)
+	if resp, _ := do(t, srv, http.MethodPost, "/bkt/big.bin?uploadId="+initiate.UploadId, bad, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("complete with unknown part = %d; want 400", resp.StatusCode)
+	}
+
+	resp, body := do(t, srv, http.MethodPost, "/bkt/big.bin?uploadId="+initiate.UploadId, complete.String(), nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("CompleteMultipartUpload = %d %s", resp.StatusCode, body)
+	}
+	if got := string(files.contents["bkt/big.bin"]); got != "first-second-third" {
+		t.Errorf("assembled object = %q", got)
+	}
+	if len(files.streamed) != 1 {
+		t.Errorf("streamed %v; want the parts streamed to the uploader once", files.streamed)
+	}
+	var result struct{ ETag string }
+	if err := xml.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatalf("decode complete: %v", err)
+	}
+	wantETag := fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(partSums.Sum(nil)), len(parts))
+	if result.ETag != wantETag {
+		t.Errorf("multipart ETag = %s; want %s", result.ETag, wantETag)
+	}
+	if resp, _ := do(t, srv, http.MethodHead, "/bkt/big.bin", "", nil); resp.Header.Get("ETag") != wantETag {
+		t.Errorf("HEAD ETag = %s; want %s", resp.Header.Get("ETag"), wantETag)
+	}
+
+	if resp, _ := do(t, srv, http.MethodDelete, "/bkt/big.bin?uploadId="+initiate.UploadId, "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("abort after complete = %d; want 404", resp.StatusCode)
+	}
+}
+
+func TestS3_StateSurvivesRestart(t *testing.T) {
+	tmp := t.TempDir()
+	staging := filepath.Join(tmp, "staging")
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	files := &fakeFiles{store: store, contents: make(map[string][]byte)}
+	start := func() *httptest.Server {
+		t.Helper()
+		s, err := NewServer("", testAccessKey, testSecretKey, "1", staging, store, files)
+		if err != nil {
+			t.Fatalf("NewServer: %v", err)
+		}
+		srv := httptest.NewServer(s.Handler())
+		t.Cleanup(srv.Close)
+		return srv
+	}
+
+	srv := start()
+	do(t, srv, http.MethodPut, "/empty", "", nil)
+	do(t, srv, http.MethodPut, "/bkt", "", nil)
+	var initiate struct{ UploadId string }
+	_, body := do(t, srv, http.MethodPost, "/bkt/big.bin?uploads", "", nil)
+	if err := xml.Unmarshal([]byte(body), &initiate); err != nil || initiate.UploadId == "" {
+		t.Fatalf("CreateMultipartUpload: %v %s", err, body)
+	}
+	resp, _ := do(t, srv, http.MethodPut, "/bkt/big.bin?partNumber=1&uploadId="+initiate.UploadId, "part", nil)
+	partETag := resp.Header.Get("ETag")
+	srv.Close()
+
+	// A PUT cut off by the restart leaves its staging dir behind.
+	stale := filepath.Join(staging, "put-123")
+	if err := os.MkdirAll(stale, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	srv = start()
+	if resp, _ := do(t, srv, http.MethodHead, "/empty", "", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("HEAD empty bucket after restart = %d; want 200", resp.StatusCode)
+	}
+	complete := fmt.Sprintf("<CompleteMultipartUpload><Part><PartNumber>1</PartNumber><ETag>%s</ETag></Part></CompleteMultipartUpload>", partETag)
+	if resp, body := do(t, srv, http.MethodPost, "/bkt/big.bin?uploadId="+initiate.UploadId, complete, nil); resp.StatusCode != http.StatusOK {
+		t.Fatalf("CompleteMultipartUpload after restart = %d %s", resp.StatusCode, body)
+	}
+	if got := string(files.contents["bkt/big.bin"]); got != "part" {
+		t.Errorf("assembled object = %q; want %q", got, "part")
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("stale staging dir kept: %v", err)
+	}
+
+	if resp, _ := do(t, srv, http.MethodDelete, "/empty", "", nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("DELETE empty bucket = %d; want 204", resp.StatusCode)
+	}
+	srv.Close()
+	srv = start()
+	if resp, _ := do(t, srv, http.MethodHead, "/empty", "", nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("HEAD deleted bucket after restart = %d; want 404", resp.StatusCode)
+	}
+}
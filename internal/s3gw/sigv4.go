@@ -0,0 +1,450 @@
+package s3gw
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	signAlgorithm   = "AWS4-HMAC-SHA256"
+	amzDateFormat   = "20060102T150405Z"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	maxClockSkew    = 15 * time.Minute
+
+	streamingPayload         = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingPayloadTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsignedTrailer = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+)
+
+var (
+	errMissingAuth     = &apiError{http.StatusForbidden, "AccessDenied", "Missing or unsupported authorization"}
+	errUnknownKey      = &apiError{http.StatusForbidden, "InvalidAccessKeyId", "The access key ID does not exist"}
+	errSignature       = &apiError{http.StatusForbidden, "SignatureDoesNotMatch", "The request signature does not match"}
+	errSkewed          = &apiError{http.StatusForbidden, "RequestTimeTooSkewed", "The difference between the request time and the server's time is too large"}
+	errStreamingUnsup  = &apiError{http.StatusNotImplemented, "NotImplemented", "Streaming payload signatures are not supported"}
+	errPayloadMismatch = &apiError{http.StatusBadRequest, "XAmzContentSHA256Mismatch", "The provided x-amz-content-sha256 does not match the payload"}
+	errChunkEncoding   = &apiError{http.StatusBadRequest, "IncompleteBody", "The aws-chunked request body is malformed"}
+)
+
+type credential struct {
+	accessKey string
+	date      string
+	region    string
+	service   string
+}
+
+func (c credential) scope() string {
+	return strings.Join([]string{c.date, c.region, c.service, "aws4_request"}, "/")
+}
+
+type authHeader struct {
+	cred          credential
+	signedHeaders []string
+	signature     string
+}
+
+func parseAuthHeader(v string) (*authHeader, error) {
+	if !strings.HasPrefix(v, signAlgorithm+" ") {
+		return nil, errMissingAuth
+	}
+
+	var h authHeader
+	for _, part := range strings.Split(strings.TrimPrefix(v, signAlgorithm+" "), ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			return nil, errMissingAuth
+		}
+		switch key {
+		case "Credential":
+			fields := strings.Split(value, "/")
+			if len(fields) != 5 || fields[4] != "aws4_request" {
+				return nil, errMissingAuth
+			}
+			h.cred = credential{accessKey: fields[0], date: fields[1], region: fields[2], service: fields[3]}
+		case "SignedHeaders":
+			h.signedHeaders = strings.Split(value, ";")
+		case "Signature":
+			h.signature = value
+		}
+	}
+	if h.cred.accessKey == "" || len(h.signedHeaders) == 0 || h.signature == "" {
+		return nil, errMissingAuth
+	}
+	return &h, nil
+}
+
+// verifyRequest checks the SigV4 Authorization header of r against
+// secretKey. When the payload hash is signed the body is wrapped so that a
+// mismatch surfaces as an error once it has been fully read. Streaming
+// (aws-chunked) bodies are decoded and each chunk signature is checked as it
+// arrives.
+func verifyRequest(r *http.Request, accessKey, secretKey string, now time.Time) error {
+	h, err := parseAuthHeader(r.Header.Get("Authorization"))
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(h.cred.accessKey), []byte(accessKey)) {
+		return errUnknownKey
+	}
+
+	// Without X-Amz-Date the request is dated by the standard HTTP Date
+	// header; the string to sign still carries the ISO 8601 form.
+	var t time.Time
+	if v := r.Header.Get("X-Amz-Date"); v != "" {
+		t, err = time.Parse(amzDateFormat, v)
+	} else {
+		t, err = http.ParseTime(r.Header.Get("Date"))
+	}
+	if err != nil {
+		return errMissingAuth
+	}
+	amzDate := t.UTC().Format(amzDateFormat)
+	if d := now.Sub(t); d > maxClockSkew || d < -maxClockSkew {
+		return errSkewed
+	}
+	if t.Format("20060102") != h.cred.date {
+		return errSignature
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash == "" {
+		return errMissingAuth
+	}
+	streaming := payloadHash == streamingPayload || payloadHash == streamingPayloadTrailer || payloadHash == streamingUnsignedTrailer
+	if strings.HasPrefix(payloadHash, "STREAMING-") && !streaming {
+		return errStreamingUnsup
+	}
+
+	canonical := canonicalRequest(r, h.signedHeaders, payloadHash)
+	sum := sha256.Sum256([]byte(canonical))
+	stringToSign := strings.Join([]string{
+		signAlgorithm,
+		amzDate,
+		h.cred.scope(),
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+
+	key := signingKey(secretKey, h.cred)
+	want := hex.EncodeToString(hmacSHA256(key, []byte(stringToSign)))
+	if !hmac.Equal([]byte(want), []byte(h.signature)) {
+		return errSignature
+	}
+
+	if streaming && r.Body != nil {
+		c := &chunkedReader{
+			r:       r.Body,
+			br:      bufio.NewReader(r.Body),
+			trailer: payloadHash != streamingPayload,
+		}
+		if payloadHash != streamingUnsignedTrailer {
+			c.key = key
+			c.amzDate = amzDate
+			c.scope = h.cred.scope()
+			c.prevSig = h.signature
+		}
+		r.Body = c
+		return nil
+	}
+
+	if payloadHash != unsignedPayload && r.Body != nil {
+		expected, err := hex.DecodeString(payloadHash)
+		if err != nil || len(expected) != sha256.Size {
+			return errPayloadMismatch
+		}
+		r.Body = &verifyingReader{r: r.Body, h: sha256.New(), expected: expected}
+	}
+
+	return nil
+}
+
+func signingKey(secret string, c credential) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), []byte(c.date))
+	k = hmacSHA256(k, []byte(c.region))
+	k = hmacSHA256(k, []byte(c.service))
+	return hmacSHA256(k, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func canonicalRequest(r *http.Request, signedHeaders []string, payloadHash string) string {
+	var headers strings.Builder
+	for _, name := range signedHeaders {
+		var values []string
+		if name == "host" {
+			values = []string{r.Host}
+		} else {
+			values = append([]string(nil), r.Header.Values(name)...)
+		}
+		for i, v := range values {
+			values[i] = strings.Join(strings.Fields(v), " ")
+		}
+		headers.WriteString(name + ":" + strings.Join(values, ",") + "\n")
+	}
+
+	return strings.Join([]string{
+		r.Method,
+		uriEncode(r.URL.Path, false),
+		canonicalQuery(r.URL.Query()),
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode implements the AWS flavour of percent-encoding, which differs
+// from url.QueryEscape in its treatment of spaces and '~'.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+type verifyingReader struct {
+	r        io.ReadCloser
+	h        hash.Hash
+	expected []byte
+}
+
+func (v *verifyingReader) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if errors.Is(err, io.EOF) && !bytes.Equal(v.h.Sum(nil), v.expected) {
+		return n, errPayloadMismatch
+	}
+	return n, err
+}
+
+func (v *verifyingReader) Close() error {
+	return v.r.Close()
+}
+
+// emptySHA256 is the hex SHA-256 of an empty string, which stands in for the
+// (absent) chunk headers in a chunk's string to sign.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// chunkedReader decodes an aws-chunked body. Each chunk is framed as
+// "<hex size>[;chunk-signature=<sig>]\r\n<data>\r\n" and the body ends with
+// a zero-sized chunk, optionally followed by trailing headers. When key is
+// set every chunk signature is chained from the previous one, starting with
+// the seed signature of the Authorization header; like verifyingReader, a
+// mismatch surfaces once the offending chunk has been read.
+type chunkedReader struct {
+	r       io.ReadCloser
+	br      *bufio.Reader
+	trailer bool
+
+	key     []byte
+	amzDate string
+	scope   string
+	prevSig string
+
+	left int64
+	sig  string
+	h    hash.Hash
+	err  error
+}
+
+func (c *chunkedReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.left == 0 {
+		if err := c.nextChunk(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+
+	if int64(len(p)) > c.left {
+		p = p[:c.left]
+	}
+	n, err := c.br.Read(p)
+	c.h.Write(p[:n])
+	c.left -= int64(n)
+	if c.left == 0 {
+		if err := c.endChunk(); err != nil {
+			c.err = err
+			return n, err
+		}
+	} else if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errChunkEncoding
+		}
+		c.err = err
+		return n, err
+	}
+	return n, nil
+}
+
+// nextChunk reads the next chunk header. A zero-sized chunk ends the body and
+// is verified, together with any trailer, before io.EOF is returned.
+func (c *chunkedReader) nextChunk() error {
+	line, err := c.readLine()
+	if err != nil {
+		return err
+	}
+	sizeHex, ext, _ := strings.Cut(line, ";")
+	size, err := strconv.ParseInt(sizeHex, 16, 64)
+	if err != nil || size < 0 {
+		return errChunkEncoding
+	}
+	c.sig = ""
+	if c.key != nil {
+		sig, ok := strings.CutPrefix(ext, "chunk-signature=")
+		if !ok {
+			return errSignature
+		}
+		c.sig = sig
+	}
+	c.left = size
+	c.h = sha256.New()
+	if size > 0 {
+		return nil
+	}
+
+	if err := c.verifyChunk(); err != nil {
+		return err
+	}
+	if c.trailer {
+		if err := c.readTrailer(); err != nil {
+			return err
+		}
+	} else if line, err := c.readLine(); err != nil || line != "" {
+		return errChunkEncoding
+	}
+	return io.EOF
+}
+
+// endChunk consumes the CRLF that closes a chunk's data and checks its
+// signature.
+func (c *chunkedReader) endChunk() error {
+	if line, err := c.readLine(); err != nil || line != "" {
+		return errChunkEncoding
+	}
+	return c.verifyChunk()
+}
+
+func (c *chunkedReader) verifyChunk() error {
+	if c.key == nil {
+		return nil
+	}
+	stringToSign := strings.Join([]string{
+		signAlgorithm + "-PAYLOAD",
+		c.amzDate,
+		c.scope,
+		c.prevSig,
+		emptySHA256,
+		hex.EncodeToString(c.h.Sum(nil)),
+	}, "\n")
+	return c.checkSignature(stringToSign, c.sig)
+}
+
+// readTrailer consumes the trailing headers up to the closing empty line and,
+// for signed streams, checks x-amz-trailer-signature over them.
+func (c *chunkedReader) readTrailer() error {
+	var canonical strings.Builder
+	var sig string
+	for {
+		line, err := c.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return errChunkEncoding
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if name == "x-amz-trailer-signature" {
+			sig = value
+			continue
+		}
+		canonical.WriteString(name + ":" + value + "\n")
+	}
+
+	if c.key == nil {
+		return nil
+	}
+	sum := sha256.Sum256([]byte(canonical.String()))
+	stringToSign := strings.Join([]string{
+		signAlgorithm + "-TRAILER",
+		c.amzDate,
+		c.scope,
+		c.prevSig,
+		hex.EncodeToString(sum[:]),
+	}, "\n")
+	return c.checkSignature(stringToSign, sig)
+}
+
+func (c *chunkedReader) checkSignature(stringToSign, sig string) error {
+	want := hex.EncodeToString(hmacSHA256(c.key, []byte(stringToSign)))
+	if !hmac.Equal([]byte(want), []byte(sig)) {
+		return errSignature
+	}
+	c.prevSig = sig
+	return nil
+}
+
+func (c *chunkedReader) readLine() (string, error) {
+	line, err := c.br.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return "", errChunkEncoding
+		}
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), nil
+}
+
+func (c *chunkedReader) Close() error {
+	return c.r.Close()
+}
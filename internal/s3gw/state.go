@@ -0,0 +1,124 @@
+package s3gw
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	bucketsFile = "buckets.json"
+	uploadFile  = "upload.json"
+
+	// uploadExpiry drops multipart uploads no part was added to for this
+	// long, as a client that gave up never aborts them.
+	uploadExpiry = 7 * 24 * time.Hour
+)
+
+// uploadState is what upload.json in a multipart upload's staging dir
+// records, so that the upload survives the server being restarted.
+type uploadState struct {
+	Bucket string         `json:"bucket"`
+	Key    string         `json:"key"`
+	Parts  map[int]string `json:"parts"`
+}
+
+// load restores the empty buckets and the multipart uploads recorded under
+// the staging dir. Staging dirs that no live upload claims, left behind by
+// requests that were cut off or by expired uploads, are removed.
+func (s *Server) load() error {
+	data, err := os.ReadFile(filepath.Join(s.stagingDir, bucketsFile))
+	if err == nil {
+		var names []string
+		if err := json.Unmarshal(data, &names); err != nil {
+			return fmt.Errorf("read %s: %w", bucketsFile, err)
+		}
+		for _, name := range names {
+			s.buckets[name] = true
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	entries, err := os.ReadDir(s.stagingDir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(s.stagingDir, e.Name())
+		if id, ok := strings.CutPrefix(e.Name(), "mpu-"); ok {
+			up, err := s.readUpload(dir)
+			if err == nil {
+				s.uploads[id] = up
+				continue
+			}
+			log.Printf("s3 dropping multipart upload %s: %v", id, err)
+		} else if !strings.HasPrefix(e.Name(), "put-") {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Printf("s3 remove stale staging dir: %v", err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) readUpload(dir string) (*multipartUpload, error) {
+	p := filepath.Join(dir, uploadFile)
+	info, err := os.Stat(p)
+	if err != nil {
+		return nil, err
+	}
+	if s.now().Sub(info.ModTime()) > uploadExpiry {
+		return nil, fmt.Errorf("no part added since %s", info.ModTime().Format(time.DateOnly))
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	var st uploadState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	if st.Parts == nil {
+		st.Parts = make(map[int]string)
+	}
+	return &multipartUpload{bucket: st.Bucket, key: st.Key, dir: dir, parts: st.Parts}, nil
+}
+
+// saveUpload records up in its staging dir. The caller must hold mu.
+func saveUpload(up *multipartUpload) error {
+	return writeJSON(filepath.Join(up.dir, uploadFile), uploadState{Bucket: up.bucket, Key: up.key, Parts: up.parts})
+}
+
+// saveBuckets records the buckets made by CreateBucket. The caller must
+// hold mu.
+func (s *Server) saveBuckets() error {
+	names := make([]string, 0, len(s.buckets))
+	for name := range s.buckets {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return writeJSON(filepath.Join(s.stagingDir, bucketsFile), names)
+}
+
+func writeJSON(p string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
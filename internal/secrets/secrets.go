@@ -0,0 +1,34 @@
+// Package secrets keeps credentials such as bot tokens out of config.json.
+package secrets
+
+import "errors"
+
+var (
+	ErrNotFound      = errors.New("secret not found")
+	ErrLocked        = errors.New("secret store is locked")
+	ErrWrongPassword = errors.New("wrong master password")
+)
+
+// Store holds named secrets. Get returns ErrNotFound for a name that was
+// never set and ErrLocked while the store cannot be read. The encrypted
+// vault is the only backend today; an OS keychain would plug in here.
+type Store interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// Lister is a Store that can enumerate the names it holds, so that
+// secrets nothing refers to any more can be cleaned up.
+type Lister interface {
+	Names() ([]string, error)
+}
+
+// Status describes the active secret store for the settings UI.
+type Status struct {
+	Backend string `json:"backend"`
+	Exists  bool   `json:"exists"`
+	Locked  bool   `json:"locked"`
+}
+
+const BackendVault = "vault"
@@ -0,0 +1,246 @@
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// scrypt cost parameters for new vaults. They are stored in the file so
+// they can be raised later without breaking existing vaults.
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+const vaultVersion = 1
+
+type vaultFile struct {
+	Version int    `json:"version"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Vault is a Store kept in a local file encrypted with AES-256-GCM under a
+// key derived from a master password. It starts locked; Unlock creates the
+// file on first use.
+type Vault struct {
+	path string
+
+	mu      sync.Mutex
+	file    *vaultFile
+	key     []byte
+	secrets map[string]string
+}
+
+func NewVault(path string) *Vault {
+	return &Vault{path: path}
+}
+
+func (v *Vault) Path() string {
+	return v.path
+}
+
+// Exists reports whether the vault file has been created.
+func (v *Vault) Exists() bool {
+	_, err := os.Stat(v.path)
+	return err == nil
+}
+
+func (v *Vault) Locked() bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.key == nil
+}
+
+// Unlock decrypts the vault with password, or creates an empty vault
+// protected by it when the file does not exist yet.
+func (v *Vault) Unlock(password string) error {
+	if password == "" {
+		return errors.New("master password must not be empty")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	data, err := os.ReadFile(v.path)
+	if os.IsNotExist(err) {
+		f := &vaultFile{Version: vaultVersion, N: scryptN, R: scryptR, P: scryptP, Salt: make([]byte, 16)}
+		if _, err := rand.Read(f.Salt); err != nil {
+			return err
+		}
+		key, err := f.deriveKey(password)
+		if err != nil {
+			return err
+		}
+		v.file, v.key, v.secrets = f, key, make(map[string]string)
+		return v.save()
+	} else if err != nil {
+		return err
+	}
+
+	var f vaultFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parse vault: %w", err)
+	}
+	if f.Version != vaultVersion {
+		return fmt.Errorf("unsupported vault version %d", f.Version)
+	}
+	key, err := f.deriveKey(password)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return err
+	}
+	plain, err := gcm.Open(nil, f.Nonce, f.Data, nil)
+	if err != nil {
+		return ErrWrongPassword
+	}
+	secrets := make(map[string]string)
+	if err := json.Unmarshal(plain, &secrets); err != nil {
+		return fmt.Errorf("parse vault contents: %w", err)
+	}
+	v.file, v.key, v.secrets = &f, key, secrets
+	return nil
+}
+
+// Lock forgets the key and the decrypted secrets.
+func (v *Vault) Lock() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.file, v.key, v.secrets = nil, nil, nil
+}
+
+// ChangePassword re-encrypts an unlocked vault under a new password and a
+// fresh salt.
+func (v *Vault) ChangePassword(password string) error {
+	if password == "" {
+		return errors.New("master password must not be empty")
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+
+	f := &vaultFile{Version: vaultVersion, N: scryptN, R: scryptR, P: scryptP, Salt: make([]byte, 16)}
+	if _, err := rand.Read(f.Salt); err != nil {
+		return err
+	}
+	key, err := f.deriveKey(password)
+	if err != nil {
+		return err
+	}
+	v.file, v.key = f, key
+	return v.save()
+}
+
+func (v *Vault) Get(name string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return "", ErrLocked
+	}
+	value, ok := v.secrets[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+// Names returns the names of the stored secrets, sorted.
+func (v *Vault) Names() ([]string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return nil, ErrLocked
+	}
+	return slices.Sorted(maps.Keys(v.secrets)), nil
+}
+
+func (v *Vault) Set(name, value string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	if old, ok := v.secrets[name]; ok && old == value {
+		return nil
+	}
+	v.secrets[name] = value
+	return v.save()
+}
+
+func (v *Vault) Delete(name string) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.key == nil {
+		return ErrLocked
+	}
+	if _, ok := v.secrets[name]; !ok {
+		return nil
+	}
+	delete(v.secrets, name)
+	return v.save()
+}
+
+// save encrypts the secrets under a new nonce and replaces the file. The
+// caller must hold v.mu.
+func (v *Vault) save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	gcm, err := newGCM(v.key)
+	if err != nil {
+		return err
+	}
+	v.file.Nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(v.file.Nonce); err != nil {
+		return err
+	}
+	v.file.Data = gcm.Seal(nil, v.file.Nonce, plain, nil)
+
+	data, err := json.Marshal(v.file)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(v.path), 0o700); err != nil {
+		return err
+	}
+	tmp := v.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, v.path)
+}
+
+func (f *vaultFile) deriveKey(password string) ([]byte, error) {
+	return scrypt.Key([]byte(password), f.Salt, f.N, f.R, f.P, 32)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
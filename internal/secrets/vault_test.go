@@ -0,0 +1,69 @@
+package secrets
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.vault")
+	v := NewVault(path)
+	if !v.Locked() || v.Exists() {
+		t.Fatal("a new vault should be locked and not exist yet")
+	}
+	if _, err := v.Get("bot_token"); !errors.Is(err, ErrLocked) {
+		t.Errorf("Get on a locked vault = %v; want ErrLocked", err)
+	}
+
+	if err := v.Unlock("hunter2"); err != nil {
+		t.Fatalf("Unlock (create): %v", err)
+	}
+	if err := v.Set("bot_token", "123:ABC"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := v.Set("s3_secret_key", "s3cr3t"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := v.Delete("s3_secret_key"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read vault: %v", err)
+	}
+	if strings.Contains(string(data), "123:ABC") {
+		t.Error("vault file contains the secret in plaintext")
+	}
+
+	reopened := NewVault(path)
+	if err := reopened.Unlock("wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Unlock with a wrong password = %v; want ErrWrongPassword", err)
+	}
+	if err := reopened.Unlock("hunter2"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if got, err := reopened.Get("bot_token"); err != nil || got != "123:ABC" {
+		t.Errorf("Get = %q, %v; want the stored token", got, err)
+	}
+	if _, err := reopened.Get("s3_secret_key"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a deleted secret = %v; want ErrNotFound", err)
+	}
+
+	if err := reopened.ChangePassword("correct horse"); err != nil {
+		t.Fatalf("ChangePassword: %v", err)
+	}
+	reopened.Lock()
+	if err := reopened.Unlock("hunter2"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("Unlock with the old password = %v; want ErrWrongPassword", err)
+	}
+	if err := reopened.Unlock("correct horse"); err != nil {
+		t.Fatalf("Unlock with the new password: %v", err)
+	}
+	if got, _ := reopened.Get("bot_token"); got != "123:ABC" {
+		t.Errorf("Get after password change = %q", got)
+	}
+}
@@ -0,0 +1,183 @@
+package share
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"tstore/pkg/model"
+)
+
+const (
+	Version = 1
+
+	// TokenPrefix marks a manifest encoded as a single copy-pasteable string.
+	TokenPrefix = "tstore-share:"
+)
+
+var ErrEncrypted = errors.New("share is encrypted; encrypted shares are not supported yet")
+
+// Manifest is everything another tstore instance needs to fetch a file
+// through its own bots. Telegram scopes file IDs to a bot, so the importing
+// instance must be able to resolve them: chunks on the primary chat with its
+// primary bot, e.g. because it shares the token, and chunks on a storage
+// target with its target of the same name.
+type Manifest struct {
+	Version         int                     `json:"v"`
+	Name            string                  `json:"name"`
+	Size            int64                   `json:"size"`
+	Checksum        string                  `json:"sha256"`
+	ChunkSize       int64                   `json:"chunk_size,omitempty"`
+	ChunkIds        []string                `json:"chunks"`
+	ChunkTargets    []string                `json:"chunk_targets,omitempty"`
+	Replicas        [][]model.ChunkLocation `json:"replicas,omitempty"`
+	ChunkChecksums  []string                `json:"chunk_sha256,omitempty"`
+	DataShards      int                     `json:"data_shards,omitempty"`
+	ParityShards    int                     `json:"parity_shards,omitempty"`
+	ParityChunks    []model.ChunkLocation   `json:"parity_chunks,omitempty"`
+	ParityChecksums []string                `json:"parity_sha256,omitempty"`
+	Key             string                  `json:"key,omitempty"`
+}
+
+func FromRecord(rec *model.FileRecord) (*Manifest, error) {
+	if len(rec.ChunkIds) == 0 {
+		return nil, fmt.Errorf("%q has no uploaded chunks", rec.Name)
+	}
+
+	m := &Manifest{
+		Version:         Version,
+		Name:            path.Base(rec.Name),
+		Size:            rec.Size,
+		Checksum:        rec.Checksum,
+		ChunkSize:       rec.ChunkSize,
+		ChunkIds:        slices.Clone(rec.ChunkIds),
+		ChunkTargets:    slices.Clone(rec.ChunkTargets),
+		ChunkChecksums:  slices.Clone(rec.ChunkChecksums),
+		DataShards:      rec.DataShards,
+		ParityShards:    rec.ParityShards,
+		ParityChunks:    slices.Clone(rec.ParityChunks),
+		ParityChecksums: slices.Clone(rec.ParityChecksums),
+	}
+	for _, locs := range rec.Replicas {
+		m.Replicas = append(m.Replicas, slices.Clone(locs))
+	}
+	return m, nil
+}
+
+// Record describes where the shared chunks are stored, for reading them
+// back with the uploader's usual replica and parity fallbacks.
+func (m *Manifest) Record() *model.FileRecord {
+	return &model.FileRecord{
+		Name:            m.Name,
+		Size:            m.Size,
+		Checksum:        m.Checksum,
+		ChunkSize:       m.ChunkSize,
+		ChunkIds:        m.ChunkIds,
+		ChunkTargets:    m.ChunkTargets,
+		Replicas:        m.Replicas,
+		ChunkChecksums:  m.ChunkChecksums,
+		DataShards:      m.DataShards,
+		ParityShards:    m.ParityShards,
+		ParityChunks:    m.ParityChunks,
+		ParityChecksums: m.ParityChecksums,
+	}
+}
+
+func (m *Manifest) Validate() error {
+	switch {
+	case m.Version != Version:
+		return fmt.Errorf("unsupported share version %d", m.Version)
+	case m.Name == "":
+		return errors.New("share has no file name")
+	case m.Size <= 0:
+		return fmt.Errorf("invalid share size %d", m.Size)
+	case len(m.Checksum) != 64:
+		return errors.New("share has no valid sha256 checksum")
+	case len(m.ChunkIds) == 0:
+		return errors.New("share has no chunks")
+	case m.ChunkTargets != nil && len(m.ChunkTargets) != len(m.ChunkIds):
+		return errors.New("share lists a target for some chunks but not others")
+	case m.Key != "":
+		return ErrEncrypted
+	}
+	return nil
+}
+
+// Token encodes m as deflated, base64url JSON behind TokenPrefix.
+func (m *Manifest) Token() (string, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	w, err := flate.NewWriter(&b, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	return TokenPrefix + base64.RawURLEncoding.EncodeToString(b.Bytes()), nil
+}
+
+func ParseToken(token string) (*Manifest, error) {
+	encoded, ok := strings.CutPrefix(strings.TrimSpace(token), TokenPrefix)
+	if !ok {
+		return nil, errors.New("not a tstore share token")
+	}
+	compressed, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("decode share token: %w", err)
+	}
+	data, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("inflate share token: %w", err)
+	}
+
+	return parseJSON(data)
+}
+
+func parseJSON(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parse share manifest: %w", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Manifest) Save(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(string(data))
+}
+
+// Parse accepts either a share token or a manifest file's JSON.
+func Parse(s string) (*Manifest, error) {
+	if strings.HasPrefix(strings.TrimSpace(s), TokenPrefix) {
+		return ParseToken(s)
+	}
+	return parseJSON([]byte(s))
+}
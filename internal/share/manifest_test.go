@@ -0,0 +1,92 @@
+package share
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"tstore/pkg/model"
+)
+
+func testRecord() *model.FileRecord {
+	return &model.FileRecord{
+		Name:         "docs/report.pdf",
+		Size:         1234,
+		Checksum:     strings.Repeat("ab", 32),
+		ChunkIds:     []string{"BQACAgIAAxkDAAIB", "BQACAgIAAxkDAAIC"},
+		ChunkSize:    1024,
+		ChunkTargets: []string{"", "backup"},
+		Replicas:     [][]model.ChunkLocation{{{Target: "spare", FileID: "BQACAgIAAxkDAAID"}}, nil},
+	}
+}
+
+func TestManifest_TokenRoundTrip(t *testing.T) {
+	m, err := FromRecord(testRecord())
+	if err != nil {
+		t.Fatalf("FromRecord: %v", err)
+	}
+	if m.Name != "report.pdf" {
+		t.Errorf("Name = %q; want base name", m.Name)
+	}
+	if rec := m.Record(); !reflect.DeepEqual(rec.ChunkTargets, testRecord().ChunkTargets) || !reflect.DeepEqual(rec.Replicas, testRecord().Replicas) {
+		t.Errorf("Record() locations = %v %v; want the source record's", rec.ChunkTargets, rec.Replicas)
+	}
+
+	token, err := m.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if !strings.HasPrefix(token, TokenPrefix) {
+		t.Errorf("token %q lacks prefix", token)
+	}
+
+	got, err := Parse(token)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("Parse(Token()) = %+v; want %+v", got, m)
+	}
+}
+
+func TestManifest_SaveLoad(t *testing.T) {
+	m, _ := FromRecord(testRecord())
+	p := filepath.Join(t.TempDir(), "report.tshare")
+	if err := m.Save(p); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := Load(p)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("Load = %+v; want %+v", got, m)
+	}
+}
+
+func TestManifest_Validate(t *testing.T) {
+	if _, err := FromRecord(&model.FileRecord{Name: "x"}); err == nil {
+		t.Error("FromRecord accepted a record without chunks")
+	}
+
+	m, _ := FromRecord(testRecord())
+	m.Key = "secret"
+	if err := m.Validate(); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("Validate with key = %v; want ErrEncrypted", err)
+	}
+
+	m, _ = FromRecord(testRecord())
+	m.ChunkTargets = m.ChunkTargets[:1]
+	if err := m.Validate(); err == nil {
+		t.Error("Validate accepted targets for only some chunks")
+	}
+
+	if _, err := Parse(`{"v":1,"name":"a","size":1,"sha256":"short","chunks":["x"]}`); err == nil {
+		t.Error("Parse accepted a manifest with a bad checksum")
+	}
+	if _, err := Parse(TokenPrefix + "!!!"); err == nil {
+		t.Error("Parse accepted a corrupt token")
+	}
+}
@@ -3,6 +3,7 @@ package sync
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 )
@@ -60,3 +61,32 @@ func MoveFile(src, dst string) error {
 
 	return nil
 }
+
+// MoveFolder moves every file under src to the same relative path under
+// dst, skipping partial ".tmp" files. Files that already exist in dst are
+// left in src and returned in conflicts.
+func MoveFolder(src, dst string) (moved int, conflicts []string, err error) {
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !d.Type().IsRegular() || filepath.Ext(path) == ".tmp" {
+			return nil
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if _, err := os.Lstat(target); err == nil {
+			conflicts = append(conflicts, filepath.ToSlash(rel))
+			return nil
+		}
+		if err := MoveFile(path, target); err != nil {
+			return err
+		}
+		moved++
+		return nil
+	})
+	return moved, conflicts, err
+}
@@ -60,3 +60,49 @@ func TestMoveFile_SrcIsDir(t *testing.T) {
 		t.Fatal("expected an error when source is a directory, got nil")
 	}
 }
+
+func TestMoveFolder(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "old")
+	dst := filepath.Join(tmp, "new")
+	for name, data := range map[string]string{
+		"a.txt":           "a",
+		"photos/b.jpg":    "b",
+		"taken.txt":       "mine",
+		"upload.bin.tmp":  "partial",
+		"photos/deep/c.z": "c",
+	} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		t.Fatalf("mkdir dst: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dst, "taken.txt"), []byte("theirs"), 0o644); err != nil {
+		t.Fatalf("write conflict: %v", err)
+	}
+
+	moved, conflicts, err := MoveFolder(src, dst)
+	if err != nil {
+		t.Fatalf("MoveFolder: %v", err)
+	}
+	if moved != 3 || len(conflicts) != 1 || conflicts[0] != "taken.txt" {
+		t.Errorf("MoveFolder = %d moved, conflicts %v; want 3 and [taken.txt]", moved, conflicts)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "photos", "deep", "c.z")); err != nil || string(data) != "c" {
+		t.Errorf("nested file = %q, %v", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dst, "taken.txt")); string(data) != "theirs" {
+		t.Errorf("existing file was overwritten with %q", data)
+	}
+	for _, name := range []string{"taken.txt", "upload.bin.tmp"} {
+		if _, err := os.Stat(filepath.Join(src, name)); err != nil {
+			t.Errorf("%s should stay in the old folder: %v", name, err)
+		}
+	}
+}
@@ -2,9 +2,11 @@ package sync
 
 import (
 	"context"
+	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 	"tstore/internal/metadata"
@@ -16,6 +18,10 @@ import (
 
 const stabilityDelay = 1 * time.Second
 
+// StartSyncWatcher reports new files anywhere under dir to onDetect and
+// keeps store in step with renames and removals until ctx is cancelled.
+// Files are named by their slash-separated path relative to dir, as
+// records are; subdirectories are watched as they appear.
 func StartSyncWatcher(
 	ctx context.Context,
 	dir string,
@@ -35,11 +41,92 @@ func StartSyncWatcher(
 		pendingRename string
 	)
 
+	relName := func(path string) string {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return filepath.Base(path)
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	// recordsUnder returns the record called name and, when name is a
+	// directory, every record inside it.
+	recordsUnder := func(name string) []*model.FileRecord {
+		recs, err := store.List(ctx)
+		if err != nil {
+			log.Printf("watcher: list metadata: %v", err)
+			return nil
+		}
+		var under []*model.FileRecord
+		for _, rec := range recs {
+			if rec.Name == name || strings.HasPrefix(rec.Name, name+"/") {
+				under = append(under, rec)
+			}
+		}
+		return under
+	}
+
+	// schedule hands an untracked file to onDetect once it has stopped
+	// changing. The caller must hold mu.
+	schedule := func(path string) {
+		name := relName(path)
+		if _, err := store.Get(ctx, name); err == nil {
+			return
+		}
+		if t, ok := timers[path]; ok {
+			t.Stop()
+		}
+		timers[path] = time.AfterFunc(stabilityDelay, func() {
+			mu.Lock()
+			delete(timers, path)
+			mu.Unlock()
+			onDetect(ctx, path, name)
+		})
+	}
+
+	// addTree watches root and every directory below it. With detect set
+	// the files already inside are scheduled too, since they may have
+	// been created before the watch was in place. The caller must hold mu
+	// when detect is set.
+	addTree := func(root string, detect bool) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if path == root {
+					return err
+				}
+				log.Printf("watcher: %v", err)
+				return nil
+			}
+			if d.IsDir() {
+				if err := watcher.Add(path); err != nil {
+					if path == root {
+						return err
+					}
+					log.Printf("watcher: watch %s: %v", path, err)
+				}
+				return nil
+			}
+			if detect && filepath.Ext(path) != ".tmp" {
+				schedule(path)
+			}
+			return nil
+		})
+	}
+
 	go func() {
 		defer watcher.Close()
 		for {
 			select {
 			case <-ctx.Done():
+				// Hand files still waiting out the stability delay to
+				// onDetect now so a restarted watcher does not lose them.
+				mu.Lock()
+				for path, t := range timers {
+					if t.Stop() {
+						go onDetect(ctx, path, relName(path))
+					}
+				}
+				mu.Unlock()
 				return
 			case ev, ok := <-watcher.Events:
 				if !ok {
@@ -48,13 +135,18 @@ func StartSyncWatcher(
 
 				if ev.Op&fsnotify.Remove != 0 {
 					if filepath.Ext(ev.Name) != ".tmp" {
-						base := filepath.Base(ev.Name)
 						mu.Lock()
-						rec, err := store.Get(ctx, base)
-						if err == nil {
+						removed := false
+						for _, rec := range recordsUnder(relName(ev.Name)) {
+							if rec.State == model.StateCloud {
+								continue
+							}
 							rec.State = model.StateCloud
 							store.Update(ctx, rec)
-							runtime.EventsEmit(ctx, "fileRemoved", base)
+							runtime.EventsEmit(ctx, "fileRemoved", rec.Name)
+							removed = true
+						}
+						if removed {
 							onRemove(ctx)
 						}
 						mu.Unlock()
@@ -70,20 +162,16 @@ func StartSyncWatcher(
 				}
 
 				info, err := os.Stat(ev.Name)
-				if err != nil {
-					if ev.Op&fsnotify.Rename == 0 {
-						continue
-					}
-				} else if info.IsDir() {
+				if err != nil && ev.Op&fsnotify.Rename == 0 {
 					continue
 				}
-
-				base := filepath.Base(ev.Name)
+				isDir := err == nil && info.IsDir()
+				name := relName(ev.Name)
 
 				mu.Lock()
 				if ev.Op&fsnotify.Rename != 0 {
-					if _, err := store.Get(ctx, base); err == nil {
-						pendingRename = base
+					if len(recordsUnder(name)) > 0 {
+						pendingRename = name
 					}
 					mu.Unlock()
 					continue
@@ -92,33 +180,36 @@ func StartSyncWatcher(
 				if ev.Op&fsnotify.Create != 0 && pendingRename != "" {
 					old := pendingRename
 					pendingRename = ""
-					rec, err := store.Get(ctx, old)
-					if err == nil {
-						store.Delete(ctx, old)
-						rec.Name = base
+					renamed := false
+					for _, rec := range recordsUnder(old) {
+						oldName := rec.Name
+						store.Delete(ctx, oldName)
+						rec.Name = name + strings.TrimPrefix(oldName, old)
 						if err := store.Create(ctx, rec); err == nil {
-							runtime.EventsEmit(ctx, "fileRenamed", old, base)
-							onRename(ctx)
+							runtime.EventsEmit(ctx, "fileRenamed", oldName, rec.Name)
+							renamed = true
 						}
 					}
+					if isDir {
+						if err := addTree(ev.Name, true); err != nil {
+							log.Printf("watcher: watch %s: %v", ev.Name, err)
+						}
+					}
+					if renamed {
+						onRename(ctx)
+					}
 					mu.Unlock()
 					continue
 				}
 
-				if ev.Op&(fsnotify.Create|fsnotify.Write) != 0 {
-					if _, err := store.Get(ctx, base); err == nil {
-						mu.Unlock()
-						continue
-					}
-					if t, ok := timers[ev.Name]; ok {
-						t.Stop()
+				if isDir {
+					if ev.Op&fsnotify.Create != 0 {
+						if err := addTree(ev.Name, true); err != nil {
+							log.Printf("watcher: watch %s: %v", ev.Name, err)
+						}
 					}
-					timers[ev.Name] = time.AfterFunc(stabilityDelay, func() {
-						mu.Lock()
-						delete(timers, ev.Name)
-						mu.Unlock()
-						onDetect(ctx, ev.Name, base)
-					})
+				} else if ev.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+					schedule(ev.Name)
 				}
 				mu.Unlock()
 
@@ -131,5 +222,5 @@ func StartSyncWatcher(
 		}
 	}()
 
-	return watcher.Add(dir)
+	return addTree(dir, false)
 }
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+)
+
+func TestStartSyncWatcher_Subdirectories(t *testing.T) {
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	dir := filepath.Join(tmp, "sync")
+	if err := os.MkdirAll(filepath.Join(dir, "old"), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	detected := make(chan string, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	nop := func(context.Context) {}
+	err = StartSyncWatcher(ctx, dir, store, func(ctx context.Context, path, name string) {
+		detected <- name
+	}, nop, nop)
+	if err != nil {
+		t.Fatalf("StartSyncWatcher: %v", err)
+	}
+
+	// One file in a directory that existed when watching started, one in
+	// a tree created afterwards.
+	if err := os.WriteFile(filepath.Join(dir, "old", "a.txt"), []byte("a"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "new", "deep"), 0o700); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "new", "deep", "b.txt"), []byte("b"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	var names []string
+	timeout := time.After(5 * time.Second)
+	for len(names) < 2 {
+		select {
+		case name := <-detected:
+			names = append(names, name)
+		case <-timeout:
+			t.Fatalf("detected %v; want old/a.txt and new/deep/b.txt", names)
+		}
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"new/deep/b.txt", "old/a.txt"}) {
+		t.Errorf("detected %v; want [new/deep/b.txt old/a.txt]", names)
+	}
+}
@@ -0,0 +1,116 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+)
+
+// Permissions the bot needs in the storage chat, as reported by
+// AccessReport.Missing.
+const (
+	PermissionPost   = "post"
+	PermissionPin    = "pin"
+	PermissionDelete = "delete"
+)
+
+// AccessReport says what the bot may do in a chat. Missing lists the
+// permissions it lacks, and Problems explains each one and how to fix it.
+type AccessReport struct {
+	BotID     int64    `json:"bot_id"`
+	BotName   string   `json:"bot_name"`
+	ChatTitle string   `json:"chat_title"`
+	ChatType  string   `json:"chat_type"`
+	Status    string   `json:"status"`
+	CanPost   bool     `json:"can_post"`
+	CanPin    bool     `json:"can_pin"`
+	CanDelete bool     `json:"can_delete"`
+	Missing   []string `json:"missing"`
+	Problems  []string `json:"problems"`
+}
+
+func (r *AccessReport) OK() bool {
+	return len(r.Missing) == 0
+}
+
+// CheckAccess verifies the client's token and works out from the bot's
+// membership whether it can post documents, pin the metadata backup and
+// delete chunks in chatID. It only reads state and sends no messages. An
+// error means the token is invalid or the chat cannot be reached at all.
+func (c *Client) CheckAccess(ctx context.Context, chatID string) (*AccessReport, error) {
+	me, err := c.GetMe(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("invalid bot token: %w", err)
+	}
+	report := &AccessReport{BotID: me.ID, BotName: me.Username, Missing: []string{}, Problems: []string{}}
+
+	chat, err := c.GetChat(ctx, chatID)
+	if err != nil {
+		return nil, fmt.Errorf("bot @%s cannot see chat %s; check the chat ID and that the bot was added to it: %w", me.Username, chatID, err)
+	}
+	report.ChatTitle, report.ChatType = chat.Title, chat.Type
+	if chat.Type == ChatPrivate {
+		// Bots may post, pin and delete their own messages in private chats
+		// with a user who started them; getChat succeeding shows that.
+		report.Status = MemberMember
+		report.CanPost, report.CanPin, report.CanDelete = true, true, true
+		return report, nil
+	}
+
+	member, err := c.GetChatMember(ctx, chatID, me.ID)
+	if err != nil {
+		return nil, fmt.Errorf("look up bot membership in chat %s: %w", chatID, err)
+	}
+	report.Status = member.Status
+
+	switch member.Status {
+	case MemberCreator:
+		report.CanPost, report.CanPin, report.CanDelete = true, true, true
+	case MemberAdministrator:
+		if chat.Type == ChatChannel {
+			report.CanPost = member.CanPostMessages
+			report.CanPin = member.CanEditMessages
+		} else {
+			report.CanPost = true
+			report.CanPin = member.CanPinMessages
+		}
+		report.CanDelete = member.CanDeleteMessages
+	case MemberMember:
+		if chat.Type != ChatChannel {
+			perms := chat.Permissions
+			report.CanPost = perms == nil || (perms.CanSendMessages && perms.CanSendDocuments)
+			report.CanPin = perms != nil && perms.CanPinMessages
+		}
+	case MemberRestricted:
+		report.CanPost = member.CanSendMessages && member.CanSendDocuments
+		report.CanPin = member.CanPinMessages
+	}
+
+	admin := "administrator"
+	if chat.Type == ChatChannel {
+		admin = "channel administrator"
+	}
+	switch {
+	case member.Status == MemberLeft || member.Status == MemberKicked:
+		report.missing(PermissionPost, fmt.Sprintf("the bot is not a member of the chat (status %q); add it again", member.Status))
+	case !report.CanPost && chat.Type == ChatChannel:
+		report.missing(PermissionPost, fmt.Sprintf("make the bot a %s with the %q right", admin, "Post messages"))
+	case !report.CanPost:
+		report.missing(PermissionPost, "the bot may not send documents in this chat; allow members to send files or make it an administrator")
+	}
+	if !report.CanPin {
+		right := "Pin messages"
+		if chat.Type == ChatChannel {
+			right = "Edit messages of others"
+		}
+		report.missing(PermissionPin, fmt.Sprintf("the bot cannot pin the metadata backup; make it a %s with the %q right", admin, right))
+	}
+	if !report.CanDelete {
+		report.missing(PermissionDelete, fmt.Sprintf("the bot cannot delete old chunks; make it a %s with the %q right", admin, "Delete messages"))
+	}
+	return report, nil
+}
+
+func (r *AccessReport) missing(permission, problem string) {
+	r.Missing = append(r.Missing, permission)
+	r.Problems = append(r.Problems, fmt.Sprintf("cannot %s: %s", permission, problem))
+}
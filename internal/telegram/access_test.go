@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// accessServer answers getMe, getChat and getChatMember for bot 7 in chats
+// described by chats, keyed by chat ID: the chat's JSON and the bot's
+// membership JSON.
+func accessServer(t *testing.T, chats map[string][2]string) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		chat, ok := chats[r.URL.Query().Get("chat_id")]
+		switch {
+		case r.URL.Path == "/getMe":
+			fmt.Fprint(w, `{"ok":true,"result":{"id":7,"is_bot":true,"username":"store_bot"}}`)
+		case !ok:
+			fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+		case r.URL.Path == "/getChat":
+			fmt.Fprintf(w, `{"ok":true,"result":%s}`, chat[0])
+		case r.URL.Path == "/getChatMember":
+			if got := r.URL.Query().Get("user_id"); got != "7" {
+				t.Errorf("getChatMember user_id = %s; want the bot's own ID", got)
+			}
+			fmt.Fprintf(w, `{"ok":true,"result":%s}`, chat[1])
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return testClient(srv)
+}
+
+func TestClient_CheckAccess(t *testing.T) {
+	client := accessServer(t, map[string][2]string{
+		"1": {`{"id":1,"type":"channel","title":"Vault"}`,
+			`{"status":"administrator","can_post_messages":true,"can_edit_messages":true,"can_delete_messages":true}`},
+		"2": {`{"id":2,"type":"supergroup","title":"Team"}`,
+			`{"status":"administrator","can_delete_messages":true}`},
+		"3": {`{"id":3,"type":"group","title":"Friends","permissions":{"can_send_messages":true,"can_send_documents":true}}`,
+			`{"status":"member"}`},
+		"4": {`{"id":4,"type":"channel","title":"Old"}`,
+			`{"status":"left"}`},
+		"5": {`{"id":5,"type":"private"}`, ``},
+	})
+	ctx := context.Background()
+
+	for chatID, want := range map[string][]string{
+		"1": {},
+		"2": {PermissionPin},
+		"3": {PermissionPin, PermissionDelete},
+		"4": {PermissionPost, PermissionPin, PermissionDelete},
+		"5": {},
+	} {
+		report, err := client.CheckAccess(ctx, chatID)
+		if err != nil {
+			t.Fatalf("CheckAccess(%s): %v", chatID, err)
+		}
+		if !reflect.DeepEqual(report.Missing, want) {
+			t.Errorf("chat %s: Missing = %v; want %v", chatID, report.Missing, want)
+		}
+		if len(report.Problems) != len(want) || report.OK() != (len(want) == 0) {
+			t.Errorf("chat %s: Problems = %v", chatID, report.Problems)
+		}
+	}
+
+	report, _ := client.CheckAccess(ctx, "2")
+	if !strings.Contains(report.Problems[0], `"Pin messages"`) {
+		t.Errorf("problem %q does not name the missing right", report.Problems[0])
+	}
+	if _, err := client.CheckAccess(ctx, "99"); err == nil || !strings.Contains(err.Error(), "@store_bot") {
+		t.Errorf("unknown chat: err = %v; want one naming the bot", err)
+	}
+}
@@ -0,0 +1,303 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+const (
+	pollTimeout    = 30 * time.Second
+	pollRetryDelay = 5 * time.Second
+
+	// maxMessageLen is Telegram's limit for a text message.
+	maxMessageLen = 4096
+	// maxDirectSend is the largest file the Bot API accepts as an upload;
+	// bigger files are sent as their stored chunks instead.
+	maxDirectSend = 50 << 20
+	maxResults    = 50
+)
+
+const botHelp = `Commands:
+/ls [folder] - list files
+/search <query> - find files, e.g. report tag:work size:>1MB
+/info <name> - show file details
+/get <name> - send a file`
+
+type BotFiles interface {
+	Open(ctx context.Context, rec *model.FileRecord) (Content, error)
+	ReadChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error)
+}
+
+// Bot answers commands sent to the bot by allow-listed users. Messages from
+// anyone else are ignored.
+type Bot struct {
+	client  *Client
+	store   metadata.Store
+	files   BotFiles
+	allowed map[int64]bool
+	offset  int
+}
+
+func NewBot(client *Client, store metadata.Store, files BotFiles, allowedUsers []int64) *Bot {
+	allowed := make(map[int64]bool, len(allowedUsers))
+	for _, id := range allowedUsers {
+		allowed[id] = true
+	}
+
+	return &Bot{
+		client:  client,
+		store:   store,
+		files:   files,
+		allowed: allowed,
+	}
+}
+
+// Run long-polls for updates until ctx is cancelled.
+func (b *Bot) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := b.poll(ctx, pollTimeout); err != nil && ctx.Err() == nil {
+			log.Printf("bot: %v", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollRetryDelay):
+			}
+		}
+	}
+}
+
+func (b *Bot) poll(ctx context.Context, timeout time.Duration) error {
+	updates, err := b.client.GetUpdates(ctx, b.offset, timeout)
+	if err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		b.offset = u.UpdateID + 1
+		if u.Message == nil || u.Message.From == nil || !b.allowed[u.Message.From.ID] {
+			continue
+		}
+
+		chatID := strconv.FormatInt(u.Message.Chat.ID, 10)
+		if err := b.handle(ctx, chatID, u.Message.Text); err != nil {
+			log.Printf("bot: %q: %v", u.Message.Text, err)
+			b.reply(ctx, chatID, "Error: "+err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (b *Bot) reply(ctx context.Context, chatID, text string) error {
+	if len(text) > maxMessageLen {
+		text = strings.ToValidUTF8(text[:maxMessageLen-len("\n…")], "") + "\n…"
+	}
+	_, err := b.client.SendText(ctx, chatID, text)
+	return err
+}
+
+func (b *Bot) handle(ctx context.Context, chatID, text string) error {
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(text), " ")
+	cmd, _, _ = strings.Cut(cmd, "@")
+	arg = strings.TrimSpace(arg)
+
+	switch cmd {
+	case "/start", "/help":
+		return b.reply(ctx, chatID, botHelp)
+	case "/ls":
+		return b.list(ctx, chatID, arg)
+	case "/search":
+		if arg == "" {
+			return b.reply(ctx, chatID, "Usage: /search <query>")
+		}
+		return b.search(ctx, chatID, arg)
+	case "/info":
+		if arg == "" {
+			return b.reply(ctx, chatID, "Usage: /info <name>")
+		}
+		return b.info(ctx, chatID, arg)
+	case "/get":
+		if arg == "" {
+			return b.reply(ctx, chatID, "Usage: /get <name>")
+		}
+		return b.get(ctx, chatID, arg)
+	default:
+		return b.reply(ctx, chatID, "Unknown command.\n\n"+botHelp)
+	}
+}
+
+func (b *Bot) list(ctx context.Context, chatID, folder string) error {
+	prefix := ""
+	if folder != "" {
+		name, err := metadata.CleanName(folder)
+		if err != nil {
+			return err
+		}
+		prefix = name + "/"
+	}
+
+	recs, err := b.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	entries := metadata.ListDir(recs, prefix)
+	if len(entries) == 0 {
+		return b.reply(ctx, chatID, "No files.")
+	}
+
+	var sb strings.Builder
+	for _, e := range entries {
+		if e.IsDir() {
+			fmt.Fprintf(&sb, "%s/\n", e.Name)
+		} else {
+			fmt.Fprintf(&sb, "%s (%s, %s)\n", e.Name, formatSize(e.Record.Size), e.Record.State)
+		}
+	}
+	return b.reply(ctx, chatID, sb.String())
+}
+
+func (b *Bot) search(ctx context.Context, chatID, term string) error {
+	recs, err := b.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	matches, err := metadata.Search(recs, term)
+	if err != nil {
+		return b.reply(ctx, chatID, err.Error())
+	}
+	if len(matches) == 0 {
+		return b.reply(ctx, chatID, "No matches.")
+	}
+	names := make([]string, len(matches))
+	for i, rec := range matches {
+		names[i] = rec.Name
+	}
+
+	more := ""
+	if len(names) > maxResults {
+		more = fmt.Sprintf("\n…and %d more", len(names)-maxResults)
+		names = names[:maxResults]
+	}
+	return b.reply(ctx, chatID, strings.Join(names, "\n")+more)
+}
+
+func (b *Bot) lookup(ctx context.Context, name string) (*model.FileRecord, error) {
+	clean, err := metadata.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	rec, err := b.store.Get(ctx, clean)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, fmt.Errorf("no file named %q", clean)
+	}
+	return rec, err
+}
+
+func (b *Bot) info(ctx context.Context, chatID, name string) error {
+	rec, err := b.lookup(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Name: %s\n", rec.Name)
+	fmt.Fprintf(&sb, "Size: %s (%d bytes)\n", formatSize(rec.Size), rec.Size)
+	fmt.Fprintf(&sb, "State: %s\n", rec.State)
+	fmt.Fprintf(&sb, "Uploaded: %s\n", rec.UploadedAt.Format(time.RFC3339))
+	fmt.Fprintf(&sb, "Chunks: %d\n", len(rec.ChunkIds))
+	fmt.Fprintf(&sb, "SHA-256: %s", rec.Checksum)
+	if rec.Description != "" {
+		fmt.Fprintf(&sb, "\n\n%s", rec.Description)
+	}
+	return b.reply(ctx, chatID, sb.String())
+}
+
+func (b *Bot) get(ctx context.Context, chatID, name string) error {
+	rec, err := b.lookup(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if rec.Size <= maxDirectSend {
+		return b.sendDirect(ctx, chatID, rec)
+	}
+
+	for i, fileID := range rec.ChunkIds {
+		caption := fmt.Sprintf("%s (part %d/%d)", rec.Name, i+1, len(rec.ChunkIds))
+		if rec.ChunkTarget(i) == "" {
+			if _, err := b.client.SendDocumentByID(ctx, chatID, fileID, caption); err != nil {
+				return fmt.Errorf("forward chunk %d: %w", i, err)
+			}
+			continue
+		}
+
+		// File IDs from other storage targets belong to another bot, so
+		// the chunk has to be uploaded again.
+		data, err := b.files.ReadChunk(ctx, rec, i)
+		if err != nil {
+			return fmt.Errorf("read chunk %d: %w", i, err)
+		}
+		name := fmt.Sprintf("chunk_%d", i)
+		if _, _, err := b.client.SendDocument(ctx, chatID, name, bytes.NewReader(data), int64(len(data)), caption); err != nil {
+			return fmt.Errorf("send chunk %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// sendDirect assembles the file under its own name and uploads it as a
+// single document.
+func (b *Bot) sendDirect(ctx context.Context, chatID string, rec *model.FileRecord) error {
+	content, err := b.files.Open(ctx, rec)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	dir, err := os.MkdirTemp("", "tstore-bot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, path.Base(rec.Name))
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, content)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("assemble %q: %w", rec.Name, err)
+	}
+
+	_, _, err = b.client.SendFile(ctx, chatID, tmpPath, rec.Name)
+	return err
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,176 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+type fakeOpener map[string][]byte
+
+func (f fakeOpener) ReadChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error) {
+	return []byte(rec.ChunkIds[index]), nil
+}
+
+func (f fakeOpener) Open(ctx context.Context, rec *model.FileRecord) (Content, error) {
+	return nopContent{bytes.NewReader(f[rec.Name])}, nil
+}
+
+type nopContent struct {
+	*bytes.Reader
+}
+
+func (nopContent) Close() error { return nil }
+
+type sentDocument struct {
+	chatID   string
+	document string
+	filename string
+	data     string
+	caption  string
+}
+
+// fakeBotAPI serves one batch of updates from getUpdates and records every
+// reply the bot sends.
+type fakeBotAPI struct {
+	t       *testing.T
+	mu      sync.Mutex
+	updates []Update
+	offsets []string
+	texts   []string
+	docs    []sentDocument
+}
+
+func (f *fakeBotAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.URL.Path {
+	case "/getUpdates":
+		f.offsets = append(f.offsets, r.URL.Query().Get("offset"))
+		json.NewEncoder(w).Encode(map[string]any{"ok": true, "result": f.updates})
+		f.updates = nil
+	case "/sendMessage":
+		r.ParseForm()
+		f.texts = append(f.texts, r.FormValue("text"))
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":1}}`)
+	case "/sendDocument":
+		doc := sentDocument{}
+		if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
+			r.ParseMultipartForm(1 << 20)
+			part, header, err := r.FormFile("document")
+			if err != nil {
+				f.t.Errorf("FormFile: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(part)
+			doc.filename, doc.data = header.Filename, string(data)
+		} else {
+			r.ParseForm()
+			doc.document = r.FormValue("document")
+		}
+		doc.chatID = r.FormValue("chat_id")
+		doc.caption = r.FormValue("caption")
+		f.docs = append(f.docs, doc)
+		fmt.Fprint(w, `{"ok":true,"result":{"message_id":2,"document":{"file_id":"x"}}}`)
+	default:
+		f.t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	}
+}
+
+func message(id int, from int64, text string) Update {
+	return Update{UpdateID: id, Message: &Message{MessageID: id, From: &User{ID: from}, Chat: Chat{ID: 500}, Text: text}}
+}
+
+func TestBot_Commands(t *testing.T) {
+	api := &fakeBotAPI{t: t, updates: []Update{
+		message(10, 42, "/ls"),
+		message(11, 42, "/ls docs"),
+		message(12, 7, "/ls"),
+		message(13, 42, "/search REPORT"),
+		message(14, 42, "/info docs/report.pdf"),
+		message(15, 42, "/get docs/report.pdf"),
+		message(16, 42, "/get big.iso"),
+		message(17, 42, "/get missing"),
+	}}
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	store, err := metadata.NewJSONStore(filepath.Join(t.TempDir(), "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	ctx := context.Background()
+	for _, rec := range []*model.FileRecord{
+		{Name: "docs/report.pdf", State: model.StateCloud, Size: 11, Checksum: "abc", ChunkIds: []string{"c1"}, UploadedAt: time.Now()},
+		{Name: "big.iso", State: model.StateCloud, Size: maxDirectSend + 1, ChunkIds: []string{"b1", "b2"}, ChunkTargets: []string{"", "backup"}, UploadedAt: time.Now()},
+		{Name: "notes.txt", State: model.StateLocal, Size: 5, Description: "quarterly report draft", UploadedAt: time.Now()},
+	} {
+		if err := store.Create(ctx, rec); err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+	}
+
+	client := &Client{baseURL: srv.URL, client: srv.Client()}
+	bot := NewBot(client, store, fakeOpener{"docs/report.pdf": []byte("hello world")}, []int64{42})
+
+	if err := bot.poll(ctx, 0); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if err := bot.poll(ctx, 0); err != nil {
+		t.Fatalf("second poll: %v", err)
+	}
+
+	if strings.Join(api.offsets, ",") != "0,18" {
+		t.Errorf("getUpdates offsets = %v; want [0 18]", api.offsets)
+	}
+
+	want := []string{
+		"big.iso (",
+		"report.pdf (11 B, cloud)",
+		"docs/report.pdf\nnotes.txt",
+		"Name: docs/report.pdf",
+		`Error: no file named "missing"`,
+	}
+	if len(api.texts) != len(want) {
+		t.Fatalf("sent %d texts; want %d: %q", len(api.texts), len(want), api.texts)
+	}
+	for i, prefix := range want {
+		if !strings.Contains(api.texts[i], prefix) {
+			t.Errorf("text %d = %q; want it to contain %q", i, api.texts[i], prefix)
+		}
+	}
+	if !strings.Contains(api.texts[0], "docs/") {
+		t.Errorf("/ls output %q does not list docs/", api.texts[0])
+	}
+
+	if len(api.docs) != 3 {
+		t.Fatalf("sent %d documents; want 3: %+v", len(api.docs), api.docs)
+	}
+	if d := api.docs[0]; d.filename != "report.pdf" || d.data != "hello world" || d.chatID != "500" {
+		t.Errorf("direct send = %+v", d)
+	}
+	if api.docs[1].document != "b1" {
+		t.Errorf("forwarded chunk = %+v", api.docs[1])
+	}
+	if d := api.docs[2]; d.data != "b2" || d.filename != "chunk_1" {
+		t.Errorf("re-sent chunk from another target = %+v", d)
+	}
+	for i, d := range api.docs[1:] {
+		if want := fmt.Sprintf("big.iso (part %d/2)", i+1); d.caption != want {
+			t.Errorf("chunk %d caption = %q; want %q", i, d.caption, want)
+		}
+	}
+}
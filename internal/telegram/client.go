@@ -12,20 +12,37 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
+// ErrFileUnavailable means Telegram no longer serves a file ID to this bot,
+// typically because its message or chat was deleted.
+var ErrFileUnavailable = errors.New("file is no longer available")
+
+// ErrNoPinnedDocument means a chat has no pinned metadata backup yet.
+var ErrNoPinnedDocument = errors.New("no pinned message with a document found")
+
 type Client struct {
 	token   string
 	baseURL string
 	fileURL string
 	client  *http.Client
+
+	// Sent, when set, records every message this client posts.
+	Sent *SentLog
 }
 
+// APIURL is the Bot API server new clients talk to, such as a self-hosted
+// one or, in tests, a fake.
+var APIURL = "https://api.telegram.org"
+
 func NewClient(token string) *Client {
 	return &Client{
 		token:   token,
-		baseURL: fmt.Sprintf("https://api.telegram.org/bot%s", token),
-		fileURL: fmt.Sprintf("https://api.telegram.org/file/bot%s", token),
+		baseURL: fmt.Sprintf("%s/bot%s", APIURL, token),
+		fileURL: fmt.Sprintf("%s/file/bot%s", APIURL, token),
 		client:  http.DefaultClient,
 	}
 }
@@ -55,6 +72,7 @@ func (c *Client) SendText(ctx context.Context, chatID string, text string) (mess
 		return 0, fmt.Errorf("telegram API error sending text")
 	}
 
+	c.recordSent(chatID, res.Result.MessageID, "")
 	return res.Result.MessageID, nil
 }
 
@@ -87,7 +105,8 @@ func (c *Client) SendChunk(ctx context.Context, chatID string, chunk io.Reader,
 	var res struct {
 		OK     bool `json:"ok"`
 		Result struct {
-			Document struct {
+			MessageID int `json:"message_id"`
+			Document  struct {
 				FileID string `json:"file_id"`
 			}
 		} `json:"result"`
@@ -99,6 +118,7 @@ func (c *Client) SendChunk(ctx context.Context, chatID string, chunk io.Reader,
 		return "", fmt.Errorf("telegram API error sending chunk")
 	}
 
+	c.recordSent(chatID, res.Result.MessageID, res.Result.Document.FileID)
 	return res.Result.Document.FileID, nil
 }
 
@@ -109,16 +129,19 @@ func (c *Client) SendFile(ctx context.Context, chatID string, localPath string,
 	}
 	defer f.Close()
 
-	var b bytes.Buffer
-	w := multipart.NewWriter(&b)
-
-	field, err := w.CreateFormFile("document", filepath.Base(localPath))
+	info, err := f.Stat()
 	if err != nil {
-		return 0, "", fmt.Errorf("create form file: %w", err)
-	}
-	if _, err := io.Copy(field, f); err != nil {
-		return 0, "", fmt.Errorf("copy file data: %w", err)
+		return 0, "", fmt.Errorf("stat %q: %w", localPath, err)
 	}
+	return c.SendDocument(ctx, chatID, filepath.Base(localPath), f, info.Size(), caption)
+}
+
+// SendDocument uploads size bytes from r as a document called name. The
+// multipart body is streamed around r rather than buffered, so sending a
+// chunk that is already in memory does not copy it again.
+func (c *Client) SendDocument(ctx context.Context, chatID, name string, r io.Reader, size int64, caption string) (messageID int, fileID string, err error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
 
 	if err := w.WriteField("chat_id", chatID); err != nil {
 		return 0, "", fmt.Errorf("write chat_id: %w", err)
@@ -128,13 +151,20 @@ func (c *Client) SendFile(ctx context.Context, chatID string, localPath string,
 			return 0, "", fmt.Errorf("write caption: %w", err)
 		}
 	}
+	if _, err := w.CreateFormFile("document", name); err != nil {
+		return 0, "", fmt.Errorf("create form file: %w", err)
+	}
+	head := b.Len()
 	w.Close()
+	tail := bytes.Clone(b.Bytes()[head:])
 
+	body := io.MultiReader(bytes.NewReader(b.Bytes()[:head]), io.LimitReader(r, size), bytes.NewReader(tail))
 	url := fmt.Sprintf("%s/sendDocument", c.baseURL)
-	req, err := http.NewRequestWithContext(ctx, "POST", url, &b)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
 	if err != nil {
 		return 0, "", fmt.Errorf("new request: %w", err)
 	}
+	req.ContentLength = int64(head) + size + int64(len(tail))
 	req.Header.Set("Content-Type", w.FormDataContentType())
 
 	resp, err := c.client.Do(req)
@@ -164,13 +194,16 @@ func (c *Client) SendFile(ctx context.Context, chatID string, localPath string,
 		return 0, "", fmt.Errorf("telegram error: %s", string(respBytes))
 	}
 
+	c.recordSent(chatID, res.Result.MessageID, res.Result.Document.FileID)
 	return res.Result.MessageID, res.Result.Document.FileID, nil
 }
 
-func (c *Client) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, error) {
+// GetFile resolves fileID to a download path. It fails once the file is no
+// longer available to this bot, which makes it a cheap liveness check.
+func (c *Client) GetFile(ctx context.Context, fileID string) (filePath string, err error) {
 	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/getFile", c.baseURL), nil)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	q := req.URL.Query()
 	q.Set("file_id", fileID)
@@ -178,7 +211,7 @@ func (c *Client) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 	defer resp.Body.Close()
 
@@ -187,15 +220,42 @@ func (c *Client) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser
 		Result struct {
 			FilePath string `json:"file_path"`
 		} `json:"result"`
+		ErrorCode   int    `json:"error_code,omitempty"`
+		Description string `json:"description,omitempty"`
 	}
 	if err := json.NewDecoder(resp.Body).Decode(&meta); err != nil {
-		return nil, err
+		return "", err
 	}
 	if !meta.OK {
-		return nil, fmt.Errorf("telegram API error getting file path")
+		if meta.ErrorCode == http.StatusBadRequest && fileGone(meta.Description) {
+			return "", fmt.Errorf("telegram API error getting file path: %s: %w", meta.Description, ErrFileUnavailable)
+		}
+		return "", fmt.Errorf("telegram API error getting file path: %s", meta.Description)
 	}
 
-	downloadURL := fmt.Sprintf("%s/%s", c.fileURL, meta.Result.FilePath)
+	return meta.Result.FilePath, nil
+}
+
+// fileGone reports whether a getFile error description says the file ID
+// itself is no longer valid, as opposed to other bad requests such as a
+// file that is too big to download.
+func fileGone(description string) bool {
+	description = strings.ToLower(description)
+	for _, s := range []string{"invalid file_id", "wrong file_id", "file not found", "wrong remote file identifier"} {
+		if strings.Contains(description, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, error) {
+	filePath, err := c.GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	downloadURL := fmt.Sprintf("%s/%s", c.fileURL, filePath)
 	req2, err := http.NewRequestWithContext(ctx, "GET", downloadURL, nil)
 	if err != nil {
 		return nil, err
@@ -294,6 +354,44 @@ func (c *Client) PinChatMessage(ctx context.Context, chatID string, messageID in
 	return nil
 }
 
+// DeleteMessage removes a message from chatID. Telegram refuses to delete
+// some messages, such as old ones in private chats, so callers should not
+// treat every failure as fatal.
+func (c *Client) DeleteMessage(ctx context.Context, chatID string, messageID int) error {
+	bodyBytes, err := json.Marshal(map[string]any{
+		"chat_id":    chatID,
+		"message_id": messageID,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal deleteMessage payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/deleteMessage", c.baseURL), bytes.NewReader(bodyBytes))
+	if err != nil {
+		return fmt.Errorf("new deleteMessage request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("deleteMessage HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var respData struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description,omitempty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&respData); err != nil {
+		return fmt.Errorf("decode deleteMessage response: %w", err)
+	}
+	if !respData.OK {
+		return fmt.Errorf("telegram API error deleting message: %s", respData.Description)
+	}
+
+	return nil
+}
+
 func (c *Client) UnpinChatMessage(ctx context.Context, chatID string, messageID int) error {
 	url := fmt.Sprintf("%s/unpinChatMessage", c.baseURL)
 
@@ -364,8 +462,226 @@ func (c *Client) GetPinnedFileID(ctx context.Context, chatID string) (string, er
 
 	pm := payload.Result.PinnedMessage
 	if pm == nil || pm.Document == nil {
-		return "", errors.New("no pinned message with a document found")
+		return "", ErrNoPinnedDocument
 	}
 
 	return pm.Document.FileID, nil
 }
+
+// GetPinnedMessageID returns the ID of the message pinned in chatID, or zero
+// when nothing is pinned.
+func (c *Client) GetPinnedMessageID(ctx context.Context, chatID string) (int, error) {
+	pm, err := c.GetPinnedMessage(ctx, chatID)
+	if err != nil || pm == nil {
+		return 0, err
+	}
+	return pm.MessageID, nil
+}
+
+// GetPinnedMessage returns the message pinned in chatID, or nil when nothing
+// is pinned.
+func (c *Client) GetPinnedMessage(ctx context.Context, chatID string) (*Message, error) {
+	chat, err := c.GetChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return chat.PinnedMessage, nil
+}
+
+// GetMe returns the bot the client's token belongs to. It is the cheapest
+// way to check that a token is valid.
+func (c *Client) GetMe(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.get(ctx, "getMe", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+func (c *Client) GetChat(ctx context.Context, chatID string) (*Chat, error) {
+	var chat Chat
+	if err := c.get(ctx, "getChat", url.Values{"chat_id": {chatID}}, &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+// GetChatMember returns the status and rights of userID in chatID.
+func (c *Client) GetChatMember(ctx context.Context, chatID string, userID int64) (*ChatMember, error) {
+	var member ChatMember
+	query := url.Values{"chat_id": {chatID}, "user_id": {strconv.FormatInt(userID, 10)}}
+	if err := c.get(ctx, "getChatMember", query, &member); err != nil {
+		return nil, err
+	}
+	return &member, nil
+}
+
+// get calls a read-only Bot API method and decodes its result into out.
+func (c *Client) get(ctx context.Context, method string, query url.Values, out any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/%s?%s", c.baseURL, method, query.Encode()), nil)
+	if err != nil {
+		return fmt.Errorf("new %s request: %w", method, err)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s HTTP request: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		Description string          `json:"description,omitempty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return fmt.Errorf("decode %s response: %w", method, err)
+	}
+	if !payload.OK {
+		return fmt.Errorf("telegram API error in %s: %s", method, payload.Description)
+	}
+	if err := json.Unmarshal(payload.Result, out); err != nil {
+		return fmt.Errorf("decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+type User struct {
+	ID        int64  `json:"id"`
+	IsBot     bool   `json:"is_bot,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	Username  string `json:"username,omitempty"`
+}
+
+const (
+	ChatPrivate    = "private"
+	ChatGroup      = "group"
+	ChatSupergroup = "supergroup"
+	ChatChannel    = "channel"
+)
+
+type Chat struct {
+	ID       int64  `json:"id"`
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Username string `json:"username,omitempty"`
+	// PinnedMessage and Permissions are only returned by getChat.
+	PinnedMessage *Message         `json:"pinned_message,omitempty"`
+	Permissions   *ChatPermissions `json:"permissions,omitempty"`
+}
+
+// ChatPermissions are the default rights of members of a group.
+type ChatPermissions struct {
+	CanSendMessages  bool `json:"can_send_messages"`
+	CanSendDocuments bool `json:"can_send_documents"`
+	CanPinMessages   bool `json:"can_pin_messages"`
+}
+
+const (
+	MemberCreator       = "creator"
+	MemberAdministrator = "administrator"
+	MemberMember        = "member"
+	MemberRestricted    = "restricted"
+	MemberLeft          = "left"
+	MemberKicked        = "kicked"
+)
+
+// ChatMember is a user's status in a chat. Which rights are set depends on
+// the status: administrators have the Can*Messages admin rights, restricted
+// members the CanSend* ones.
+type ChatMember struct {
+	Status            string `json:"status"`
+	User              User   `json:"user"`
+	CanPostMessages   bool   `json:"can_post_messages,omitempty"`
+	CanEditMessages   bool   `json:"can_edit_messages,omitempty"`
+	CanDeleteMessages bool   `json:"can_delete_messages,omitempty"`
+	CanPinMessages    bool   `json:"can_pin_messages,omitempty"`
+	CanSendMessages   bool   `json:"can_send_messages,omitempty"`
+	CanSendDocuments  bool   `json:"can_send_documents,omitempty"`
+}
+
+type Message struct {
+	MessageID int    `json:"message_id"`
+	From      *User  `json:"from,omitempty"`
+	Chat      Chat   `json:"chat"`
+	Text      string `json:"text,omitempty"`
+	Caption   string `json:"caption,omitempty"`
+}
+
+type Update struct {
+	UpdateID int      `json:"update_id"`
+	Message  *Message `json:"message,omitempty"`
+}
+
+func (c *Client) GetUpdates(ctx context.Context, offset int, timeout time.Duration) ([]Update, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/getUpdates", c.baseURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("new getUpdates request: %w", err)
+	}
+	q := req.URL.Query()
+	q.Set("offset", strconv.Itoa(offset))
+	q.Set("timeout", strconv.Itoa(int(timeout.Seconds())))
+	q.Set("allowed_updates", `["message"]`)
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("getUpdates HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var payload struct {
+		OK          bool     `json:"ok"`
+		Result      []Update `json:"result"`
+		Description string   `json:"description,omitempty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("decode getUpdates response: %w", err)
+	}
+	if !payload.OK {
+		return nil, fmt.Errorf("telegram API error in getUpdates: %s", payload.Description)
+	}
+
+	return payload.Result, nil
+}
+
+// SendDocumentByID re-sends a document that is already stored on Telegram's
+// servers, so chunks can be forwarded without downloading them first.
+func (c *Client) SendDocumentByID(ctx context.Context, chatID string, fileID string, caption string) (messageID int, err error) {
+	form := url.Values{}
+	form.Set("chat_id", chatID)
+	form.Set("document", fileID)
+	if caption != "" {
+		form.Set("caption", caption)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/sendDocument", c.baseURL), strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, fmt.Errorf("new sendDocument request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("sendDocument HTTP request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var res struct {
+		OK     bool `json:"ok"`
+		Result struct {
+			MessageID int `json:"message_id"`
+		} `json:"result"`
+		Description string `json:"description,omitempty"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return 0, fmt.Errorf("decode sendDocument response: %w", err)
+	}
+	if !res.OK {
+		return 0, fmt.Errorf("telegram API error resending document: %s", res.Description)
+	}
+
+	// The copy is not stored content, so it is logged without the file ID
+	// and purging the original never deletes it.
+	c.recordSent(chatID, res.Result.MessageID, "")
+	return res.Result.MessageID, nil
+}
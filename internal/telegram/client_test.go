@@ -3,6 +3,7 @@ package telegram
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -115,6 +116,7 @@ func TestDownloadFile(t *testing.T) {
 
 	c := &Client{
 		baseURL: srv.URL,
+		fileURL: srv.URL + "/file",
 		client:  srv.Client(),
 	}
 
@@ -132,3 +134,35 @@ func TestDownloadFile(t *testing.T) {
 		t.Errorf("downloaded content = %q; want %q", got, want)
 	}
 }
+
+func TestGetFile_Unavailable(t *testing.T) {
+	tests := []struct {
+		code        int
+		description string
+		unavailable bool
+	}{
+		{400, "Bad Request: invalid file_id", true},
+		{400, "Bad Request: wrong file_id or the file is temporarily unavailable", true},
+		{400, "Bad Request: file not found", true},
+		{400, "Bad Request: file is too big", false},
+		{400, "Bad Request: chat not found", false},
+		{401, "Unauthorized", false},
+	}
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"ok":false,"error_code":%d,"description":%q}`, tt.code, tt.description)
+		}))
+		c := &Client{baseURL: srv.URL, client: srv.Client()}
+
+		_, err := c.GetFile(context.Background(), "FILE_ID")
+		srv.Close()
+		if err == nil {
+			t.Errorf("%q: GetFile succeeded", tt.description)
+			continue
+		}
+		if got := errors.Is(err, ErrFileUnavailable); got != tt.unavailable {
+			t.Errorf("%q: errors.Is(err, ErrFileUnavailable) = %v; want %v", tt.description, got, tt.unavailable)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"tstore/pkg/model"
+)
+
+// indexCaptionPrefix marks the line of a metadata backup's caption that
+// names the content index backup sent with it.
+const indexCaptionPrefix = "content index: "
+
+// indexBackup remembers the last content index backup, so unchanged indexes
+// are not sent again with every metadata backup.
+type indexBackup struct {
+	mu      sync.Mutex
+	changes uint64
+	fileID  string
+}
+
+// indexBackupID returns the file ID of the content index backup named in a
+// metadata backup's caption, or "" if there is none.
+func indexBackupID(caption string) string {
+	for _, line := range strings.Split(caption, "\n") {
+		if id, ok := strings.CutPrefix(line, indexCaptionPrefix); ok {
+			return strings.TrimSpace(id)
+		}
+	}
+	return ""
+}
+
+// backupContentIndex drops content no record refers to any more, then sends
+// the index unless the last backup is still current, and returns the file
+// ID of the backup.
+func (u *Uploader) backupContentIndex(ctx context.Context, chatID string) (string, error) {
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return "", fmt.Errorf("list metadata: %w", err)
+	}
+	trashed, err := u.Store.ListTrash(ctx)
+	if err != nil {
+		return "", fmt.Errorf("list trash: %w", err)
+	}
+	keep := make(map[string]bool, len(recs)+len(trashed))
+	for _, rec := range append(recs, trashed...) {
+		keep[rec.Checksum] = true
+	}
+	if _, err := u.Index.Prune(keep); err != nil {
+		return "", fmt.Errorf("prune content index: %w", err)
+	}
+
+	u.indexBackup.mu.Lock()
+	defer u.indexBackup.mu.Unlock()
+
+	changes := u.Index.Changes()
+	if u.indexBackup.fileID != "" && u.indexBackup.changes == changes {
+		return u.indexBackup.fileID, nil
+	}
+	_, fileID, err := u.Client.SendFile(ctx, chatID, u.Index.Path(), "content index backup")
+	if err != nil {
+		return "", fmt.Errorf("sending content index backup: %w", err)
+	}
+	u.indexBackup.changes, u.indexBackup.fileID = changes, fileID
+	return fileID, nil
+}
+
+// RestoreContentIndex replaces the content index with the backup named by
+// the pinned metadata backup in chatID. It does nothing when there is no
+// such backup.
+func (u *Uploader) RestoreContentIndex(ctx context.Context, chatID string) error {
+	pm, err := u.Client.GetPinnedMessage(ctx, chatID)
+	if err != nil {
+		return fmt.Errorf("find pinned backup: %w", err)
+	}
+	if pm == nil {
+		return nil
+	}
+	fileID := indexBackupID(pm.Caption)
+	if fileID == "" {
+		return nil
+	}
+
+	reader, err := u.Client.DownloadFile(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("download content index backup: %w", err)
+	}
+	if err := u.Index.Load(ctx, reader); err != nil {
+		return fmt.Errorf("load content index backup: %w", err)
+	}
+	if err := u.Index.Save(); err != nil {
+		return fmt.Errorf("save content index: %w", err)
+	}
+
+	u.indexBackup.mu.Lock()
+	u.indexBackup.changes, u.indexBackup.fileID = u.Index.Changes(), fileID
+	u.indexBackup.mu.Unlock()
+	return nil
+}
+
+// IndexLocalFiles indexes the local copies of files whose content is not
+// indexed yet, such as those uploaded before indexing was turned on. It
+// returns how many files it read.
+func (u *Uploader) IndexLocalFiles(ctx context.Context) (int, error) {
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list metadata: %w", err)
+	}
+
+	n := 0
+	for _, rec := range recs {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		if rec.State != model.StateLocal || u.Index.Has(rec.Checksum) {
+			continue
+		}
+		u.indexContent(rec, filepath.Join(u.SyncFolder, filepath.FromSlash(rec.Name)))
+		n++
+	}
+	return n, nil
+}
+
+// indexContent adds the file at localPath to the content index, if there is
+// one. Indexing is best effort: a failure is logged and never fails the
+// operation that stored the file.
+func (u *Uploader) indexContent(rec *model.FileRecord, localPath string) {
+	if u.Index == nil {
+		return
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		log.Printf("index %q: %v", rec.Name, err)
+		return
+	}
+	defer f.Close()
+
+	if err := u.Index.IndexFile(rec.Checksum, rec.Name, f); err != nil {
+		log.Printf("index %q: %v", rec.Name, err)
+	}
+}
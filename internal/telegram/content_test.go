@@ -0,0 +1,94 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"tstore/internal/index"
+	"tstore/internal/metadata"
+)
+
+func TestUploader_ContentIndex(t *testing.T) {
+	srv, stored := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	ix, err := index.NewContentIndex(filepath.Join(tmp, "index.json"))
+	if err != nil {
+		t.Fatalf("NewContentIndex: %v", err)
+	}
+	sent, err := NewSentLog(filepath.Join(tmp, "sent.jsonl"))
+	if err != nil {
+		t.Fatalf("NewSentLog: %v", err)
+	}
+	client := testClient(srv)
+	client.Sent = sent
+
+	u := NewUploader(client, store, filepath.Join(tmp, "sync"), 16)
+	u.Index = ix
+	ctx := context.Background()
+	src := filepath.Join(tmp, "minutes.md")
+	if err := os.WriteFile(src, []byte("# Minutes\nWe agreed on the zeppelin budget."), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	if _, err := u.UploadFileAs(ctx, src, "minutes.md", "1", nil); err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	if err := u.OffloadFile(ctx, "minutes.md", "1"); err != nil {
+		t.Fatalf("OffloadFile: %v", err)
+	}
+
+	recs, _ := store.List(ctx)
+	if got := ix.Search(recs, "zeppelin"); len(got) != 1 || got[0].Name != "minutes.md" {
+		t.Errorf("Search after offload = %v; want minutes.md", got)
+	}
+
+	// Index backups are JSON objects, metadata backups are arrays.
+	indexBackups := func() []string {
+		var ids []string
+		for id, data := range stored {
+			if strings.HasPrefix(data, "{") {
+				ids = append(ids, id)
+			}
+		}
+		return ids
+	}
+	// The offload's backup reuses the unchanged index sent on upload.
+	if got := indexBackups(); len(got) != 1 {
+		t.Errorf("%d content index backups stored; want 1", len(got))
+	}
+
+	// A fresh install restores the index from the pinned backup.
+	restored, err := index.NewContentIndex(filepath.Join(tmp, "restored.json"))
+	if err != nil {
+		t.Fatalf("NewContentIndex: %v", err)
+	}
+	fresh := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 16)
+	fresh.Index = restored
+	if err := fresh.RestoreContentIndex(ctx, "1"); err != nil {
+		t.Fatalf("RestoreContentIndex: %v", err)
+	}
+	if got := restored.Search(recs, "budget"); len(got) != 1 {
+		t.Errorf("restored Search = %v; want minutes.md", got)
+	}
+
+	report, err := u.CollectGarbage(ctx, "1", GCOptions{Grace: -time.Hour})
+	if err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	for _, m := range report.Orphans {
+		if m.FileID == u.indexBackup.fileID {
+			t.Error("garbage collection deleted the current content index backup")
+		}
+	}
+	if got, want := indexBackups(), []string{u.indexBackup.fileID}; !reflect.DeepEqual(got, want) {
+		t.Errorf("index backups left after gc = %v; want %v", got, want)
+	}
+}
@@ -0,0 +1,242 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+// DirProgress is the aggregate progress of a directory operation. Percent
+// is weighted by file size.
+type DirProgress struct {
+	Files   int     `json:"files"`
+	Done    int     `json:"done"`
+	Failed  int     `json:"failed"`
+	Current string  `json:"current"`
+	Percent float64 `json:"percent"`
+}
+
+type DirProgressFn func(DirProgress)
+
+var ErrRootDirectory = errors.New("refusing to operate on the root directory")
+
+// DirResult lists the files a directory operation handled. One file failing
+// does not stop the others; its error is kept in Failed. Metadata is backed
+// up once, after the last file.
+type DirResult struct {
+	Done   []string          `json:"done"`
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+type dirJob struct {
+	name string
+	size int64
+	run  func(onProgress ProgressFn) error
+}
+
+// UploadDirectory uploads every regular file below dirPath. Records keep
+// the tree's shape under the directory's own name, so uploading
+// ~/src/project stores project/main.go and so on. Names that already exist
+// are reported as failures rather than overwritten.
+func (u *Uploader) UploadDirectory(ctx context.Context, dirPath string, chatID string, onProgress DirProgressFn) (*DirResult, error) {
+	dirPath = filepath.Clean(dirPath)
+	root := filepath.Base(dirPath)
+
+	var jobs []dirJob
+	err := filepath.WalkDir(dirPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dirPath, p)
+		if err != nil {
+			return err
+		}
+
+		name := path.Join(root, filepath.ToSlash(rel))
+		jobs = append(jobs, dirJob{
+			name: name,
+			size: info.Size(),
+			run: func(fn ProgressFn) error {
+				if _, err := u.Store.Get(ctx, name); err == nil {
+					return metadata.ErrAlreadyExists
+				}
+				_, err := u.create(ctx, p, name, chatID, PutOptions{}, fn)
+				return err
+			},
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walk %q: %w", dirPath, err)
+	}
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("no files in %q", dirPath)
+	}
+
+	return u.runDirJobs(ctx, chatID, jobs, onProgress)
+}
+
+// OffloadDirectory offloads every local file below dir, which must not be
+// the root.
+func (u *Uploader) OffloadDirectory(ctx context.Context, dir string, chatID string, onProgress DirProgressFn) (*DirResult, error) {
+	if err := checkNotRoot(dir); err != nil {
+		return nil, err
+	}
+	return u.dirRecords(ctx, dir, chatID, model.StateLocal, onProgress, func(rec *model.FileRecord, fn ProgressFn) error {
+		_, err := u.Offload(ctx, rec.Name)
+		return err
+	})
+}
+
+// DownloadDirectory downloads every cloud-only file below dir.
+func (u *Uploader) DownloadDirectory(ctx context.Context, dir string, chatID string, onProgress DirProgressFn) (*DirResult, error) {
+	return u.dirRecords(ctx, dir, chatID, model.StateCloud, onProgress, func(rec *model.FileRecord, fn ProgressFn) error {
+		_, err := u.Download(ctx, rec.Name, fn)
+		return err
+	})
+}
+
+// DeleteDirectory moves every file below dir, which must not be the root,
+// to the trash.
+func (u *Uploader) DeleteDirectory(ctx context.Context, dir string, chatID string, onProgress DirProgressFn) (*DirResult, error) {
+	if err := checkNotRoot(dir); err != nil {
+		return nil, err
+	}
+	return u.dirRecords(ctx, dir, chatID, "", onProgress, func(rec *model.FileRecord, fn ProgressFn) error {
+		return u.trash(ctx, rec.Name)
+	})
+}
+
+// RenameDirectory moves every file below oldDir to the same place below
+// newDir. Neither may be the root.
+func (u *Uploader) RenameDirectory(ctx context.Context, oldDir, newDir string, chatID string, onProgress DirProgressFn) (*DirResult, error) {
+	for _, dir := range []string{oldDir, newDir} {
+		if err := checkNotRoot(dir); err != nil {
+			return nil, err
+		}
+	}
+	prefix := strings.Trim(oldDir, "/") + "/"
+	return u.dirRecords(ctx, oldDir, chatID, "", onProgress, func(rec *model.FileRecord, fn ProgressFn) error {
+		return u.rename(ctx, rec.Name, path.Join(newDir, strings.TrimPrefix(rec.Name, prefix)))
+	})
+}
+
+// checkNotRoot refuses dir when it names the root, which would take in
+// every file in the store.
+func checkNotRoot(dir string) error {
+	if _, err := metadata.CleanName(dir); err != nil {
+		return fmt.Errorf("directory %q: %w", dir, ErrRootDirectory)
+	}
+	return nil
+}
+
+// dirRecords runs op on the records below dir, only those in state when it
+// is set.
+func (u *Uploader) dirRecords(
+	ctx context.Context,
+	dir string,
+	chatID string,
+	state model.FileState,
+	onProgress DirProgressFn,
+	op func(rec *model.FileRecord, fn ProgressFn) error,
+) (*DirResult, error) {
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list metadata: %w", err)
+	}
+
+	under := metadata.Under(recs, dir)
+	if len(under) == 0 {
+		return nil, fmt.Errorf("no files under %q: %w", dir, metadata.ErrNotFound)
+	}
+
+	var jobs []dirJob
+	for _, rec := range under {
+		if state != "" && rec.State != state {
+			continue
+		}
+		jobs = append(jobs, dirJob{
+			name: rec.Name,
+			size: rec.Size,
+			run:  func(fn ProgressFn) error { return op(rec, fn) },
+		})
+	}
+	return u.runDirJobs(ctx, chatID, jobs, onProgress)
+}
+
+// runDirJobs runs jobs one at a time, reporting progress across all of them,
+// and backs up metadata once if any job succeeded. Only cancellation stops
+// it early; the jobs done by then are still backed up.
+func (u *Uploader) runDirJobs(ctx context.Context, chatID string, jobs []dirJob, onProgress DirProgressFn) (*DirResult, error) {
+	result, err := runJobs(ctx, jobs, onProgress)
+	if len(result.Done) == 0 {
+		return result, err
+	}
+	if berr := u.BackupMetadata(context.WithoutCancel(ctx), chatID); berr != nil {
+		return result, errors.Join(err, fmt.Errorf("backup metadata: %w", berr))
+	}
+	return result, err
+}
+
+func runJobs(ctx context.Context, jobs []dirJob, onProgress DirProgressFn) (*DirResult, error) {
+	var total int64
+	for _, j := range jobs {
+		total += j.size
+	}
+
+	result := &DirResult{}
+	progress := DirProgress{Files: len(jobs)}
+	report := func(done int64) {
+		if onProgress == nil {
+			return
+		}
+		if total > 0 {
+			progress.Percent = float64(done) / float64(total) * 100
+		} else {
+			progress.Percent = float64(progress.Done+progress.Failed) / float64(len(jobs)) * 100
+		}
+		onProgress(progress)
+	}
+
+	var finished int64
+	for _, j := range jobs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+
+		progress.Current = j.name
+		report(finished)
+		err := j.run(func(p float64) {
+			report(finished + int64(float64(j.size)*p/100))
+		})
+		finished += j.size
+
+		if err != nil {
+			if result.Failed == nil {
+				result.Failed = make(map[string]string)
+			}
+			result.Failed[j.name] = err.Error()
+			progress.Failed++
+		} else {
+			result.Done = append(result.Done, j.name)
+			progress.Done++
+		}
+	}
+
+	progress.Current = ""
+	report(finished)
+	return result, nil
+}
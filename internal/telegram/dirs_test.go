@@ -0,0 +1,136 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+func TestUploader_DirectoryOperations(t *testing.T) {
+	srv, stored := chunkServer(t, false)
+	backups := func() int {
+		n := 0
+		for _, data := range stored {
+			if strings.HasPrefix(data, "[") {
+				n++
+			}
+		}
+		return n
+	}
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+
+	src := filepath.Join(tmp, "project")
+	for name, data := range map[string]string{
+		"main.go":        "package main",
+		"docs/readme.md": "hello",
+		"docs/empty.txt": "",
+	} {
+		p := filepath.Join(src, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	// Already stored, so it must be reported rather than overwritten.
+	if err := store.Create(context.Background(), &model.FileRecord{Name: "project/docs/empty.txt", State: model.StateCloud}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	syncDir := filepath.Join(tmp, "sync")
+	u := NewUploader(testClient(srv), store, syncDir, 4)
+	ctx := context.Background()
+
+	var last DirProgress
+	result, err := u.UploadDirectory(ctx, src, "1", func(p DirProgress) { last = p })
+	if err != nil {
+		t.Fatalf("UploadDirectory: %v", err)
+	}
+	sort.Strings(result.Done)
+	if want := []string{"project/docs/readme.md", "project/main.go"}; !reflect.DeepEqual(result.Done, want) {
+		t.Errorf("uploaded %v; want %v", result.Done, want)
+	}
+	if _, ok := result.Failed["project/docs/empty.txt"]; !ok || len(result.Failed) != 1 {
+		t.Errorf("failed = %v; want project/docs/empty.txt", result.Failed)
+	}
+	if last.Files != 3 || last.Done != 2 || last.Failed != 1 || last.Percent != 100 {
+		t.Errorf("last progress = %+v", last)
+	}
+	if n := backups(); n != 1 {
+		t.Errorf("UploadDirectory sent %d metadata backups; want 1", n)
+	}
+	if data, err := os.ReadFile(filepath.Join(syncDir, "project", "docs", "readme.md")); err != nil || string(data) != "hello" {
+		t.Errorf("synced readme = %q, %v", data, err)
+	}
+
+	result, err = u.OffloadDirectory(ctx, "project/docs", "1", nil)
+	if err != nil {
+		t.Fatalf("OffloadDirectory: %v", err)
+	}
+	if want := []string{"project/docs/readme.md"}; !reflect.DeepEqual(result.Done, want) {
+		t.Errorf("offloaded %v; want %v", result.Done, want)
+	}
+	if rec, _ := store.Get(ctx, "project/main.go"); rec.State != model.StateLocal {
+		t.Errorf("main.go outside the directory was offloaded")
+	}
+
+	result, err = u.DownloadDirectory(ctx, "project/docs", "1", nil)
+	if err != nil {
+		t.Fatalf("DownloadDirectory: %v", err)
+	}
+	if want := []string{"project/docs/readme.md"}; !reflect.DeepEqual(result.Done, want) {
+		t.Errorf("downloaded %v; want %v", result.Done, want)
+	}
+
+	for _, dir := range []string{"", "/", "."} {
+		if _, err := u.DeleteDirectory(ctx, dir, "1", nil); !errors.Is(err, ErrRootDirectory) {
+			t.Errorf("DeleteDirectory(%q) err = %v; want %v", dir, err, ErrRootDirectory)
+		}
+		if _, err := u.OffloadDirectory(ctx, dir, "1", nil); !errors.Is(err, ErrRootDirectory) {
+			t.Errorf("OffloadDirectory(%q) err = %v; want %v", dir, err, ErrRootDirectory)
+		}
+		if _, err := u.RenameDirectory(ctx, "project", dir, "1", nil); !errors.Is(err, ErrRootDirectory) {
+			t.Errorf("RenameDirectory to %q err = %v; want %v", dir, err, ErrRootDirectory)
+		}
+	}
+
+	result, err = u.RenameDirectory(ctx, "project/docs", "project/notes", "1", nil)
+	if err != nil {
+		t.Fatalf("RenameDirectory: %v", err)
+	}
+	if want := []string{"project/docs/empty.txt", "project/docs/readme.md"}; !reflect.DeepEqual(result.Done, want) {
+		t.Errorf("renamed %v; want %v", result.Done, want)
+	}
+	if _, err := store.Get(ctx, "project/notes/empty.txt"); err != nil {
+		t.Errorf("renamed record: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(syncDir, "project", "notes", "readme.md")); err != nil || string(data) != "hello" {
+		t.Errorf("renamed readme = %q, %v", data, err)
+	}
+
+	if _, err := u.DeleteDirectory(ctx, "project", "1", nil); err != nil {
+		t.Fatalf("DeleteDirectory: %v", err)
+	}
+	if recs, _ := store.List(ctx); len(recs) != 0 {
+		t.Errorf("%d records left after DeleteDirectory", len(recs))
+	}
+	if n := backups(); n != 5 {
+		t.Errorf("sent %d metadata backups for five directory operations; want 5", n)
+	}
+	if _, err := u.DeleteDirectory(ctx, "project", "1", nil); err == nil {
+		t.Error("expected an error for a directory with no files")
+	}
+}
@@ -0,0 +1,68 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"tstore/internal/ingestion"
+	"tstore/internal/metadata"
+)
+
+func TestUploader_ReconstructsFromParity(t *testing.T) {
+	srv, chunks := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "src.bin")
+	if err := os.WriteFile(src, []byte("abcdefghij"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 4)
+	u.Erasure, err = ingestion.NewErasure(2, 1)
+	if err != nil {
+		t.Fatalf("NewErasure: %v", err)
+	}
+
+	ctx := context.Background()
+	rec, err := u.UploadFileAs(ctx, src, "data.bin", "1", nil)
+	if err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	if len(rec.ParityChunks) != 2 || len(rec.ParityChecksums) != 2 || len(rec.ChunkChecksums) != 3 {
+		t.Fatalf("parity = %+v, checksums = %d; want 2 parity chunks, 3 checksums", rec.ParityChunks, len(rec.ChunkChecksums))
+	}
+	// Three data chunks, two parity chunks and the metadata backup.
+	if len(chunks) != 6 {
+		t.Errorf("server holds %d documents; want 6", len(chunks))
+	}
+
+	// One chunk gone and the short last chunk corrupted: each stripe has
+	// lost one chunk, which a single parity chunk covers.
+	delete(chunks, rec.ChunkIds[0])
+	chunks[rec.ChunkIds[2]] = "xx"
+
+	data, err := u.ReadChunk(ctx, rec, 2)
+	if err != nil || string(data) != "ij" {
+		t.Fatalf("ReadChunk(2) = %q, %v; want %q", data, err, "ij")
+	}
+	if err := os.Remove(filepath.Join(tmp, "sync", "data.bin")); err != nil {
+		t.Fatalf("remove local copy: %v", err)
+	}
+	if err := u.DownloadFile(ctx, "data.bin", "1", func(float64) {}); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	got, _ := os.ReadFile(filepath.Join(tmp, "sync", "data.bin"))
+	if string(got) != "abcdefghij" {
+		t.Errorf("downloaded %q; want %q", got, "abcdefghij")
+	}
+
+	delete(chunks, rec.ChunkIds[1])
+	if _, err := u.ReadChunk(ctx, rec, 0); err == nil {
+		t.Error("expected an error with two chunks of a 2+1 stripe lost")
+	}
+}
@@ -0,0 +1,190 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"tstore/pkg/model"
+)
+
+type GCOptions struct {
+	// Grace keeps unreferenced messages younger than this, so chunks of an
+	// upload still in progress are not collected.
+	Grace  time.Duration
+	DryRun bool
+}
+
+type GCReport struct {
+	DryRun     bool          `json:"dry_run"`
+	Scanned    int           `json:"scanned"`
+	Referenced int           `json:"referenced"`
+	Pending    int           `json:"pending"`
+	Orphans    []SentMessage `json:"orphans"`
+	Failed     int           `json:"failed"`
+}
+
+// RunGC calls CollectGarbage every interval until ctx is cancelled.
+func (u *Uploader) RunGC(ctx context.Context, chatID string, opts GCOptions, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := u.CollectGarbage(ctx, chatID, opts)
+		if err != nil {
+			log.Printf("gc: %v", err)
+		} else if len(report.Orphans) > 0 {
+			log.Printf("gc: deleted %d orphaned messages, %d failed", len(report.Orphans)-report.Failed, report.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CollectGarbage deletes messages in the storage chats that the client sent
+// but nothing references any more: chunks of failed uploads and of records
+// dropped without a purge, and superseded metadata backups. A message is
+// referenced when a live or trashed record points at its file ID, when it
+// is the pinned backup of its chat, or when it is the content index backup
+// that backup names. Messages sent to other chats, such as bot replies, are
+// dropped from the log without being deleted. With DryRun set nothing is
+// deleted or forgotten.
+func (u *Uploader) CollectGarbage(ctx context.Context, chatID string, opts GCOptions) (*GCReport, error) {
+	if u.Client.Sent == nil {
+		return nil, fmt.Errorf("no sent message log configured")
+	}
+	sent, err := u.Client.Sent.List()
+	if err != nil {
+		return nil, fmt.Errorf("read sent message log: %w", err)
+	}
+
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list metadata: %w", err)
+	}
+	trashed, err := u.Store.ListTrash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	recs = append(recs, trashed...)
+	referenced := make(map[string]bool)
+	for _, rec := range recs {
+		for _, id := range storedFileIDs(rec) {
+			referenced[id] = true
+		}
+	}
+
+	storage := u.storageClients(chatID)
+	pinned := make(map[string]int, len(storage))
+	for chat, client := range storage {
+		pm, err := client.GetPinnedMessage(ctx, chat)
+		if err != nil {
+			return nil, fmt.Errorf("find pinned backup in %s: %w", chat, err)
+		}
+		if pm != nil {
+			pinned[chat] = pm.MessageID
+			if id := indexBackupID(pm.Caption); id != "" {
+				referenced[id] = true
+			}
+		}
+	}
+
+	report := &GCReport{DryRun: opts.DryRun}
+	cutoff := time.Now().Add(-opts.Grace)
+	var forget []SentMessage
+	for _, m := range sent {
+		if m.SentAt.After(cutoff) {
+			if storage[m.ChatID] != nil {
+				report.Scanned++
+				report.Pending++
+			}
+			continue
+		}
+		if storage[m.ChatID] == nil {
+			forget = append(forget, m)
+			continue
+		}
+		report.Scanned++
+		if (m.FileID != "" && referenced[m.FileID]) || pinned[m.ChatID] == m.MessageID {
+			report.Referenced++
+			continue
+		}
+		report.Orphans = append(report.Orphans, m)
+	}
+
+	if opts.DryRun {
+		return report, nil
+	}
+
+	failed, err := u.deleteSent(ctx, report.Orphans, forget)
+	report.Failed = failed
+	return report, err
+}
+
+// storageClients maps chatID and the chats of the storage targets to the
+// client that stores files in each.
+func (u *Uploader) storageClients(chatID string) map[string]*Client {
+	storage := map[string]*Client{chatID: u.Client}
+	for _, t := range u.Targets {
+		if storage[t.ChatID] == nil {
+			storage[t.ChatID] = t.Client
+		}
+	}
+	return storage
+}
+
+// deleteSent deletes msgs using the bot that sent each one, then drops the
+// deleted ones and extra from the sent log. Failed deletions are logged and
+// counted; the messages stay in the log to be retried.
+func (u *Uploader) deleteSent(ctx context.Context, msgs, extra []SentMessage) (failed int, err error) {
+	clients := map[string]*Client{u.Client.botID(): u.Client}
+	for _, t := range u.Targets {
+		if clients[t.Client.botID()] == nil {
+			clients[t.Client.botID()] = t.Client
+		}
+	}
+
+	forget := extra
+	for _, m := range msgs {
+		client := clients[m.Bot]
+		if client == nil {
+			client = u.Client
+		}
+		if err := client.DeleteMessage(ctx, m.ChatID, m.MessageID); err != nil {
+			if ctx.Err() != nil {
+				return failed, ctx.Err()
+			}
+			log.Printf("delete message %d in %s: %v", m.MessageID, m.ChatID, err)
+			failed++
+			continue
+		}
+		forget = append(forget, m)
+	}
+
+	if err := u.Client.Sent.Forget(forget); err != nil {
+		return failed, fmt.Errorf("update sent message log: %w", err)
+	}
+	return failed, nil
+}
+
+// storedFileIDs lists the file IDs of every stored copy of rec's chunks,
+// parity and preview included.
+func storedFileIDs(rec *model.FileRecord) []string {
+	var ids []string
+	for i := range rec.ChunkIds {
+		for _, loc := range rec.ChunkLocations(i) {
+			ids = append(ids, loc.FileID)
+		}
+	}
+	for _, loc := range rec.ParityChunks {
+		ids = append(ids, loc.FileID)
+	}
+	if rec.Preview != nil {
+		ids = append(ids, rec.Preview.FileID)
+	}
+	return ids
+}
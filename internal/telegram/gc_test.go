@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+)
+
+func TestUploader_CollectGarbage(t *testing.T) {
+	srv, stored := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	sent, err := NewSentLog(filepath.Join(tmp, "sent.jsonl"))
+	if err != nil {
+		t.Fatalf("NewSentLog: %v", err)
+	}
+	client := testClient(srv)
+	client.token = "42:secret"
+	client.Sent = sent
+
+	u := NewUploader(client, store, filepath.Join(tmp, "sync"), 4)
+	ctx := context.Background()
+	for _, name := range []string{"keep.bin", "drop.bin"} {
+		src := filepath.Join(tmp, name)
+		if err := os.WriteFile(src, []byte("abcdefgh"), 0o600); err != nil {
+			t.Fatalf("write source: %v", err)
+		}
+		if _, err := u.UploadFileAs(ctx, src, name, "1", nil); err != nil {
+			t.Fatalf("UploadFileAs(%s): %v", name, err)
+		}
+	}
+	keep, _ := store.Get(ctx, "keep.bin")
+	if err := store.Delete(ctx, "drop.bin"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	// A chunk of an upload that failed before its record was written.
+	if _, err := client.SendChunk(ctx, "1", strings.NewReader("zz"), 0); err != nil {
+		t.Fatalf("SendChunk: %v", err)
+	}
+	if err := u.BackupMetadata(ctx, "1"); err != nil {
+		t.Fatalf("BackupMetadata: %v", err)
+	}
+
+	report, err := u.CollectGarbage(ctx, "1", GCOptions{Grace: time.Hour})
+	if err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	if report.Pending != 8 || len(report.Orphans) != 0 {
+		t.Errorf("within grace: %+v; want 8 pending, no orphans", report)
+	}
+
+	// Two chunks of drop.bin, the failed chunk and two superseded backups.
+	report, err = u.CollectGarbage(ctx, "1", GCOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if len(report.Orphans) != 5 || report.Referenced != 3 {
+		t.Errorf("dry run: %d orphans, %d referenced; want 5, 3", len(report.Orphans), report.Referenced)
+	}
+	if len(stored) != 8 {
+		t.Errorf("dry run deleted messages: %d left; want 8", len(stored))
+	}
+
+	report, err = u.CollectGarbage(ctx, "1", GCOptions{})
+	if err != nil {
+		t.Fatalf("CollectGarbage: %v", err)
+	}
+	if len(report.Orphans) != 5 || report.Failed != 0 || len(stored) != 3 {
+		t.Errorf("report = %+v, %d messages left; want 5 deleted, 3 left", report, len(stored))
+	}
+	for _, m := range report.Orphans {
+		if m.Bot != "42" {
+			t.Errorf("orphan bot = %q; want 42", m.Bot)
+		}
+	}
+	if msgs, _ := sent.List(); len(msgs) != 3 {
+		t.Errorf("sent log holds %d messages; want 3", len(msgs))
+	}
+
+	data, err := u.ReadChunk(ctx, keep, 1)
+	if err != nil || string(data) != "efgh" {
+		t.Errorf("ReadChunk after gc = %q, %v", data, err)
+	}
+}
@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"tstore/internal/preview"
+	"tstore/pkg/model"
+)
+
+// sendPreview generates a preview of the file at localPath and stores it on
+// the primary chat. Previews are best effort: failures are logged and the
+// file is stored without one.
+func (u *Uploader) sendPreview(ctx context.Context, localPath, name, chatID string) *model.Preview {
+	p, err := preview.Generate(localPath, name)
+	if err != nil {
+		log.Printf("preview %q: %v", name, err)
+		return nil
+	}
+	if p == nil {
+		return nil
+	}
+
+	fileID, err := u.Client.SendChunk(ctx, chatID, bytes.NewReader(p.Data), 0)
+	if err != nil {
+		log.Printf("send preview of %q: %v", name, err)
+		return nil
+	}
+	u.recordTransfer(true, int64(len(p.Data)))
+	return &model.Preview{Kind: p.Kind, MIME: p.MIME, FileID: fileID, Size: int64(len(p.Data))}
+}
+
+// ReadPreview returns the stored preview of rec, going through the chunk
+// cache when there is one.
+func (u *Uploader) ReadPreview(ctx context.Context, rec *model.FileRecord) ([]byte, error) {
+	if rec.Preview == nil {
+		return nil, fmt.Errorf("%q has no preview: %w", rec.Name, model.ErrNotFound)
+	}
+
+	fetch := func(ctx context.Context) (io.ReadCloser, error) {
+		return u.Client.DownloadFile(ctx, rec.Preview.FileID)
+	}
+	if u.Cache == nil {
+		rc, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return u.Cache.Get(ctx, rec.Preview.FileID, fetch)
+}
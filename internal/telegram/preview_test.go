@@ -0,0 +1,99 @@
+package telegram
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+func TestUploader_Preview(t *testing.T) {
+	srv, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "readme.txt")
+	if err := os.WriteFile(src, []byte("Hello from the cloud.\n"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 4)
+	u.Previews = true
+	ctx := context.Background()
+	if _, err := u.UploadFileAs(ctx, src, "readme.txt", "1", nil); err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	if err := u.OffloadFile(ctx, "readme.txt", "1"); err != nil {
+		t.Fatalf("OffloadFile: %v", err)
+	}
+
+	rec, err := store.Get(ctx, "readme.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if rec.Preview == nil || rec.Preview.Kind != model.PreviewText {
+		t.Fatalf("Preview = %+v; want a text preview", rec.Preview)
+	}
+	data, err := u.ReadPreview(ctx, rec)
+	if err != nil || string(data) != "Hello from the cloud." {
+		t.Errorf("ReadPreview = %q, %v", data, err)
+	}
+
+	ids := storedFileIDs(rec)
+	if ids[len(ids)-1] != rec.Preview.FileID {
+		t.Errorf("storedFileIDs = %v; want the preview included", ids)
+	}
+}
+
+func TestUploader_PreviewSentBeforeMove(t *testing.T) {
+	backend, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	dst := filepath.Join(tmp, "sync", "readme.txt")
+	// inSync records, for each document sent, whether the file had already
+	// been moved into the sync folder.
+	var inSync []bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/sendDocument" {
+			_, err := os.Stat(dst)
+			inSync = append(inSync, err == nil)
+		}
+		backend.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "readme.txt")
+	if err := os.WriteFile(src, []byte("Hello from the cloud.\n"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 4)
+	u.Previews = true
+	rec, err := u.UploadFileAs(context.Background(), src, "readme.txt", "1", nil)
+	if err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	if rec.Preview == nil {
+		t.Fatal("no preview sent")
+	}
+
+	// Chunks, then the preview, then the metadata backup.
+	if want := len(rec.ChunkIds) + 2; len(inSync) != want {
+		t.Fatalf("%d documents sent; want %d", len(inSync), want)
+	}
+	if slices.Contains(inSync[:len(inSync)-1], true) {
+		t.Errorf("file was in the sync folder while chunks or preview were sent: %v", inSync)
+	}
+}
@@ -0,0 +1,195 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"tstore/pkg/model"
+)
+
+type ChunkSource interface {
+	ReadChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error)
+	ChunkSizeOf(rec *model.FileRecord) int64
+}
+
+// FileReader exposes a stored file as io.ReaderAt and io.ReadSeeker,
+// fetching only the chunks that cover the requested offsets.
+type FileReader struct {
+	ctx       context.Context
+	chunks    ChunkSource
+	rec       *model.FileRecord
+	chunkSize int64
+	off       int64
+
+	mu        sync.Mutex
+	lastIndex int
+	lastData  []byte
+}
+
+func NewFileReader(ctx context.Context, chunks ChunkSource, rec *model.FileRecord) *FileReader {
+	return &FileReader{
+		ctx:       ctx,
+		chunks:    chunks,
+		rec:       rec,
+		chunkSize: chunks.ChunkSizeOf(rec),
+		lastIndex: -1,
+	}
+}
+
+func (r *FileReader) Size() int64 {
+	return r.rec.Size
+}
+
+func (r *FileReader) chunk(index int) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if index == r.lastIndex {
+		return r.lastData, nil
+	}
+	data, err := r.chunks.ReadChunk(r.ctx, r.rec, index)
+	if err != nil {
+		return nil, err
+	}
+	r.lastIndex, r.lastData = index, data
+	return data, nil
+}
+
+func (r *FileReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("negative offset")
+	}
+	if off >= r.rec.Size {
+		return 0, io.EOF
+	}
+	if r.chunkSize <= 0 {
+		return 0, fmt.Errorf("invalid chunk size %d for %q", r.chunkSize, r.rec.Name)
+	}
+
+	n := 0
+	for n < len(p) && off < r.rec.Size {
+		idx := int(off / r.chunkSize)
+		data, err := r.chunk(idx)
+		if err != nil {
+			return n, fmt.Errorf("read chunk %d of %q: %w", idx, r.rec.Name, err)
+		}
+
+		within := off - int64(idx)*r.chunkSize
+		if within >= int64(len(data)) {
+			return n, io.ErrUnexpectedEOF
+		}
+		c := copy(p[n:], data[within:])
+		n += c
+		off += int64(c)
+	}
+
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (r *FileReader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.off)
+	r.off += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (r *FileReader) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = r.off + offset
+	case io.SeekEnd:
+		abs = r.rec.Size + offset
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.off = abs
+	return abs, nil
+}
+
+func (r *FileReader) Close() error {
+	return nil
+}
+
+type Content interface {
+	io.ReadSeeker
+	io.ReaderAt
+	io.Closer
+}
+
+// Open returns the local copy of rec when there is one and a chunk-backed
+// FileReader otherwise.
+func (u *Uploader) Open(ctx context.Context, rec *model.FileRecord) (Content, error) {
+	if rec.State == model.StateLocal {
+		f, err := os.Open(filepath.Join(u.SyncFolder, filepath.FromSlash(rec.Name)))
+		if err == nil {
+			return f, nil
+		}
+	}
+	return NewFileReader(ctx, u, rec), nil
+}
+
+func (u *Uploader) OpenFile(ctx context.Context, name string) (Content, error) {
+	rec, err := u.Store.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %q: %w", name, err)
+	}
+	return u.Open(ctx, rec)
+}
+
+// ExtractRange writes length bytes starting at offset of the stored file to
+// dstPath, reading the local copy when there is one.
+func (u *Uploader) ExtractRange(ctx context.Context, name string, offset, length int64, dstPath string) error {
+	rec, err := u.Store.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("lookup %q: %w", name, err)
+	}
+	if offset < 0 || length <= 0 || offset+length > rec.Size {
+		return fmt.Errorf("range %d+%d outside of %q (%d bytes)", offset, length, name, rec.Size)
+	}
+
+	src, err := u.Open(ctx, rec)
+	if err != nil {
+		return fmt.Errorf("open %q: %w", name, err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o700); err != nil {
+		return fmt.Errorf("create parent dirs for %q: %w", dstPath, err)
+	}
+	tmpPath := dstPath + ".tmp"
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("open temp file %q: %w", tmpPath, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, io.NewSectionReader(src, offset, length)); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("copy range: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("close temp file %q: %w", tmpPath, err)
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("rename %q to %q: %w", tmpPath, dstPath, err)
+	}
+
+	return nil
+}
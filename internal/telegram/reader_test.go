@@ -0,0 +1,141 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+type fakeChunks struct {
+	size  int64
+	data  []string
+	reads []int
+}
+
+func (f *fakeChunks) ReadChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error) {
+	if index >= len(f.data) {
+		return nil, fmt.Errorf("chunk %d out of range", index)
+	}
+	f.reads = append(f.reads, index)
+	return []byte(f.data[index]), nil
+}
+
+func (f *fakeChunks) ChunkSizeOf(rec *model.FileRecord) int64 {
+	return f.size
+}
+
+func TestFileReader_ReadAtOnlyTouchedChunks(t *testing.T) {
+	chunks := &fakeChunks{size: 4, data: []string{"abcd", "efgh", "ij"}}
+	r := NewFileReader(context.Background(), chunks, &model.FileRecord{Name: "f", Size: 10})
+
+	buf := make([]byte, 3)
+	n, err := r.ReadAt(buf, 3)
+	if err != nil {
+		t.Fatalf("ReadAt: %v", err)
+	}
+	if got := string(buf[:n]); got != "def" {
+		t.Errorf("ReadAt(3) = %q; want %q", got, "def")
+	}
+	if !reflect.DeepEqual(chunks.reads, []int{0, 1}) {
+		t.Errorf("chunks read = %v; want [0 1]", chunks.reads)
+	}
+
+	buf = make([]byte, 5)
+	n, err = r.ReadAt(buf, 8)
+	if err != io.EOF {
+		t.Errorf("ReadAt past end err = %v; want io.EOF", err)
+	}
+	if got := string(buf[:n]); got != "ij" {
+		t.Errorf("ReadAt(8) = %q; want %q", got, "ij")
+	}
+}
+
+func TestFileReader_SeekAndRead(t *testing.T) {
+	chunks := &fakeChunks{size: 4, data: []string{"abcd", "efgh", "ij"}}
+	r := NewFileReader(context.Background(), chunks, &model.FileRecord{Name: "f", Size: 10})
+
+	if _, err := r.Seek(-4, io.SeekEnd); err != nil {
+		t.Fatalf("Seek: %v", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(data) != "ghij" {
+		t.Errorf("tail = %q; want %q", data, "ghij")
+	}
+	if !reflect.DeepEqual(chunks.reads, []int{1, 2}) {
+		t.Errorf("chunks read = %v; want [1 2]", chunks.reads)
+	}
+}
+
+func TestUploader_ExtractRange(t *testing.T) {
+	chunkData := map[string]string{"c0": "abcd", "c1": "efgh", "c2": "ij"}
+	var fetched []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/getFile":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"ok":true,"result":{"file_path":%q}}`, r.URL.Query().Get("file_id"))
+		case strings.HasPrefix(r.URL.Path, "/file/"):
+			id := strings.TrimPrefix(r.URL.Path, "/file/")
+			fetched = append(fetched, id)
+			io.WriteString(w, chunkData[id])
+		default:
+			t.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+
+	ctx := context.Background()
+	rec := &model.FileRecord{
+		Name:       "archive.bin",
+		State:      model.StateCloud,
+		Size:       10,
+		UploadedAt: time.Now(),
+		ChunkIds:   []string{"c0", "c1", "c2"},
+		ChunkSize:  4,
+	}
+	if err := store.Create(ctx, rec); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	client := &Client{baseURL: srv.URL, fileURL: srv.URL + "/file", client: srv.Client()}
+	u := NewUploader(client, store, tmp, 4)
+
+	dst := filepath.Join(tmp, "out", "range.bin")
+	if err := u.ExtractRange(ctx, rec.Name, 5, 4, dst); err != nil {
+		t.Fatalf("ExtractRange: %v", err)
+	}
+
+	data, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatalf("read extracted range: %v", err)
+	}
+	if string(data) != "fghi" {
+		t.Errorf("extracted = %q; want %q", data, "fghi")
+	}
+	if !reflect.DeepEqual(fetched, []string{"c1", "c2"}) {
+		t.Errorf("fetched chunks = %v; want [c1 c2]", fetched)
+	}
+
+	if err := u.ExtractRange(ctx, rec.Name, 8, 3, dst); err == nil {
+		t.Error("expected an error for a range past the end of the file")
+	}
+}
@@ -0,0 +1,173 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+	"tstore/pkg/model"
+)
+
+type RepairReport struct {
+	Checked  int      `json:"checked"`
+	Dropped  int      `json:"dropped"`
+	Repaired int      `json:"repaired"`
+	Lost     []string `json:"lost"`
+}
+
+// RunRepair calls Repair every interval until ctx is cancelled.
+func (u *Uploader) RunRepair(ctx context.Context, chatID string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := u.Repair(ctx, chatID)
+		if err != nil {
+			log.Printf("repair: %v", err)
+		} else if report.Dropped > 0 || report.Repaired > 0 || len(report.Lost) > 0 {
+			log.Printf("repair: dropped %d copies, added %d, %d files lost", report.Dropped, report.Repaired, len(report.Lost))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Repair checks every recorded copy of every chunk, forgets copies Telegram
+// no longer serves and uploads new ones for chunks that have fewer live
+// copies than the replication factor. Copies on targets that are no longer
+// configured are kept but not counted. Network or rate-limit errors abort
+// the run rather than being mistaken for missing copies.
+func (u *Uploader) Repair(ctx context.Context, chatID string) (*RepairReport, error) {
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list metadata: %w", err)
+	}
+
+	report := &RepairReport{}
+	changed := false
+	for _, rec := range recs {
+		chunkIDs := slices.Clone(rec.ChunkIds)
+		recChanged, err := u.repairRecord(ctx, rec, chatID, report)
+		if recChanged {
+			saved, err := u.saveRepair(ctx, rec, chunkIDs)
+			if err != nil {
+				return report, fmt.Errorf("update metadata for %q: %w", rec.Name, err)
+			}
+			changed = changed || saved
+		}
+		if err != nil {
+			return report, fmt.Errorf("repair %q: %w", rec.Name, err)
+		}
+	}
+
+	if changed {
+		if err := u.BackupMetadata(ctx, chatID); err != nil {
+			return report, fmt.Errorf("backup metadata: %w", err)
+		}
+	}
+	return report, nil
+}
+
+// saveRepair writes the chunk locations repair settled on for rec into the
+// record as it is now, so that changes made to it during the run are kept.
+// A record that was deleted, renamed or uploaded anew in the meantime is
+// left alone and false returned.
+func (u *Uploader) saveRepair(ctx context.Context, rec *model.FileRecord, chunkIDs []string) (bool, error) {
+	cur, err := u.Store.Get(ctx, rec.Name)
+	if errors.Is(err, model.ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !slices.Equal(cur.ChunkIds, chunkIDs) {
+		return false, nil
+	}
+	cur.ChunkIds = rec.ChunkIds
+	cur.ChunkTargets = rec.ChunkTargets
+	cur.Replicas = rec.Replicas
+	cur.ParityChunks = rec.ParityChunks
+	return true, u.Store.Update(ctx, cur)
+}
+
+func (u *Uploader) repairRecord(ctx context.Context, rec *model.FileRecord, chatID string, report *RepairReport) (changed bool, err error) {
+	lost := false
+	defer func() {
+		if lost {
+			report.Lost = append(report.Lost, rec.Name)
+		}
+	}()
+
+	for i := range rec.ChunkIds {
+		report.Checked++
+
+		var live, unreachable []model.ChunkLocation
+		dropped := 0
+		for _, loc := range rec.ChunkLocations(i) {
+			client, err := u.targetClient(loc.Target)
+			if err != nil {
+				unreachable = append(unreachable, loc)
+				continue
+			}
+			if _, err := client.GetFile(ctx, loc.FileID); errors.Is(err, ErrFileUnavailable) {
+				dropped++
+				continue
+			} else if err != nil {
+				return changed, err
+			}
+			live = append(live, loc)
+		}
+
+		if len(live) == 0 {
+			lost = true
+			continue
+		}
+
+		var added []model.ChunkLocation
+		if missing := u.copies() - len(live); missing > 0 && u.Placement != nil {
+			added, err = u.replicate(ctx, rec, i, chatID, live, missing)
+			if err != nil {
+				if ctx.Err() != nil {
+					return changed, ctx.Err()
+				}
+				log.Printf("repair: re-replicate chunk %d of %q: %v", i, rec.Name, err)
+			}
+		}
+
+		if dropped == 0 && len(added) == 0 {
+			continue
+		}
+		locs := append(append(live, added...), unreachable...)
+		rec.SetChunkLocations(i, locs)
+		report.Dropped += dropped
+		report.Repaired += len(added)
+		changed = true
+	}
+
+	return changed, nil
+}
+
+func (u *Uploader) replicate(
+	ctx context.Context,
+	rec *model.FileRecord,
+	index int,
+	chatID string,
+	live []model.ChunkLocation,
+	missing int,
+) ([]model.ChunkLocation, error) {
+	data, err := u.readLocation(ctx, live[0], rec.ChunkChecksum(index))
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make(map[string]bool, len(live))
+	for _, loc := range live {
+		exclude[loc.Target] = true
+	}
+	return u.sendChunk(ctx, rec.Name, chatID, data, index, missing, exclude)
+}
@@ -0,0 +1,238 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+func TestUploader_ReplicasFallbackAndRepair(t *testing.T) {
+	primary, _ := chunkServer(t, false)
+	srvA, chunksA := chunkServer(t, false)
+	srvB, chunksB := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "src.bin")
+	if err := os.WriteFile(src, []byte("abcdefgh"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(primary), store, filepath.Join(tmp, "sync"), 4)
+	u.Targets = []*Target{
+		{Name: "a", Client: testClient(srvA), ChatID: "1"},
+		{Name: "b", Client: testClient(srvB), ChatID: "2"},
+	}
+	u.Placement, _ = NewPlacement(PlacementFillFirst, u.Targets, nil, nil)
+	u.Replication = 2
+
+	ctx := context.Background()
+	rec, err := u.UploadFileAs(ctx, src, "data.bin", "1", nil)
+	if err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	if len(chunksA) != 2 || len(chunksB) != 2 {
+		t.Fatalf("chunks on a=%d b=%d; want 2 each", len(chunksA), len(chunksB))
+	}
+	if locs := rec.ChunkLocations(1); len(locs) != 2 || locs[0].Target != "a" || locs[1].Target != "b" {
+		t.Errorf("chunk 1 locations = %+v", locs)
+	}
+
+	// Losing every chunk on target a must not lose the file.
+	for id := range chunksA {
+		delete(chunksA, id)
+	}
+	data, err := u.ReadChunk(ctx, rec, 0)
+	if err != nil || string(data) != "abcd" {
+		t.Fatalf("ReadChunk after losing a = %q, %v", data, err)
+	}
+	if err := os.Remove(filepath.Join(tmp, "sync", "data.bin")); err != nil {
+		t.Fatalf("remove local copy: %v", err)
+	}
+	if err := u.DownloadFile(ctx, "data.bin", "1", func(float64) {}); err != nil {
+		t.Fatalf("DownloadFile after losing a: %v", err)
+	}
+
+	report, err := u.Repair(ctx, "1")
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if report.Checked != 2 || report.Dropped != 2 || report.Repaired != 2 || len(report.Lost) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(chunksA) != 2 {
+		t.Errorf("target a holds %d chunks after repair; want 2", len(chunksA))
+	}
+
+	rec, _ = store.Get(ctx, "data.bin")
+	for i := range rec.ChunkIds {
+		locs := rec.ChunkLocations(i)
+		if len(locs) != 2 || locs[0].Target != "b" || locs[1].Target != "a" {
+			t.Errorf("chunk %d locations after repair = %+v", i, locs)
+		}
+	}
+
+	for id := range chunksB {
+		delete(chunksB, id)
+	}
+	for id := range chunksA {
+		delete(chunksA, id)
+	}
+	report, err = u.Repair(ctx, "1")
+	if err != nil {
+		t.Fatalf("second Repair: %v", err)
+	}
+	if len(report.Lost) != 1 || report.Lost[0] != "data.bin" {
+		t.Errorf("lost = %v; want [data.bin]", report.Lost)
+	}
+}
+
+// failingUpdates is a store whose updates always fail.
+type failingUpdates struct {
+	metadata.Store
+}
+
+func (failingUpdates) Update(ctx context.Context, rec *model.FileRecord) error {
+	return errors.New("disk full")
+}
+
+func TestUploader_RepairLeavesStoreAloneWhenUpdateFails(t *testing.T) {
+	primary, _ := chunkServer(t, false)
+	srvA, chunksA := chunkServer(t, false)
+	srvB, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "src.bin")
+	if err := os.WriteFile(src, []byte("abcdefgh"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(primary), store, filepath.Join(tmp, "sync"), 4)
+	u.Targets = []*Target{
+		{Name: "a", Client: testClient(srvA), ChatID: "1"},
+		{Name: "b", Client: testClient(srvB), ChatID: "2"},
+	}
+	u.Placement, _ = NewPlacement(PlacementFillFirst, u.Targets, nil, nil)
+	u.Replication = 2
+
+	ctx := context.Background()
+	if _, err := u.UploadFileAs(ctx, src, "data.bin", "1", nil); err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	// Copy the slices so the comparison does not share them with the store.
+	before, _ := store.Get(ctx, "data.bin")
+	before.ChunkIds = slices.Clone(before.ChunkIds)
+	before.ChunkTargets = slices.Clone(before.ChunkTargets)
+	before.Replicas = slices.Clone(before.Replicas)
+	for id := range chunksA {
+		delete(chunksA, id)
+	}
+
+	u.Store = failingUpdates{store}
+	if _, err := u.Repair(ctx, "1"); err == nil {
+		t.Fatal("Repair succeeded although the update failed")
+	}
+
+	after, _ := store.Get(ctx, "data.bin")
+	if !reflect.DeepEqual(after.ChunkIds, before.ChunkIds) ||
+		!reflect.DeepEqual(after.ChunkTargets, before.ChunkTargets) ||
+		!reflect.DeepEqual(after.Replicas, before.Replicas) {
+		t.Errorf("stored record changed by a failed repair:\nbefore %v %v %v\nafter  %v %v %v",
+			before.ChunkIds, before.ChunkTargets, before.Replicas,
+			after.ChunkIds, after.ChunkTargets, after.Replicas)
+	}
+}
+
+// staleList is a store whose List returns a snapshot taken earlier, as it
+// looks to a repair that started before the records were changed.
+type staleList struct {
+	metadata.Store
+	recs []*model.FileRecord
+}
+
+func (s staleList) List(ctx context.Context) ([]*model.FileRecord, error) {
+	return s.recs, nil
+}
+
+func TestUploader_RepairKeepsChangesMadeDuringTheRun(t *testing.T) {
+	primary, _ := chunkServer(t, false)
+	srvA, chunksA := chunkServer(t, false)
+	srvB, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	u := NewUploader(testClient(primary), store, filepath.Join(tmp, "sync"), 4)
+	u.Targets = []*Target{
+		{Name: "a", Client: testClient(srvA), ChatID: "1"},
+		{Name: "b", Client: testClient(srvB), ChatID: "2"},
+	}
+	u.Placement, _ = NewPlacement(PlacementFillFirst, u.Targets, nil, nil)
+	u.Replication = 2
+
+	ctx := context.Background()
+	src := filepath.Join(tmp, "src.bin")
+	for _, name := range []string{"data.bin", "gone.bin"} {
+		if err := os.WriteFile(src, []byte("abcdefgh"), 0o600); err != nil {
+			t.Fatalf("write source: %v", err)
+		}
+		if _, err := u.UploadFileAs(ctx, src, name, "1", nil); err != nil {
+			t.Fatalf("UploadFileAs %s: %v", name, err)
+		}
+	}
+	snapshot, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	for id := range chunksA {
+		delete(chunksA, id)
+	}
+
+	rec, _ := store.Get(ctx, "data.bin")
+	rec.Description = "edited during repair"
+	rec.State = model.StateCloud
+	if err := store.Update(ctx, rec); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := store.Trash(ctx, "gone.bin", time.Now()); err != nil {
+		t.Fatalf("Trash: %v", err)
+	}
+
+	u.Store = staleList{store, snapshot}
+	report, err := u.Repair(ctx, "1")
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if report.Repaired != 4 {
+		t.Errorf("report = %+v; want 4 repaired copies", report)
+	}
+
+	rec, _ = store.Get(ctx, "data.bin")
+	if rec.Description != "edited during repair" || rec.State != model.StateCloud {
+		t.Errorf("repair reverted the record to description %q, state %v", rec.Description, rec.State)
+	}
+	for i := range rec.ChunkIds {
+		if locs := rec.ChunkLocations(i); len(locs) != 2 || locs[1].Target != "a" {
+			t.Errorf("chunk %d locations after repair = %+v", i, locs)
+		}
+	}
+	if _, err := store.Get(ctx, "gone.bin"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("trashed record is back after repair: %v", err)
+	}
+}
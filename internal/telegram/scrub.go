@@ -0,0 +1,240 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"time"
+	"tstore/pkg/model"
+)
+
+const (
+	// ScrubCheck only asks Telegram whether each chunk is still served.
+	ScrubCheck = "check"
+	// ScrubSample also downloads and hashes a few chunks of every file.
+	ScrubSample = "sample"
+	// ScrubFull downloads and hashes every chunk.
+	ScrubFull = "full"
+)
+
+const (
+	HealthOK      = "ok"
+	HealthMissing = "missing"
+	HealthCorrupt = "corrupt"
+)
+
+type ScrubOptions struct {
+	Mode string
+	// Sample is how many chunks per file ScrubSample downloads; zero means
+	// one.
+	Sample int
+}
+
+// FileHealth is the scrub result for one file. Missing and Corrupt hold
+// chunk indexes; a corrupt file without indexes failed its whole-file
+// checksum.
+type FileHealth struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Missing []int  `json:"missing,omitempty"`
+	Corrupt []int  `json:"corrupt,omitempty"`
+}
+
+type ScrubReport struct {
+	Mode       string       `json:"mode"`
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	Checked    int          `json:"checked"`
+	Verified   int          `json:"verified"`
+	Files      []FileHealth `json:"files"`
+}
+
+// Damaged returns the files whose status is not ok.
+func (r *ScrubReport) Damaged() []FileHealth {
+	var out []FileHealth
+	for _, f := range r.Files {
+		if f.Status != HealthOK {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func ValidScrubMode(mode string) bool {
+	switch mode {
+	case ScrubCheck, ScrubSample, ScrubFull:
+		return true
+	}
+	return false
+}
+
+func SaveScrubReport(path string, r *ScrubReport) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func LoadScrubReport(path string) (*ScrubReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r ScrubReport
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parse scrub report: %w", err)
+	}
+	return &r, nil
+}
+
+// RunScrub calls Scrub every interval until ctx is cancelled, handing each
+// finished report to done.
+func (u *Uploader) RunScrub(ctx context.Context, opts ScrubOptions, interval time.Duration, done func(*ScrubReport)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := u.Scrub(ctx, opts)
+		if err != nil {
+			log.Printf("scrub: %v", err)
+		} else {
+			if n := len(report.Damaged()); n > 0 {
+				log.Printf("scrub: %d of %d files damaged", n, len(report.Files))
+			}
+			done(report)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scrub checks that every chunk of every file can still be fetched from at
+// least one of its copies, and in the sample and full modes that the
+// downloaded bytes match their checksums. Files uploaded before chunk
+// checksums were recorded are checked against the whole-file checksum in
+// full mode only. Like Repair, network errors abort the run.
+func (u *Uploader) Scrub(ctx context.Context, opts ScrubOptions) (*ScrubReport, error) {
+	if opts.Mode == "" {
+		opts.Mode = ScrubCheck
+	}
+	if !ValidScrubMode(opts.Mode) {
+		return nil, fmt.Errorf("unknown scrub mode %q", opts.Mode)
+	}
+
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list metadata: %w", err)
+	}
+
+	report := &ScrubReport{Mode: opts.Mode, StartedAt: time.Now()}
+	for _, rec := range recs {
+		health, err := u.scrubRecord(ctx, rec, opts, report)
+		if err != nil {
+			return nil, fmt.Errorf("scrub %q: %w", rec.Name, err)
+		}
+		report.Files = append(report.Files, health)
+	}
+	report.FinishedAt = time.Now()
+	return report, nil
+}
+
+func (u *Uploader) scrubRecord(ctx context.Context, rec *model.FileRecord, opts ScrubOptions, report *ScrubReport) (FileHealth, error) {
+	health := FileHealth{Name: rec.Name}
+
+	download := make([]bool, len(rec.ChunkIds))
+	switch opts.Mode {
+	case ScrubFull:
+		for i := range download {
+			download[i] = true
+		}
+	case ScrubSample:
+		for _, i := range rand.Perm(len(download))[:min(max(opts.Sample, 1), len(download))] {
+			download[i] = true
+		}
+	}
+
+	// Without chunk checksums only the whole file can be verified, and only
+	// when every chunk is downloaded.
+	legacy := len(rec.ChunkChecksums) == 0 && opts.Mode == ScrubFull
+	hasher := sha256.New()
+
+	for i := range rec.ChunkIds {
+		report.Checked++
+		data, status, err := u.scrubChunk(ctx, rec, i, download[i])
+		if err != nil {
+			return health, err
+		}
+		switch status {
+		case HealthMissing:
+			health.Missing = append(health.Missing, i)
+		case HealthCorrupt:
+			health.Corrupt = append(health.Corrupt, i)
+		}
+		if download[i] && status != HealthMissing {
+			report.Verified++
+		}
+		if legacy {
+			hasher.Write(data)
+		}
+	}
+
+	switch {
+	case len(health.Missing) > 0:
+		health.Status = HealthMissing
+	case len(health.Corrupt) > 0:
+		health.Status = HealthCorrupt
+	case legacy && hex.EncodeToString(hasher.Sum(nil)) != rec.Checksum:
+		health.Status = HealthCorrupt
+	default:
+		health.Status = HealthOK
+	}
+	return health, nil
+}
+
+// scrubChunk reports whether any copy of chunk index is available and, when
+// download is set, intact. data is the first good copy when downloaded.
+func (u *Uploader) scrubChunk(ctx context.Context, rec *model.FileRecord, index int, download bool) (data []byte, status string, err error) {
+	status = HealthMissing
+	for _, loc := range rec.ChunkLocations(index) {
+		client, err := u.targetClient(loc.Target)
+		if err != nil {
+			continue
+		}
+
+		if !download {
+			if _, err := client.GetFile(ctx, loc.FileID); errors.Is(err, ErrFileUnavailable) {
+				continue
+			} else if err != nil {
+				return nil, "", err
+			}
+			return nil, HealthOK, nil
+		}
+
+		data, err := u.readLocation(ctx, loc, rec.ChunkChecksum(index))
+		switch {
+		case err == nil:
+			return data, HealthOK, nil
+		case errors.Is(err, ErrChecksumMismatch):
+			status = HealthCorrupt
+		case errors.Is(err, ErrFileUnavailable):
+		default:
+			return nil, "", err
+		}
+	}
+	return nil, status, nil
+}
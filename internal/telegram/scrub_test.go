@@ -0,0 +1,113 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tstore/internal/metadata"
+)
+
+func TestUploader_Scrub(t *testing.T) {
+	srv, chunks := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "src.bin")
+	if err := os.WriteFile(src, []byte("abcdefghij"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 4)
+	ctx := context.Background()
+	rec, err := u.UploadFileAs(ctx, src, "data.bin", "1", nil)
+	if err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+
+	report, err := u.Scrub(ctx, ScrubOptions{Mode: ScrubSample})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	if report.Checked != 3 || report.Verified != 1 || len(report.Damaged()) != 0 {
+		t.Errorf("clean report = %+v", report)
+	}
+
+	chunks[rec.ChunkIds[1]] = "xxxx"
+	report, err = u.Scrub(ctx, ScrubOptions{Mode: ScrubCheck})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	if len(report.Damaged()) != 0 {
+		t.Errorf("check mode flagged %+v; it does not download chunks", report.Damaged())
+	}
+
+	delete(chunks, rec.ChunkIds[0])
+	report, err = u.Scrub(ctx, ScrubOptions{Mode: ScrubFull})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	want := FileHealth{Name: "data.bin", Status: HealthMissing, Missing: []int{0}, Corrupt: []int{1}}
+	if len(report.Files) != 1 || !reflect.DeepEqual(report.Files[0], want) {
+		t.Errorf("files = %+v; want [%+v]", report.Files, want)
+	}
+
+	path := filepath.Join(tmp, "scrub.json")
+	if err := SaveScrubReport(path, report); err != nil {
+		t.Fatalf("SaveScrubReport: %v", err)
+	}
+	loaded, err := LoadScrubReport(path)
+	if err != nil {
+		t.Fatalf("LoadScrubReport: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Damaged(), report.Damaged()) {
+		t.Errorf("loaded damaged = %+v; want %+v", loaded.Damaged(), report.Damaged())
+	}
+}
+
+func TestUploader_ScrubWholeFileChecksum(t *testing.T) {
+	srv, chunks := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "src.bin")
+	if err := os.WriteFile(src, []byte("abcdefgh"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 4)
+	ctx := context.Background()
+	rec, err := u.UploadFileAs(ctx, src, "old.bin", "1", nil)
+	if err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	// Records written before chunk checksums existed.
+	rec.ChunkChecksums = nil
+	if err := store.Update(ctx, rec); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+
+	report, err := u.Scrub(ctx, ScrubOptions{Mode: ScrubFull})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	if got := report.Files[0].Status; got != HealthOK {
+		t.Errorf("status = %q; want ok", got)
+	}
+
+	chunks[rec.ChunkIds[1]] = "xxxx"
+	report, err = u.Scrub(ctx, ScrubOptions{Mode: ScrubFull})
+	if err != nil {
+		t.Fatalf("Scrub: %v", err)
+	}
+	if got := report.Files[0]; got.Status != HealthCorrupt || len(got.Corrupt) != 0 {
+		t.Errorf("file = %+v; want corrupt without chunk indexes", got)
+	}
+}
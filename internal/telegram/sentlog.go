@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SentMessage is one message a client posted. The Bot API cannot list a
+// chat's history, so this log is the only way to find chunks that no record
+// references any more.
+type SentMessage struct {
+	Bot       string    `json:"bot"`
+	ChatID    string    `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	FileID    string    `json:"file_id,omitempty"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// SentLog is an append-only JSON lines file of sent messages, shared by
+// every client.
+type SentLog struct {
+	mu   sync.Mutex
+	path string
+}
+
+func NewSentLog(path string) (*SentLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create sent log dir: %w", err)
+	}
+	return &SentLog{path: path}, nil
+}
+
+func (l *SentLog) Record(m SentMessage) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (l *SentLog) List() ([]SentMessage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.read()
+}
+
+// Forget removes the given messages from the log.
+func (l *SentLog) Forget(msgs []SentMessage) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	drop := make(map[sentKey]bool, len(msgs))
+	for _, m := range msgs {
+		drop[m.key()] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	all, err := l.read()
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	for _, m := range all {
+		if drop[m.key()] {
+			continue
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+		b.WriteByte('\n')
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func (l *SentLog) read() ([]SentMessage, error) {
+	f, err := os.Open(l.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []SentMessage
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+		var m SentMessage
+		// A crash mid-append can leave a torn last line; skip it.
+		if err := json.Unmarshal(sc.Bytes(), &m); err != nil {
+			continue
+		}
+		out = append(out, m)
+	}
+	return out, sc.Err()
+}
+
+type sentKey struct {
+	bot       string
+	chatID    string
+	messageID int
+}
+
+func (m SentMessage) key() sentKey {
+	return sentKey{m.Bot, m.ChatID, m.MessageID}
+}
+
+// botID is the numeric bot ID that prefixes the token. It identifies the
+// sender in the sent log without storing the secret part.
+func (c *Client) botID() string {
+	id, _, _ := strings.Cut(c.token, ":")
+	return id
+}
+
+func (c *Client) recordSent(chatID string, messageID int, fileID string) {
+	if c.Sent == nil {
+		return
+	}
+	err := c.Sent.Record(SentMessage{
+		Bot:       c.botID(),
+		ChatID:    chatID,
+		MessageID: messageID,
+		FileID:    fileID,
+		SentAt:    time.Now(),
+	})
+	if err != nil {
+		log.Printf("failed to record sent message %d: %v", messageID, err)
+	}
+}
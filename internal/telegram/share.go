@@ -0,0 +1,104 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"tstore/internal/metadata"
+	"tstore/internal/share"
+	"tstore/pkg/model"
+)
+
+func (u *Uploader) ExportShare(ctx context.Context, name string) (*share.Manifest, error) {
+	rec, err := u.Store.Get(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %q: %w", name, err)
+	}
+	return share.FromRecord(rec)
+}
+
+// ImportShare downloads the chunks listed in m, using the bot of whichever
+// target holds each one, and checks the result against the manifest
+// checksum. The file is then uploaded again through our own bots so the
+// record does not depend on the sender's messages.
+func (u *Uploader) ImportShare(
+	ctx context.Context,
+	m *share.Manifest,
+	name string,
+	chatID string,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = m.Name
+	}
+	name, err := metadata.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := u.Store.Get(ctx, name); err == nil {
+		return nil, fmt.Errorf("import %q: %w", name, metadata.ErrAlreadyExists)
+	}
+
+	dstPath := filepath.Join(u.SyncFolder, filepath.FromSlash(name))
+	tmpPath := dstPath + ".tmp"
+	if err := os.MkdirAll(filepath.Dir(dstPath), 0o700); err != nil {
+		return nil, fmt.Errorf("create sync folder: %w", err)
+	}
+	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open temp file: %w", err)
+	}
+	defer out.Close()
+	defer os.Remove(tmpPath)
+
+	if onProgress == nil {
+		onProgress = func(float64) {}
+	}
+	// Downloading and uploading again each take half of the progress bar.
+	downloadProgress := func(p float64) { onProgress(p / 2) }
+	uploadProgress := func(p float64) { onProgress(50 + p/2) }
+
+	src := m.Record()
+	hasher := sha256.New()
+	var downloaded int64
+	for i, fileID := range m.ChunkIds {
+		data, err := u.fetchChunk(ctx, src, i)
+		if err != nil {
+			return nil, fmt.Errorf("download chunk %q: %w", fileID, err)
+		}
+
+		pr := &progressReader{
+			r:          bytes.NewReader(data),
+			total:      m.Size,
+			done:       &downloaded,
+			onProgress: downloadProgress,
+		}
+		if _, err := io.Copy(io.MultiWriter(out, hasher), pr); err != nil {
+			return nil, fmt.Errorf("assemble chunk %q: %w", fileID, err)
+		}
+	}
+	if err := out.Close(); err != nil {
+		return nil, fmt.Errorf("close temp file: %w", err)
+	}
+
+	if downloaded != m.Size {
+		return nil, fmt.Errorf("share size mismatch: got %d bytes, want %d", downloaded, m.Size)
+	}
+	if sum := hex.EncodeToString(hasher.Sum(nil)); sum != m.Checksum {
+		return nil, fmt.Errorf("share checksum mismatch: got %s, want %s", sum, m.Checksum)
+	}
+
+	rec, err := u.UploadFileAs(ctx, tmpPath, name, chatID, uploadProgress)
+	if err != nil {
+		return nil, fmt.Errorf("upload imported file: %w", err)
+	}
+	return rec, nil
+}
@@ -0,0 +1,165 @@
+package telegram
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"tstore/internal/metadata"
+	"tstore/internal/share"
+	"tstore/pkg/model"
+)
+
+func TestUploader_ImportShare(t *testing.T) {
+	chunkData := map[string]string{"c0": "hello ", "c1": "world"}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/getFile":
+			fmt.Fprintf(w, `{"ok":true,"result":{"file_path":%q}}`, r.URL.Query().Get("file_id"))
+		case strings.HasPrefix(r.URL.Path, "/file/"):
+			io.WriteString(w, chunkData[strings.TrimPrefix(r.URL.Path, "/file/")])
+		case r.URL.Path == "/sendDocument":
+			fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"document":{"file_id":"meta"}}}`)
+		case r.URL.Path == "/pinChatMessage":
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+		default:
+			t.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	client := &Client{baseURL: srv.URL, fileURL: srv.URL + "/file", client: srv.Client()}
+	syncDir := filepath.Join(tmp, "sync")
+	u := NewUploader(client, store, syncDir, 6)
+
+	sum := sha256.Sum256([]byte("hello world"))
+	m := &share.Manifest{
+		Version:  share.Version,
+		Name:     "greeting.txt",
+		Size:     11,
+		Checksum: hex.EncodeToString(sum[:]),
+		ChunkIds: []string{"c0", "c1"},
+	}
+
+	ctx := context.Background()
+	rec, err := u.ImportShare(ctx, m, "shared/greeting.txt", "1", nil)
+	if err != nil {
+		t.Fatalf("ImportShare: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(syncDir, "shared", "greeting.txt"))
+	if err != nil || string(data) != "hello world" {
+		t.Errorf("imported file = %q, %v; want %q", data, err, "hello world")
+	}
+	if got, err := store.Get(ctx, "shared/greeting.txt"); err != nil || got.Checksum != rec.Checksum {
+		t.Errorf("stored record = %+v, %v", got, err)
+	}
+
+	if _, err := u.ImportShare(ctx, m, "shared/greeting.txt", "1", nil); err == nil {
+		t.Error("expected an error importing over an existing record")
+	}
+
+	m.Checksum = strings.Repeat("0", 64)
+	if _, err := u.ImportShare(ctx, m, "", "1", nil); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("tampered checksum: err = %v; want checksum mismatch", err)
+	}
+	if _, err := store.Get(ctx, "greeting.txt"); err == nil {
+		t.Error("record created for a share that failed verification")
+	}
+	if _, err := os.Stat(filepath.Join(syncDir, "greeting.txt")); !os.IsNotExist(err) {
+		t.Errorf("unverified file left in sync folder: %v", err)
+	}
+}
+
+func TestUploader_ImportShareAcrossTargets(t *testing.T) {
+	primary, primaryChunks := chunkServer(t, false)
+	backup, _ := chunkServer(t, false)
+	ctx := context.Background()
+
+	// The sender's file has its first chunk on the primary chat and its
+	// second on the "backup" target.
+	first, err := testClient(primary).SendChunk(ctx, "1", strings.NewReader("hello "), 0)
+	if err != nil {
+		t.Fatalf("SendChunk: %v", err)
+	}
+	second, err := testClient(backup).SendChunk(ctx, "20", strings.NewReader("world"), 1)
+	if err != nil {
+		t.Fatalf("SendChunk: %v", err)
+	}
+	sum := sha256.Sum256([]byte("hello world"))
+	m, err := share.FromRecord(&model.FileRecord{
+		Name:         "greeting.txt",
+		Size:         11,
+		Checksum:     hex.EncodeToString(sum[:]),
+		ChunkSize:    6,
+		ChunkIds:     []string{first, second},
+		ChunkTargets: []string{"", "backup"},
+	})
+	if err != nil {
+		t.Fatalf("FromRecord: %v", err)
+	}
+	token, err := m.Token()
+	if err != nil {
+		t.Fatalf("Token: %v", err)
+	}
+	if m, err = share.Parse(token); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	newUploader := func(targets ...*Target) (*Uploader, string) {
+		tmp := t.TempDir()
+		store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+		if err != nil {
+			t.Fatalf("NewJSONStore: %v", err)
+		}
+		syncDir := filepath.Join(tmp, "sync")
+		u := NewUploader(testClient(primary), store, syncDir, 6)
+		u.Targets = targets
+		return u, syncDir
+	}
+
+	u, _ := newUploader()
+	if _, err := u.ImportShare(ctx, m, "", "1", nil); err == nil || !strings.Contains(err.Error(), `"backup"`) {
+		t.Errorf("import without the backup target: err = %v; want an unknown target error", err)
+	}
+
+	u, syncDir := newUploader(&Target{Name: "backup", Client: testClient(backup), ChatID: "20"})
+	rec, err := u.ImportShare(ctx, m, "", "1", nil)
+	if err != nil {
+		t.Fatalf("ImportShare: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(syncDir, "greeting.txt"))
+	if err != nil || string(data) != "hello world" {
+		t.Errorf("imported file = %q, %v; want %q", data, err, "hello world")
+	}
+
+	// The imported record points at fresh uploads on our own chat, not at
+	// the sender's messages.
+	for i, id := range rec.ChunkIds {
+		if id == first || id == second {
+			t.Errorf("chunk %d still points at the sender's message %q", i, id)
+		}
+		if rec.ChunkTarget(i) != "" {
+			t.Errorf("chunk %d target = %q; want the primary chat", i, rec.ChunkTarget(i))
+		}
+	}
+	var got strings.Builder
+	for _, id := range rec.ChunkIds {
+		got.WriteString(primaryChunks[id])
+	}
+	if got.String() != "hello world" {
+		t.Errorf("re-uploaded chunks = %q; want %q", got.String(), "hello world")
+	}
+}
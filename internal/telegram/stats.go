@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+	"tstore/pkg/model"
+)
+
+// StorageStats aggregates the metadata store. Files and Bytes count live
+// files; trashed ones only show up in States, StateBytes and the stored
+// totals, as they still take up space.
+type StorageStats struct {
+	Files      int                       `json:"files"`
+	Bytes      int64                     `json:"bytes"`
+	States     map[model.FileState]int   `json:"states"`
+	StateBytes map[model.FileState]int64 `json:"state_bytes"`
+	// Chunks and StoredBytes count every stored document: data chunks,
+	// their replicas, parity and previews.
+	Chunks      int   `json:"chunks"`
+	StoredBytes int64 `json:"stored_bytes"`
+	// DuplicateBytes is content stored more than once under different
+	// names, which deduplication would save. Chunks are not compressed, so
+	// there are no compression savings to report.
+	DuplicateBytes int64               `json:"duplicate_bytes"`
+	Largest        []*model.FileRecord `json:"largest"`
+	Growth         []GrowthPoint       `json:"growth"`
+}
+
+// GrowthPoint is what was uploaded on one day by UploadedAt, and the live
+// total up to and including it.
+type GrowthPoint struct {
+	Day        string `json:"day"`
+	Files      int    `json:"files"`
+	Bytes      int64  `json:"bytes"`
+	TotalBytes int64  `json:"total_bytes"`
+}
+
+// StorageStats computes StorageStats, listing the largest live files up to
+// largest.
+func (u *Uploader) StorageStats(ctx context.Context, largest int) (*StorageStats, error) {
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list metadata: %w", err)
+	}
+	trashed, err := u.Store.ListTrash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+
+	stats := &StorageStats{
+		Files:      len(recs),
+		States:     make(map[model.FileState]int),
+		StateBytes: make(map[model.FileState]int64),
+		Largest:    []*model.FileRecord{},
+		Growth:     []GrowthPoint{},
+	}
+	seen := make(map[string]bool)
+	for _, rec := range append(recs, trashed...) {
+		stats.States[rec.State]++
+		stats.StateBytes[rec.State] += rec.Size
+		chunks, bytes := u.storedSize(rec)
+		stats.Chunks += chunks
+		stats.StoredBytes += bytes
+		if rec.Checksum != "" {
+			if seen[rec.Checksum] {
+				stats.DuplicateBytes += rec.Size
+			}
+			seen[rec.Checksum] = true
+		}
+	}
+
+	growth := make(map[string]*GrowthPoint)
+	for _, rec := range recs {
+		stats.Bytes += rec.Size
+		day := rec.UploadedAt.Local().Format(time.DateOnly)
+		if growth[day] == nil {
+			growth[day] = &GrowthPoint{Day: day}
+		}
+		growth[day].Files++
+		growth[day].Bytes += rec.Size
+	}
+	for _, p := range growth {
+		stats.Growth = append(stats.Growth, *p)
+	}
+	sort.Slice(stats.Growth, func(i, j int) bool { return stats.Growth[i].Day < stats.Growth[j].Day })
+	var total int64
+	for i := range stats.Growth {
+		total += stats.Growth[i].Bytes
+		stats.Growth[i].TotalBytes = total
+	}
+
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Size != recs[j].Size {
+			return recs[i].Size > recs[j].Size
+		}
+		return recs[i].Name < recs[j].Name
+	})
+	stats.Largest = append(stats.Largest, recs[:min(largest, len(recs))]...)
+	return stats, nil
+}
+
+// storedSize counts the documents stored for rec and their bytes.
+func (u *Uploader) storedSize(rec *model.FileRecord) (chunks int, bytes int64) {
+	for i := range rec.ChunkIds {
+		copies := len(rec.ChunkLocations(i))
+		chunks += copies
+		bytes += int64(copies) * u.chunkLen(rec, i)
+	}
+	for i := range rec.ParityChunks {
+		chunks++
+		bytes += u.chunkLen(rec, i/max(rec.ParityShards, 1)*rec.DataShards)
+	}
+	if rec.Preview != nil {
+		chunks++
+		bytes += rec.Preview.Size
+	}
+	return chunks, bytes
+}
+
+// recordTransfer adds n bytes to today's totals, if transfers are logged.
+func (u *Uploader) recordTransfer(uploaded bool, n int64) {
+	if u.Transfers == nil || n == 0 {
+		return
+	}
+	var err error
+	if uploaded {
+		err = u.Transfers.AddUploaded(n)
+	} else {
+		err = u.Transfers.AddDownloaded(n)
+	}
+	if err != nil {
+		log.Printf("record transfer: %v", err)
+	}
+}
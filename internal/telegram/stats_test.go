@@ -0,0 +1,81 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+func TestUploader_StorageStats(t *testing.T) {
+	srv, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	transfers, err := NewTransferLog(filepath.Join(tmp, "transfers.json"))
+	if err != nil {
+		t.Fatalf("NewTransferLog: %v", err)
+	}
+
+	u := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 4)
+	u.Transfers = transfers
+	ctx := context.Background()
+	for name, content := range map[string]string{
+		"big.bin":  "0123456789",
+		"copy.bin": "0123456789",
+		"gone.bin": "abc",
+	} {
+		src := filepath.Join(tmp, name)
+		if err := os.WriteFile(src, []byte(content), 0o600); err != nil {
+			t.Fatalf("write source: %v", err)
+		}
+		if _, err := u.UploadFileAs(ctx, src, name, "1", nil); err != nil {
+			t.Fatalf("UploadFileAs(%s): %v", name, err)
+		}
+	}
+	if err := u.OffloadFile(ctx, "big.bin", "1"); err != nil {
+		t.Fatalf("OffloadFile: %v", err)
+	}
+	if err := u.DownloadFile(ctx, "big.bin", "1", func(float64) {}); err != nil {
+		t.Fatalf("DownloadFile: %v", err)
+	}
+	if err := u.DeleteFile(ctx, "gone.bin", "1"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+
+	stats, err := u.StorageStats(ctx, 1)
+	if err != nil {
+		t.Fatalf("StorageStats: %v", err)
+	}
+	if stats.Files != 2 || stats.Bytes != 20 || stats.States[model.StateTrash] != 1 {
+		t.Errorf("files = %d, bytes = %d, states = %v", stats.Files, stats.Bytes, stats.States)
+	}
+	if stats.Chunks != 7 || stats.StoredBytes != 23 || stats.DuplicateBytes != 10 {
+		t.Errorf("chunks = %d, stored = %d, duplicate = %d; want 7, 23, 10", stats.Chunks, stats.StoredBytes, stats.DuplicateBytes)
+	}
+	if len(stats.Largest) != 1 || stats.Largest[0].Name != "big.bin" {
+		t.Errorf("Largest = %v; want big.bin", stats.Largest)
+	}
+	today := time.Now().Format(time.DateOnly)
+	if len(stats.Growth) != 1 || stats.Growth[0].Day != today || stats.Growth[0].TotalBytes != 20 {
+		t.Errorf("Growth = %+v", stats.Growth)
+	}
+
+	reopened, err := NewTransferLog(filepath.Join(tmp, "transfers.json"))
+	if err != nil {
+		t.Fatalf("reopen transfer log: %v", err)
+	}
+	history := reopened.History(3)
+	if len(history) != 3 || history[2].Day != today || history[0].Uploaded != 0 {
+		t.Fatalf("History = %+v", history)
+	}
+	if history[2].Uploaded != 23 || history[2].Downloaded != 10 {
+		t.Errorf("today = %+v; want 23 bytes up, 10 down", history[2])
+	}
+}
@@ -0,0 +1,202 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"tstore/pkg/model"
+)
+
+const (
+	PlacementRoundRobin = "round-robin"
+	PlacementFillFirst  = "fill-first"
+	PlacementPerFolder  = "per-folder"
+)
+
+// Target is a bot and chat pair that chunks can be stored in. MaxBytes caps
+// what fill-first placement puts there; zero means unlimited.
+type Target struct {
+	Name     string
+	Client   *Client
+	ChatID   string
+	MaxBytes int64
+}
+
+// Placement decides where chunks go. Order returns every target worth
+// trying for a chunk of the named file, best first, so the uploader can
+// fail over when a target is rate-limited or gone.
+type Placement interface {
+	Order(name string, size int64) []*Target
+	Placed(t *Target, size int64)
+}
+
+// UsageFn reports the bytes already stored on each target, by name.
+type UsageFn func() map[string]int64
+
+func NewPlacement(kind string, targets []*Target, folderTargets map[string]string, usage UsageFn) (Placement, error) {
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no storage targets configured")
+	}
+	byName := make(map[string]*Target, len(targets))
+	for _, t := range targets {
+		byName[t.Name] = t
+	}
+
+	switch kind {
+	case "", PlacementRoundRobin:
+		return &roundRobin{targets: targets}, nil
+	case PlacementFillFirst:
+		return &fillFirst{targets: targets, usage: usage}, nil
+	case PlacementPerFolder:
+		folders := make(map[string]*Target, len(folderTargets))
+		for folder, name := range folderTargets {
+			t, ok := byName[name]
+			if !ok {
+				return nil, fmt.Errorf("folder %q uses unknown target %q", folder, name)
+			}
+			folders[strings.Trim(folder, "/")] = t
+		}
+		return &perFolder{folders: folders, fallback: &roundRobin{targets: targets}}, nil
+	default:
+		return nil, fmt.Errorf("unknown placement %q", kind)
+	}
+}
+
+type roundRobin struct {
+	mu      sync.Mutex
+	targets []*Target
+	next    int
+}
+
+func (p *roundRobin) Order(name string, size int64) []*Target {
+	p.mu.Lock()
+	start := p.next
+	p.next = (p.next + 1) % len(p.targets)
+	p.mu.Unlock()
+
+	out := make([]*Target, 0, len(p.targets))
+	for i := range p.targets {
+		out = append(out, p.targets[(start+i)%len(p.targets)])
+	}
+	return out
+}
+
+func (p *roundRobin) Placed(t *Target, size int64) {}
+
+// fillFirst keeps using the first target until it reaches MaxBytes. Usage is
+// loaded once and then tracked in memory, so space freed by deletes is only
+// seen after the uploader is rebuilt.
+type fillFirst struct {
+	mu      sync.Mutex
+	targets []*Target
+	usage   UsageFn
+	used    map[string]int64
+}
+
+func (p *fillFirst) Order(name string, size int64) []*Target {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.used == nil {
+		p.used = make(map[string]int64)
+		if p.usage != nil {
+			for k, v := range p.usage() {
+				p.used[k] = v
+			}
+		}
+	}
+
+	var out []*Target
+	for _, t := range p.targets {
+		if t.MaxBytes == 0 || p.used[t.Name]+size <= t.MaxBytes {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (p *fillFirst) Placed(t *Target, size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.used != nil {
+		p.used[t.Name] += size
+	}
+}
+
+// perFolder sends files below a configured folder to its target, falling
+// back to round-robin for everything else and when that target fails.
+type perFolder struct {
+	folders  map[string]*Target
+	fallback Placement
+}
+
+func (p *perFolder) Order(name string, size int64) []*Target {
+	var (
+		best    *Target
+		bestLen = -1
+	)
+	for folder, t := range p.folders {
+		if (folder == "" || name == folder || strings.HasPrefix(name, folder+"/")) && len(folder) > bestLen {
+			best, bestLen = t, len(folder)
+		}
+	}
+
+	rest := p.fallback.Order(name, size)
+	if best == nil {
+		return rest
+	}
+	out := []*Target{best}
+	for _, t := range rest {
+		if t != best {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (p *perFolder) Placed(t *Target, size int64) {
+	p.fallback.Placed(t, size)
+}
+
+// TargetUsage sums the chunk bytes each target holds across recs.
+func (u *Uploader) TargetUsage(recs []*model.FileRecord) map[string]int64 {
+	used := make(map[string]int64)
+	for _, rec := range recs {
+		for i := range rec.ChunkIds {
+			size := u.chunkLen(rec, i)
+			for _, loc := range rec.ChunkLocations(i) {
+				if loc.Target != "" {
+					used[loc.Target] += size
+				}
+			}
+		}
+		for i, loc := range rec.ParityChunks {
+			if loc.Target != "" {
+				used[loc.Target] += u.chunkLen(rec, i/max(rec.ParityShards, 1)*rec.DataShards)
+			}
+		}
+	}
+	return used
+}
+
+func (u *Uploader) target(name string) (*Target, error) {
+	for _, t := range u.Targets {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown storage target %q", name)
+}
+
+// targetClient returns the bot behind a chunk location; file IDs are only
+// valid for the bot that uploaded them. The empty name is the primary bot.
+func (u *Uploader) targetClient(name string) (*Client, error) {
+	if name == "" {
+		return u.Client, nil
+	}
+	t, err := u.target(name)
+	if err != nil {
+		return nil, err
+	}
+	return t.Client, nil
+}
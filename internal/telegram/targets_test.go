@@ -0,0 +1,206 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+func targetNames(ts []*Target) []string {
+	var names []string
+	for _, t := range ts {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func TestPlacement_Order(t *testing.T) {
+	a := &Target{Name: "a", MaxBytes: 10}
+	b := &Target{Name: "b", MaxBytes: 10}
+	c := &Target{Name: "c"}
+	targets := []*Target{a, b, c}
+
+	rr, err := NewPlacement(PlacementRoundRobin, targets, nil, nil)
+	if err != nil {
+		t.Fatalf("NewPlacement: %v", err)
+	}
+	if got := targetNames(rr.Order("x", 1)); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("round-robin first = %v", got)
+	}
+	if got := targetNames(rr.Order("x", 1)); !reflect.DeepEqual(got, []string{"b", "c", "a"}) {
+		t.Errorf("round-robin second = %v", got)
+	}
+
+	ff, err := NewPlacement(PlacementFillFirst, targets, nil, func() map[string]int64 {
+		return map[string]int64{"a": 8}
+	})
+	if err != nil {
+		t.Fatalf("NewPlacement: %v", err)
+	}
+	if got := targetNames(ff.Order("x", 2)); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("fill-first with room = %v", got)
+	}
+	ff.Placed(a, 2)
+	if got := targetNames(ff.Order("x", 1)); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("fill-first after a is full = %v", got)
+	}
+
+	pf, err := NewPlacement(PlacementPerFolder, targets, map[string]string{"photos": "c", "photos/raw": "b"}, nil)
+	if err != nil {
+		t.Fatalf("NewPlacement: %v", err)
+	}
+	if got := pf.Order("photoshop.psd", 1)[0].Name; got != "a" {
+		t.Errorf("per-folder unmatched = %q; want round-robin fallback a", got)
+	}
+	if got := pf.Order("photos/raw/img.cr2", 1)[0].Name; got != "b" {
+		t.Errorf("per-folder nested = %q; want b", got)
+	}
+	if got := pf.Order("photos/a.jpg", 1); got[0].Name != "c" || len(got) != 3 {
+		t.Errorf("per-folder = %v; want c first with fallbacks", targetNames(got))
+	}
+
+	if _, err := NewPlacement(PlacementPerFolder, targets, map[string]string{"x": "missing"}, nil); err == nil {
+		t.Error("expected an error for an unknown folder target")
+	}
+	if _, err := NewPlacement("random", targets, nil, nil); err == nil {
+		t.Error("expected an error for an unknown placement")
+	}
+}
+
+// chunkServer is a fake bot that stores chunks in memory. When failing is
+// set it answers every upload like a rate-limited bot.
+func chunkServer(t *testing.T, failing bool) (*httptest.Server, map[string]string) {
+	t.Helper()
+
+	var (
+		n      atomic.Int32
+		pinned string
+	)
+	stored := make(map[string]string)
+	messages := make(map[string]string)
+	captions := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/sendDocument" && failing:
+			w.WriteHeader(http.StatusTooManyRequests)
+			fmt.Fprint(w, `{"ok":false,"error_code":429,"description":"Too Many Requests"}`)
+		case r.URL.Path == "/sendDocument":
+			r.ParseMultipartForm(1 << 20)
+			part, _, err := r.FormFile("document")
+			if err != nil {
+				t.Errorf("FormFile: %v", err)
+				return
+			}
+			data, _ := io.ReadAll(part)
+			msgID := n.Add(1)
+			id := fmt.Sprintf("%s-%d", r.FormValue("chat_id"), msgID)
+			stored[id] = string(data)
+			messages[fmt.Sprint(msgID)] = id
+			captions[fmt.Sprint(msgID)] = r.FormValue("caption")
+			fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"document":{"file_id":%q}}}`, msgID, id)
+		case r.URL.Path == "/pinChatMessage" || r.URL.Path == "/deleteMessage":
+			var body struct {
+				MessageID int `json:"message_id"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if r.URL.Path == "/pinChatMessage" {
+				pinned = fmt.Sprint(body.MessageID)
+			} else {
+				delete(stored, messages[fmt.Sprint(body.MessageID)])
+			}
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+		case r.URL.Path == "/getChat":
+			if pinned == "" {
+				fmt.Fprint(w, `{"ok":true,"result":{}}`)
+				return
+			}
+			fmt.Fprintf(w, `{"ok":true,"result":{"pinned_message":{"message_id":%s,"caption":%q,"document":{"file_id":%q}}}}`,
+				pinned, captions[pinned], messages[pinned])
+		case r.URL.Path == "/getFile":
+			id := r.URL.Query().Get("file_id")
+			if _, ok := stored[id]; !ok {
+				fmt.Fprint(w, `{"ok":false,"error_code":400,"description":"Bad Request: invalid file_id"}`)
+				return
+			}
+			fmt.Fprintf(w, `{"ok":true,"result":{"file_path":%q}}`, id)
+		case strings.HasPrefix(r.URL.Path, "/file/"):
+			data, ok := stored[strings.TrimPrefix(r.URL.Path, "/file/")]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			io.WriteString(w, data)
+		default:
+			t.Errorf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, stored
+}
+
+func testClient(srv *httptest.Server) *Client {
+	return &Client{baseURL: srv.URL, fileURL: srv.URL + "/file", client: srv.Client()}
+}
+
+func TestUploader_FailsOverBetweenTargets(t *testing.T) {
+	primary, _ := chunkServer(t, false)
+	limited, _ := chunkServer(t, true)
+	healthy, healthyChunks := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	src := filepath.Join(tmp, "src.bin")
+	if err := os.WriteFile(src, []byte("abcdefghij"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(testClient(primary), store, filepath.Join(tmp, "sync"), 4)
+	u.Targets = []*Target{
+		{Name: "limited", Client: testClient(limited), ChatID: "10"},
+		{Name: "healthy", Client: testClient(healthy), ChatID: "20"},
+	}
+	u.Placement, err = NewPlacement(PlacementFillFirst, u.Targets, nil, nil)
+	if err != nil {
+		t.Fatalf("NewPlacement: %v", err)
+	}
+
+	ctx := context.Background()
+	rec, err := u.UploadFileAs(ctx, src, "data.bin", "1", nil)
+	if err != nil {
+		t.Fatalf("UploadFileAs: %v", err)
+	}
+	if want := []string{"healthy", "healthy", "healthy"}; !reflect.DeepEqual(rec.ChunkTargets, want) {
+		t.Errorf("ChunkTargets = %v; want %v", rec.ChunkTargets, want)
+	}
+	if len(healthyChunks) != 3 {
+		t.Errorf("healthy target holds %d chunks; want 3", len(healthyChunks))
+	}
+	if got := u.TargetUsage([]*model.FileRecord{rec}); got["healthy"] != 10 {
+		t.Errorf("TargetUsage = %v; want healthy=10", got)
+	}
+
+	data, err := u.ReadChunk(ctx, rec, 2)
+	if err != nil || string(data) != "ij" {
+		t.Errorf("ReadChunk = %q, %v; want %q", data, err, "ij")
+	}
+
+	u.Targets = u.Targets[:1]
+	if _, err := u.ReadChunk(ctx, rec, 0); err == nil {
+		t.Error("expected an error reading a chunk from a removed target")
+	}
+}
@@ -0,0 +1,119 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TransferRetentionDays is how long daily transfer totals are kept.
+const TransferRetentionDays = 400
+
+// DayTransfer is the volume moved to and from Telegram on one local day,
+// replicas and parity included.
+type DayTransfer struct {
+	Day        string `json:"day"`
+	Uploaded   int64  `json:"uploaded"`
+	Downloaded int64  `json:"downloaded"`
+}
+
+// TransferLog keeps per-day transfer totals in a small JSON file.
+type TransferLog struct {
+	mu   sync.Mutex
+	path string
+	days map[string]*DayTransfer
+}
+
+func NewTransferLog(path string) (*TransferLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create transfer log dir: %w", err)
+	}
+
+	l := &TransferLog{path: path, days: make(map[string]*DayTransfer)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return l, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var list []*DayTransfer
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parse transfer log: %w", err)
+	}
+	for _, d := range list {
+		l.days[d.Day] = d
+	}
+	return l, nil
+}
+
+func (l *TransferLog) AddUploaded(n int64) error {
+	return l.add(time.Now(), n, 0)
+}
+
+func (l *TransferLog) AddDownloaded(n int64) error {
+	return l.add(time.Now(), 0, n)
+}
+
+func (l *TransferLog) add(at time.Time, up, down int64) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	day := at.Format(time.DateOnly)
+	d := l.days[day]
+	if d == nil {
+		d = &DayTransfer{Day: day}
+		l.days[day] = d
+	}
+	d.Uploaded += up
+	d.Downloaded += down
+
+	cutoff := at.AddDate(0, 0, -TransferRetentionDays).Format(time.DateOnly)
+	for day := range l.days {
+		if day < cutoff {
+			delete(l.days, day)
+		}
+	}
+	return l.save()
+}
+
+func (l *TransferLog) save() error {
+	list := make([]*DayTransfer, 0, len(l.days))
+	for _, d := range l.days {
+		list = append(list, d)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Day < list[j].Day })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// History returns the totals of the last days days, oldest first, with
+// idle days included as zeros.
+func (l *TransferLog) History(days int) []DayTransfer {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	out := make([]DayTransfer, 0, days)
+	for i := days - 1; i >= 0; i-- {
+		day := now.AddDate(0, 0, -i).Format(time.DateOnly)
+		if d := l.days[day]; d != nil {
+			out = append(out, *d)
+		} else {
+			out = append(out, DayTransfer{Day: day})
+		}
+	}
+	return out
+}
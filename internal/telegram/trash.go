@@ -0,0 +1,139 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+	"tstore/pkg/model"
+)
+
+type PurgeReport struct {
+	Purged  []string `json:"purged"`
+	Deleted int      `json:"deleted"`
+	Failed  int      `json:"failed"`
+}
+
+// RestoreFromTrash brings the most recently trashed file called name back
+// as a cloud file.
+func (u *Uploader) RestoreFromTrash(ctx context.Context, name string, chatID string) error {
+	trashed, err := u.Store.ListTrash(ctx)
+	if err != nil {
+		return fmt.Errorf("list trash: %w", err)
+	}
+	var rec *model.FileRecord
+	for _, r := range trashed {
+		if r.Name == name && r.TrashedAt != nil && (rec == nil || !r.TrashedAt.Before(*rec.TrashedAt)) {
+			rec = r
+		}
+	}
+	if rec == nil {
+		return fmt.Errorf("restore %q: %w", name, model.ErrNotFound)
+	}
+
+	if err := u.Store.Restore(ctx, name); err != nil {
+		return fmt.Errorf("restore %q: %w", name, err)
+	}
+
+	if err := u.BackupMetadata(ctx, chatID); err != nil {
+		if err2 := u.Store.Trash(ctx, name, *rec.TrashedAt); err2 != nil {
+			return fmt.Errorf("backup failed: %v; rollback failed: %w", err, err2)
+		}
+		return fmt.Errorf("backup metadata failed: %w", err)
+	}
+
+	return nil
+}
+
+// RunTrashPurge calls PurgeTrash every interval until ctx is cancelled.
+func (u *Uploader) RunTrashPurge(ctx context.Context, chatID string, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := u.PurgeTrash(ctx, chatID, retention)
+		if err != nil {
+			log.Printf("trash purge: %v", err)
+		} else if len(report.Purged) > 0 {
+			log.Printf("trash purge: purged %d files, deleted %d messages, %d failed", len(report.Purged), report.Deleted, report.Failed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeTrash forgets files trashed more than retention ago and deletes their
+// chunk messages. The records are dropped and backed up first, so a failure
+// part way never leaves a record pointing at deleted chunks. Chunks sent
+// before the sent message log existed cannot be found and are left behind.
+func (u *Uploader) PurgeTrash(ctx context.Context, chatID string, retention time.Duration) (*PurgeReport, error) {
+	trashed, err := u.Store.ListTrash(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+
+	report := &PurgeReport{}
+	cutoff := time.Now().Add(-retention)
+	purged := make(map[string]bool)
+	for _, rec := range trashed {
+		if rec.TrashedAt != nil && rec.TrashedAt.After(cutoff) {
+			continue
+		}
+		var at time.Time
+		if rec.TrashedAt != nil {
+			at = *rec.TrashedAt
+		}
+		if err := u.Store.Purge(ctx, rec.Name, at); err != nil {
+			return report, fmt.Errorf("purge %q: %w", rec.Name, err)
+		}
+		report.Purged = append(report.Purged, rec.Name)
+		for _, id := range storedFileIDs(rec) {
+			purged[id] = true
+		}
+	}
+	if len(report.Purged) == 0 {
+		return report, nil
+	}
+	if err := u.BackupMetadata(ctx, chatID); err != nil {
+		return report, fmt.Errorf("backup metadata: %w", err)
+	}
+
+	if u.Client.Sent == nil {
+		return report, nil
+	}
+	// Never delete a chunk that some other record still points at.
+	live, err := u.Store.List(ctx)
+	if err != nil {
+		return report, fmt.Errorf("list metadata: %w", err)
+	}
+	rest, err := u.Store.ListTrash(ctx)
+	if err != nil {
+		return report, fmt.Errorf("list trash: %w", err)
+	}
+	for _, rec := range append(live, rest...) {
+		for _, id := range storedFileIDs(rec) {
+			delete(purged, id)
+		}
+	}
+
+	sent, err := u.Client.Sent.List()
+	if err != nil {
+		return report, fmt.Errorf("read sent message log: %w", err)
+	}
+	// Only messages in the storage chats hold chunks. Copies sent to other
+	// chats, such as the bot's replies, can share a chunk's file ID.
+	storage := u.storageClients(chatID)
+	var msgs []SentMessage
+	for _, m := range sent {
+		if storage[m.ChatID] != nil && m.FileID != "" && purged[m.FileID] {
+			msgs = append(msgs, m)
+		}
+	}
+	report.Failed, err = u.deleteSent(ctx, msgs, nil)
+	report.Deleted = len(msgs) - report.Failed
+	return report, err
+}
@@ -0,0 +1,98 @@
+package telegram
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+	"tstore/internal/metadata"
+	"tstore/pkg/model"
+)
+
+func TestUploader_TrashRestoreAndPurge(t *testing.T) {
+	srv, stored := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	sent, err := NewSentLog(filepath.Join(tmp, "sent.jsonl"))
+	if err != nil {
+		t.Fatalf("NewSentLog: %v", err)
+	}
+	client := testClient(srv)
+	client.Sent = sent
+
+	syncDir := filepath.Join(tmp, "sync")
+	if err := os.MkdirAll(syncDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	local := filepath.Join(syncDir, "data.bin")
+	if err := os.WriteFile(local, []byte("abcdefgh"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+
+	u := NewUploader(client, store, syncDir, 4)
+	ctx := context.Background()
+	rec, err := u.UploadFile(ctx, local, "1", nil)
+	if err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+
+	if err := u.DeleteFile(ctx, "data.bin", "1"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	if _, err := os.Stat(local); !os.IsNotExist(err) {
+		t.Errorf("local copy still present: %v", err)
+	}
+	if _, err := store.Get(ctx, "data.bin"); err != metadata.ErrNotFound {
+		t.Errorf("Get after delete: %v; want ErrNotFound", err)
+	}
+
+	if err := u.RestoreFromTrash(ctx, "data.bin", "1"); err != nil {
+		t.Fatalf("RestoreFromTrash: %v", err)
+	}
+	got, err := store.Get(ctx, "data.bin")
+	if err != nil || got.State != model.StateCloud {
+		t.Fatalf("Get after restore = %+v, %v; want a cloud record", got, err)
+	}
+	if err := u.RestoreFromTrash(ctx, "data.bin", "1"); err == nil {
+		t.Error("expected an error restoring a file that is not in the trash")
+	}
+
+	if err := u.DeleteFile(ctx, "data.bin", "1"); err != nil {
+		t.Fatalf("DeleteFile: %v", err)
+	}
+	// A copy of a chunk the bot sent to a user, as older versions logged it.
+	copied := SentMessage{ChatID: "99", MessageID: 500, FileID: rec.ChunkIds[0], SentAt: time.Now()}
+	if err := sent.Record(copied); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+	report, err := u.PurgeTrash(ctx, "1", time.Hour)
+	if err != nil || len(report.Purged) != 0 {
+		t.Fatalf("PurgeTrash within retention = %+v, %v; want nothing purged", report, err)
+	}
+
+	report, err = u.PurgeTrash(ctx, "1", 0)
+	if err != nil {
+		t.Fatalf("PurgeTrash: %v", err)
+	}
+	if len(report.Purged) != 1 || report.Deleted != 2 || report.Failed != 0 {
+		t.Errorf("report = %+v; want data.bin purged with 2 messages deleted", report)
+	}
+	for _, id := range rec.ChunkIds {
+		if _, ok := stored[id]; ok {
+			t.Errorf("chunk %s survived the purge", id)
+		}
+	}
+	msgs, _ := sent.List()
+	if !slices.ContainsFunc(msgs, func(m SentMessage) bool { return m.key() == copied.key() }) {
+		t.Errorf("the copy sent to chat 99 was deleted with the chunk")
+	}
+	if trash, _ := store.ListTrash(ctx); len(trash) != 0 {
+		t.Errorf("trash = %+v; want empty", trash)
+	}
+}
@@ -5,11 +5,15 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"time"
+	"tstore/internal/cache"
+	"tstore/internal/index"
 	"tstore/internal/ingestion"
 	"tstore/internal/metadata"
 	"tstore/internal/sync"
@@ -18,6 +22,8 @@ import (
 
 type ProgressFn func(percent float64)
 
+var ErrChecksumMismatch = errors.New("chunk checksum mismatch")
+
 type progressReader struct {
 	r          io.Reader
 	total      int64
@@ -35,10 +41,23 @@ func (pr *progressReader) Read(p []byte) (n int, err error) {
 }
 
 type Uploader struct {
-	Client     *Client
-	Store      metadata.Store
-	SyncFolder string
-	ChunkSize  int64
+	Client      *Client
+	Store       metadata.Store
+	SyncFolder  string
+	ChunkSize   int64
+	Cache       *cache.ChunkCache
+	Targets     []*Target
+	Placement   Placement
+	Replication int
+	Erasure     *ingestion.Erasure
+	// Index, when set, is fed the text of stored files and sent along with
+	// every metadata backup.
+	Index       *index.ContentIndex
+	indexBackup indexBackup
+	// Previews turns on thumbnails and text snippets for uploaded files.
+	Previews bool
+	// Transfers, when set, is told about every chunk sent or fetched.
+	Transfers *TransferLog
 }
 
 func NewUploader(client *Client, store metadata.Store, syncFolder string, chunkSize int64) *Uploader {
@@ -51,7 +70,16 @@ func NewUploader(client *Client, store metadata.Store, syncFolder string, chunkS
 }
 
 func (u *Uploader) BackupMetadata(ctx context.Context, chatID string) error {
-	msgID, _, err := u.Client.SendFile(ctx, chatID, u.Store.Path(), "metadata backup")
+	caption := "metadata backup"
+	if u.Index != nil {
+		fileID, err := u.backupContentIndex(ctx, chatID)
+		if err != nil {
+			return err
+		}
+		caption += "\n" + indexCaptionPrefix + fileID
+	}
+
+	msgID, _, err := u.Client.SendFile(ctx, chatID, u.Store.Path(), caption)
 	if err != nil {
 		return fmt.Errorf("sending metadata backup: %w", err)
 	}
@@ -67,29 +95,172 @@ func (u *Uploader) UploadFile(
 	filePath string,
 	chatID string,
 	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	return u.UploadFileAs(ctx, filePath, filepath.Base(filePath), chatID, onProgress)
+}
+
+// UploadFileAs uploads filePath and stores it under name, a slash-separated
+// path relative to the sync folder.
+func (u *Uploader) UploadFileAs(
+	ctx context.Context,
+	filePath string,
+	name string,
+	chatID string,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	return u.upload(ctx, filePath, name, chatID, PutOptions{}, onProgress)
+}
+
+// PutOptions adjust how PutFileWith stores a file.
+type PutOptions struct {
+	// ETag is recorded on the new record, before metadata is backed up.
+	ETag string
+	// Offload records the file as offloaded and leaves filePath where it
+	// is instead of moving it into the sync folder.
+	Offload bool
+}
+
+func (u *Uploader) upload(
+	ctx context.Context,
+	filePath string,
+	name string,
+	chatID string,
+	opts PutOptions,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	rec, err := u.create(ctx, filePath, name, chatID, opts, onProgress)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.BackupMetadata(ctx, chatID); err != nil {
+		return nil, fmt.Errorf("backup metadata: %w", err)
+	}
+
+	return rec, nil
+}
+
+// create is upload without the metadata backup.
+func (u *Uploader) create(
+	ctx context.Context,
+	filePath string,
+	name string,
+	chatID string,
+	opts PutOptions,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	rec, err := u.sendFile(ctx, filePath, name, chatID, opts, onProgress)
+	if err != nil {
+		return nil, err
+	}
+	if err := u.add(ctx, rec, filePath); err != nil {
+		return nil, err
+	}
+	return rec, nil
+}
+
+// add saves rec, a new record, and moves its content at filePath into the
+// sync folder when it is local. filePath is "" for content that was never
+// in a file.
+func (u *Uploader) add(ctx context.Context, rec *model.FileRecord, filePath string) error {
+	// The record goes in before the file lands in the sync folder, so the
+	// watcher, which skips names that have a record, leaves it alone.
+	if err := u.Store.Create(ctx, rec); err != nil {
+		return fmt.Errorf("save metadata: %w", err)
+	}
+
+	localPath := filePath
+	if rec.State == model.StateLocal {
+		localPath = filepath.Join(u.SyncFolder, filepath.FromSlash(rec.Name))
+		if err := sync.MoveFile(filePath, localPath); err != nil {
+			if err2 := u.Store.Delete(ctx, rec.Name); err2 != nil {
+				return fmt.Errorf("move file to sync folder: %v; rollback failed: %w", err, err2)
+			}
+			return fmt.Errorf("move file to sync folder: %w", err)
+		}
+	}
+
+	if localPath != "" {
+		u.indexContent(rec, localPath)
+	}
+
+	return nil
+}
+
+// sendFile stores the chunks and preview of filePath and returns the record
+// describing them, without saving it or moving the file.
+func (u *Uploader) sendFile(
+	ctx context.Context,
+	filePath string,
+	name string,
+	chatID string,
+	opts PutOptions,
+	onProgress ProgressFn,
 ) (*model.FileRecord, error) {
 	f, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("open file %q: %w", filePath, err)
 	}
+	defer f.Close()
 
 	info, err := f.Stat()
 	if err != nil {
 		return nil, fmt.Errorf("stat file %q: %w", filePath, err)
 	}
-	fileSize := info.Size()
-	fileName := info.Name()
 
-	hasher := sha256.New()
-	if _, err := f.Seek(0, io.SeekStart); err != nil {
-		return nil, fmt.Errorf("seek for chunking: %w", err)
+	rec, err := u.sendContent(ctx, f, info.Size(), name, chatID, opts, onProgress)
+	if err != nil {
+		return nil, err
+	}
+	f.Close()
+
+	// Send the preview before the caller moves the file: once it is in the
+	// sync folder the watcher would take it for an untracked one.
+	if u.Previews {
+		rec.Preview = u.sendPreview(ctx, filePath, rec.Name, chatID)
+	}
+	return rec, nil
+}
+
+// sendContent stores the chunks of the size bytes read from r and returns
+// the record describing them, without saving it.
+func (u *Uploader) sendContent(
+	ctx context.Context,
+	r io.Reader,
+	fileSize int64,
+	name string,
+	chatID string,
+	opts PutOptions,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	fileName, err := metadata.CleanName(name)
+	if err != nil {
+		return nil, err
 	}
 
-	chunksCh, errCh := ingestion.StreamChunks(f, u.ChunkSize)
+	hasher := sha256.New()
+	chunksCh, errCh := ingestion.StreamChunks(r, u.ChunkSize)
 	var (
-		chunkIDs []string
-		uploaded int64
+		chunkIDs     []string
+		chunkTargets []string
+		replicas     [][]model.ChunkLocation
+		replicated   bool
+		chunkSums    []string
+		stripe       [][]byte
+		parity       []model.ChunkLocation
+		paritySums   []string
+		uploaded     int64
 	)
+	sendParity := func() error {
+		locs, sums, err := u.sendParity(ctx, fileName, chatID, stripe, len(parity))
+		if err != nil {
+			return err
+		}
+		parity = append(parity, locs...)
+		paritySums = append(paritySums, sums...)
+		stripe = stripe[:0]
+		return nil
+	}
 
 	for chunk := range chunksCh {
 		select {
@@ -99,12 +270,28 @@ func (u *Uploader) UploadFile(
 		}
 
 		hasher.Write(chunk.Data)
-		reader := bytes.NewReader(chunk.Data)
-		fileID, err := u.Client.SendChunk(ctx, chatID, reader, chunk.Index)
+		chunkSums = append(chunkSums, sha256Hex(chunk.Data))
+		locs, err := u.sendChunk(ctx, fileName, chatID, chunk.Data, chunk.Index, u.copies(), nil)
 		if err != nil {
 			return nil, fmt.Errorf("send chunk %d: %w", chunk.Index, err)
 		}
-		chunkIDs = append(chunkIDs, fileID)
+		chunkIDs = append(chunkIDs, locs[0].FileID)
+		if u.Placement != nil {
+			chunkTargets = append(chunkTargets, locs[0].Target)
+		}
+		replicas = append(replicas, locs[1:])
+		if len(locs) > 1 {
+			replicated = true
+		}
+
+		if u.Erasure != nil {
+			stripe = append(stripe, chunk.Data)
+			if len(stripe) == u.Erasure.DataShards {
+				if err := sendParity(); err != nil {
+					return nil, err
+				}
+			}
+		}
 
 		uploaded += int64(len(chunk.Data))
 		if onProgress != nil {
@@ -115,57 +302,328 @@ func (u *Uploader) UploadFile(
 	if err := <-errCh; err != nil {
 		return nil, fmt.Errorf("chunking error: %w", err)
 	}
+	if uploaded != fileSize {
+		return nil, fmt.Errorf("read %d bytes of %q; want %d", uploaded, fileName, fileSize)
+	}
+	if len(stripe) > 0 {
+		if err := sendParity(); err != nil {
+			return nil, err
+		}
+	}
 
-	f.Close()
-	dstPath := filepath.Join(u.SyncFolder, fileName)
-	if err := sync.MoveFile(filePath, dstPath); err != nil {
-		return nil, fmt.Errorf("move file to sync folder: %w", err)
+	state := model.StateLocal
+	if opts.Offload {
+		state = model.StateCloud
 	}
 
 	checksum := hex.EncodeToString(hasher.Sum(nil))
 	rec := &model.FileRecord{
-		Name:        fileName,
-		State:       model.StateLocal,
-		Description: "",
-		Size:        fileSize,
-		Checksum:    checksum,
-		UploadedAt:  time.Now(),
-		ChunkIds:    chunkIDs,
+		Name:         fileName,
+		State:        state,
+		Description:  "",
+		Size:         fileSize,
+		Checksum:     checksum,
+		UploadedAt:   time.Now(),
+		ChunkIds:     chunkIDs,
+		ChunkSize:    u.ChunkSize,
+		ChunkTargets: chunkTargets,
 	}
-	if err := u.Store.Create(ctx, rec); err != nil {
-		return nil, fmt.Errorf("save metadata: %w", err)
+	rec.ChunkChecksums = chunkSums
+	if replicated {
+		rec.Replicas = replicas
+	}
+	if u.Erasure != nil {
+		rec.DataShards = u.Erasure.DataShards
+		rec.ParityShards = u.Erasure.ParityShards
+		rec.ParityChunks = parity
+		rec.ParityChecksums = paritySums
 	}
+	rec.ETag = opts.ETag
+	return rec, nil
+}
 
-	if err := u.BackupMetadata(ctx, chatID); err != nil {
-		return nil, fmt.Errorf("backup metadata: %w", err)
+// sendChunk stores data on the primary chat, or on as many targets as the
+// replication factor asks for, trying the placement's candidates in order.
+// Targets in exclude already hold a copy. It only fails when no copy could
+// be stored; missing replicas are left for Repair.
+func (u *Uploader) sendChunk(
+	ctx context.Context,
+	name string,
+	chatID string,
+	data []byte,
+	index int,
+	copies int,
+	exclude map[string]bool,
+) ([]model.ChunkLocation, error) {
+	if u.Placement == nil {
+		fileID, err := u.Client.SendChunk(ctx, chatID, bytes.NewReader(data), index)
+		if err != nil {
+			return nil, err
+		}
+		u.recordTransfer(true, int64(len(data)))
+		return []model.ChunkLocation{{FileID: fileID}}, nil
 	}
 
-	return rec, nil
+	size := int64(len(data))
+	var (
+		locs []model.ChunkLocation
+		errs []error
+	)
+	for _, t := range u.Placement.Order(name, size) {
+		if len(locs) == copies {
+			break
+		}
+		if exclude[t.Name] {
+			continue
+		}
+
+		fileID, err := t.Client.SendChunk(ctx, t.ChatID, bytes.NewReader(data), index)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			errs = append(errs, fmt.Errorf("target %q: %w", t.Name, err))
+			continue
+		}
+		u.Placement.Placed(t, size)
+		u.recordTransfer(true, size)
+		locs = append(locs, model.ChunkLocation{Target: t.Name, FileID: fileID})
+	}
+
+	if len(locs) == 0 {
+		if len(errs) == 0 {
+			return nil, errors.New("no storage target has room for the chunk")
+		}
+		return nil, errors.Join(errs...)
+	}
+	if len(locs) < copies {
+		log.Printf("chunk %d of %q stored %d of %d copies: %v", index, name, len(locs), copies, errors.Join(errs...))
+	}
+	return locs, nil
+}
+
+func (u *Uploader) copies() int {
+	return max(u.Replication, 1)
+}
+
+// sendParity computes and stores the parity chunks of one stripe. Parity
+// chunks are stored once; they already are the redundancy.
+func (u *Uploader) sendParity(ctx context.Context, name, chatID string, stripe [][]byte, first int) ([]model.ChunkLocation, []string, error) {
+	shards, err := u.Erasure.Encode(stripe)
+	if err != nil {
+		return nil, nil, fmt.Errorf("encode parity: %w", err)
+	}
+
+	var (
+		locs []model.ChunkLocation
+		sums []string
+	)
+	for i, shard := range shards {
+		placed, err := u.sendChunk(ctx, name, chatID, shard, first+i, 1, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("send parity chunk %d: %w", first+i, err)
+		}
+		locs = append(locs, placed[0])
+		sums = append(sums, sha256Hex(shard))
+	}
+	return locs, sums, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// chunkLen is the size of chunk index; only the last chunk is short.
+func (u *Uploader) chunkLen(rec *model.FileRecord, index int) int64 {
+	chunkSize := u.ChunkSizeOf(rec)
+	return min(chunkSize, rec.Size-int64(index)*chunkSize)
+}
+
+// readLocation downloads one stored copy and checks it against checksum
+// when one was recorded.
+func (u *Uploader) readLocation(ctx context.Context, loc model.ChunkLocation, checksum string) ([]byte, error) {
+	client, err := u.targetClient(loc.Target)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := client.DownloadFile(ctx, loc.FileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	u.recordTransfer(false, int64(len(data)))
+	if checksum != "" && sha256Hex(data) != checksum {
+		return nil, ErrChecksumMismatch
+	}
+	return data, nil
+}
+
+// readCopies returns chunk index from the first copy that downloads and
+// verifies.
+func (u *Uploader) readCopies(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error) {
+	var errs []error
+	for _, loc := range rec.ChunkLocations(index) {
+		data, err := u.readLocation(ctx, loc, rec.ChunkChecksum(index))
+		if err == nil {
+			return data, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("copy %q: %w", loc.FileID, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// fetchChunk returns chunk index, falling back to replicas and then to
+// rebuilding it from its stripe's parity.
+func (u *Uploader) fetchChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error) {
+	data, err := u.readCopies(ctx, rec, index)
+	if err == nil || rec.ParityShards == 0 || ctx.Err() != nil {
+		return data, err
+	}
+
+	data, rerr := u.reconstructChunk(ctx, rec, index)
+	if rerr != nil {
+		return nil, errors.Join(err, fmt.Errorf("reconstruct: %w", rerr))
+	}
+	return data, nil
+}
+
+func (u *Uploader) reconstructChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error) {
+	e, err := ingestion.NewErasure(rec.DataShards, rec.ParityShards)
+	if err != nil {
+		return nil, err
+	}
+	k, m := rec.DataShards, rec.ParityShards
+	stripe := index / k
+	first := stripe * k
+
+	// Chunks past the end of the file are implicit zeros and count as
+	// present.
+	have := 0
+	sizes := make([]int, k)
+	for j := range sizes {
+		if first+j < len(rec.ChunkIds) {
+			sizes[j] = int(u.chunkLen(rec, first+j))
+		} else {
+			have++
+		}
+	}
+
+	data := make([][]byte, k)
+	for j := 0; j < k && have < k; j++ {
+		if sizes[j] == 0 || first+j == index {
+			continue
+		}
+		if d, err := u.readCopies(ctx, rec, first+j); err == nil {
+			data[j] = d
+			have++
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	parity := make([][]byte, m)
+	for p := 0; p < m && have < k; p++ {
+		i := stripe*m + p
+		if i >= len(rec.ParityChunks) {
+			break
+		}
+		checksum := ""
+		if i < len(rec.ParityChecksums) {
+			checksum = rec.ParityChecksums[i]
+		}
+		if d, err := u.readLocation(ctx, rec.ParityChunks[i], checksum); err == nil {
+			parity[p] = d
+			have++
+		} else if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+	}
+
+	if have < k {
+		return nil, fmt.Errorf("stripe %d has %d of the %d chunks needed", stripe, have, k)
+	}
+	if err := e.Reconstruct(data, parity, sizes); err != nil {
+		return nil, err
+	}
+	return data[index-first], nil
 }
 
 func (u *Uploader) OffloadFile(ctx context.Context, name string, chatID string) error {
+	rec, err := u.Offload(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if err := u.BackupMetadata(ctx, chatID); err != nil {
+		rec.State = model.StateLocal
+		_ = u.Store.Update(ctx, rec)
+		return err
+	}
+
+	return nil
+}
+
+// Offload is OffloadFile without the metadata backup, for callers that
+// offload many files and back up once. It returns the updated record.
+func (u *Uploader) Offload(ctx context.Context, name string) (*model.FileRecord, error) {
 	rec, err := u.Store.Get(ctx, name)
 	if err != nil {
-		return fmt.Errorf("lookup %q: %w", name, err)
+		return nil, fmt.Errorf("lookup %q: %w", name, err)
 	}
 
 	localPath := filepath.Join(u.SyncFolder, rec.Name)
 	if err := os.Remove(localPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("remove local file: %w", err)
+		return nil, fmt.Errorf("remove local file: %w", err)
 	}
 
 	rec.State = model.StateCloud
 	if err := u.Store.Update(ctx, rec); err != nil {
-		return fmt.Errorf("update local metadata: %w", err)
+		return nil, fmt.Errorf("update local metadata: %w", err)
 	}
 
-	if err := u.BackupMetadata(ctx, chatID); err != nil {
-		rec.State = model.StateLocal
-		_ = u.Store.Update(ctx, rec)
-		return err
+	return rec, nil
+}
+
+// OffloadMissing marks the local records whose files are not in the sync
+// folder as offloaded, as after the sync folder changed without the files
+// being moved along. Their chunks are still stored, so they can be
+// downloaded again. It returns how many records it marked.
+func (u *Uploader) OffloadMissing(ctx context.Context, chatID string) (int, error) {
+	recs, err := u.Store.List(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("list metadata: %w", err)
 	}
 
-	return nil
+	marked := 0
+	for _, rec := range recs {
+		if rec.State != model.StateLocal {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(u.SyncFolder, filepath.FromSlash(rec.Name))); !os.IsNotExist(err) {
+			continue
+		}
+		rec.State = model.StateCloud
+		if err := u.Store.Update(ctx, rec); err != nil {
+			return marked, fmt.Errorf("update metadata for %q: %w", rec.Name, err)
+		}
+		marked++
+	}
+
+	if marked > 0 {
+		if err := u.BackupMetadata(ctx, chatID); err != nil {
+			return marked, fmt.Errorf("backup metadata: %w", err)
+		}
+	}
+	return marked, nil
 }
 
 func (u *Uploader) DownloadFile(
@@ -174,95 +632,290 @@ func (u *Uploader) DownloadFile(
 	chatID string,
 	onProgress ProgressFn,
 ) error {
+	rec, err := u.Download(ctx, name, onProgress)
+	if err != nil {
+		return err
+	}
+
+	if err := u.BackupMetadata(ctx, chatID); err != nil {
+		rec.State = model.StateLocal
+		_ = u.Store.Update(ctx, rec)
+		return err
+	}
+
+	return nil
+}
+
+// Download is DownloadFile without the metadata backup, for callers that
+// download many files and back up once. It returns the updated record.
+func (u *Uploader) Download(ctx context.Context, name string, onProgress ProgressFn) (*model.FileRecord, error) {
 	rec, err := u.Store.Get(ctx, name)
 	if err != nil {
-		return fmt.Errorf("lookup %q: %w", name, err)
+		return nil, fmt.Errorf("lookup %q: %w", name, err)
 	}
 
 	totalSize := rec.Size
 	if totalSize <= 0 {
-		return fmt.Errorf("invalid total size %d", totalSize)
+		return nil, fmt.Errorf("invalid total size %d", totalSize)
 	}
 
 	dstPath := filepath.Join(u.SyncFolder, rec.Name)
 	tmpPath := dstPath + ".tmp"
 	if err := os.MkdirAll(filepath.Dir(dstPath), 0o700); err != nil {
-		return fmt.Errorf("create sync folder: %w", err)
+		return nil, fmt.Errorf("create sync folder: %w", err)
 	}
 	out, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
 	if err != nil {
-		return fmt.Errorf("open temp file: %w", err)
+		return nil, fmt.Errorf("open temp file: %w", err)
 	}
 	defer out.Close()
 
 	var downloaded int64
-	for _, fileID := range rec.ChunkIds {
-		rc, err := u.Client.DownloadFile(ctx, fileID)
+	for i, fileID := range rec.ChunkIds {
+		data, err := u.fetchChunk(ctx, rec, i)
 		if err != nil {
 			os.Remove(tmpPath)
-			return fmt.Errorf("download chunk %q: %w", fileID, err)
+			return nil, fmt.Errorf("download chunk %q: %w", fileID, err)
 		}
 
-		pr := &progressReader{
-			r:          rc,
-			total:      totalSize,
-			done:       &downloaded,
-			onProgress: onProgress,
-		}
-
-		if _, err := io.Copy(out, pr); err != nil {
-			rc.Close()
+		if _, err := out.Write(data); err != nil {
 			os.Remove(tmpPath)
-			return fmt.Errorf("assemble chunk %q: %w", fileID, err)
+			return nil, fmt.Errorf("assemble chunk %q: %w", fileID, err)
 		}
-		rc.Close()
+		downloaded += int64(len(data))
+		onProgress(float64(downloaded) / float64(totalSize) * 100)
 	}
 
 	if err := out.Sync(); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("sync temp file: %w", err)
+		return nil, fmt.Errorf("sync temp file: %w", err)
 	}
 	if err := out.Close(); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("close temp file: %w", err)
+		return nil, fmt.Errorf("close temp file: %w", err)
 	}
 	if err := os.Rename(tmpPath, dstPath); err != nil {
 		os.Remove(tmpPath)
-		return fmt.Errorf("rename to final file: %w", err)
+		return nil, fmt.Errorf("rename to final file: %w", err)
 	}
 
 	rec.State = model.StateLocal
 	if err := u.Store.Update(ctx, rec); err != nil {
-		return fmt.Errorf("update metadata: %w", err)
+		return nil, fmt.Errorf("update metadata: %w", err)
+	}
+	u.indexContent(rec, dstPath)
+
+	return rec, nil
+}
+
+// PutFile stores filePath under name, replacing an existing record only once
+// the new content has been uploaded.
+func (u *Uploader) PutFile(
+	ctx context.Context,
+	filePath string,
+	name string,
+	chatID string,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	return u.PutFileWith(ctx, filePath, name, chatID, PutOptions{}, onProgress)
+}
+
+// PutFileWith is PutFile with options.
+func (u *Uploader) PutFileWith(
+	ctx context.Context,
+	filePath string,
+	name string,
+	chatID string,
+	opts PutOptions,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	return u.put(ctx, name, chatID, filePath, func(name string) (*model.FileRecord, error) {
+		return u.sendFile(ctx, filePath, name, chatID, opts, onProgress)
+	})
+}
+
+// PutStream is PutFileWith for content read from r, size bytes long,
+// that is not in a file of its own. The record is always offloaded, and
+// without a file there is no preview or content index entry.
+func (u *Uploader) PutStream(
+	ctx context.Context,
+	r io.Reader,
+	size int64,
+	name string,
+	chatID string,
+	opts PutOptions,
+	onProgress ProgressFn,
+) (*model.FileRecord, error) {
+	opts.Offload = true
+	return u.put(ctx, name, chatID, "", func(name string) (*model.FileRecord, error) {
+		return u.sendContent(ctx, r, size, name, chatID, opts, onProgress)
+	})
+}
+
+// put stores the record send makes under name, in place of an existing
+// one, and backs up metadata. filePath holds the content, or is "" when
+// there is no file.
+func (u *Uploader) put(
+	ctx context.Context,
+	name string,
+	chatID string,
+	filePath string,
+	send func(name string) (*model.FileRecord, error),
+) (*model.FileRecord, error) {
+	name, err := metadata.CleanName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	old, err := u.Store.Get(ctx, name)
+	if err != nil && !errors.Is(err, metadata.ErrNotFound) {
+		return nil, fmt.Errorf("lookup %q: %w", name, err)
+	}
+
+	rec, err := send(name)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil {
+		if err := u.add(ctx, rec, filePath); err != nil {
+			return nil, err
+		}
+	} else {
+		rec.Description, rec.Tags, rec.Attributes = old.Description, old.Tags, old.Attributes
+		if err := u.replace(ctx, old, rec, filePath); err != nil {
+			return nil, fmt.Errorf("replace %q: %w", name, err)
+		}
 	}
 
 	if err := u.BackupMetadata(ctx, chatID); err != nil {
-		rec.State = model.StateLocal
-		_ = u.Store.Update(ctx, rec)
+		return nil, fmt.Errorf("backup metadata: %w", err)
+	}
+
+	return rec, nil
+}
+
+// replace puts rec in the place of old, a record of the same name, and its
+// content at filePath in the sync folder. Overwriting is not deleting, so
+// old skips the trash; its chunks are left for garbage collection. The
+// record is swapped first so the sync watcher ignores the file changes.
+// Descriptions, tags and attributes belong to the name and carry over.
+// When the file cannot be put in place, old is restored.
+func (u *Uploader) replace(ctx context.Context, old, rec *model.FileRecord, filePath string) error {
+	if err := u.Store.Update(ctx, rec); err != nil {
+		return fmt.Errorf("update metadata: %w", err)
+	}
+
+	localPath := filepath.Join(u.SyncFolder, filepath.FromSlash(rec.Name))
+	var err error
+	switch {
+	case rec.State == model.StateLocal:
+		err = sync.MoveFile(filePath, localPath)
+	case old.State == model.StateLocal:
+		if rerr := os.Remove(localPath); rerr != nil && !os.IsNotExist(rerr) {
+			err = fmt.Errorf("remove local file: %w", rerr)
+		}
+	}
+	if err != nil {
+		if err2 := u.Store.Update(ctx, old); err2 != nil {
+			return fmt.Errorf("%v; rollback failed: %w", err, err2)
+		}
 		return err
 	}
 
+	if rec.State != model.StateLocal {
+		localPath = filePath
+	}
+	if localPath != "" {
+		u.indexContent(rec, localPath)
+	}
+
 	return nil
 }
 
-func (u *Uploader) DeleteFile(ctx context.Context, name string, chatID string) error {
-	rec, err := u.Store.Get(ctx, name)
+// RenameFile moves a record and its local copy, if any, to newName. The
+// record is renamed first so the sync watcher ignores the file move.
+func (u *Uploader) RenameFile(ctx context.Context, oldName, newName string, chatID string) error {
+	if err := u.rename(ctx, oldName, newName); err != nil {
+		return err
+	}
+	return u.BackupMetadata(ctx, chatID)
+}
+
+// rename is RenameFile without the metadata backup.
+func (u *Uploader) rename(ctx context.Context, oldName, newName string) error {
+	newName, err := metadata.CleanName(newName)
 	if err != nil {
-		return fmt.Errorf("lookup %q: %w", name, err)
+		return err
 	}
 
-	path := filepath.Join(u.SyncFolder, rec.Name)
-	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("delete local file %q: %w", path, err)
+	rec, err := u.Store.Get(ctx, oldName)
+	if err != nil {
+		return fmt.Errorf("lookup %q: %w", oldName, err)
+	}
+	if _, err := u.Store.Get(ctx, newName); err == nil {
+		return fmt.Errorf("rename to %q: %w", newName, metadata.ErrAlreadyExists)
 	}
 
-	if err := u.Store.Delete(ctx, name); err != nil {
-		return fmt.Errorf("delete metadata for %q: %w", name, err)
+	renamed := *rec
+	renamed.Name = newName
+	if err := u.Store.Create(ctx, &renamed); err != nil {
+		return fmt.Errorf("create metadata for %q: %w", newName, err)
+	}
+	if err := u.Store.Delete(ctx, oldName); err != nil {
+		_ = u.Store.Delete(ctx, newName)
+		return fmt.Errorf("delete metadata for %q: %w", oldName, err)
+	}
+
+	if rec.State == model.StateLocal {
+		src := filepath.Join(u.SyncFolder, filepath.FromSlash(oldName))
+		dst := filepath.Join(u.SyncFolder, filepath.FromSlash(newName))
+		if err := sync.MoveFile(src, dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+			_ = u.Store.Delete(ctx, newName)
+			_ = u.Store.Create(ctx, rec)
+			return fmt.Errorf("move local file: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// LegacyChunkSize is the chunk size of records from before FileRecord
+// stored one, when it was fixed.
+const LegacyChunkSize = 5 << 20
+
+func (u *Uploader) ChunkSizeOf(rec *model.FileRecord) int64 {
+	if rec.ChunkSize > 0 {
+		return rec.ChunkSize
+	}
+	return LegacyChunkSize
+}
+
+func (u *Uploader) ReadChunk(ctx context.Context, rec *model.FileRecord, index int) ([]byte, error) {
+	if index < 0 || index >= len(rec.ChunkIds) {
+		return nil, fmt.Errorf("chunk %d out of range for %q", index, rec.Name)
+	}
+
+	if u.Cache == nil {
+		return u.fetchChunk(ctx, rec, index)
+	}
+	return u.Cache.Get(ctx, rec.ChunkIds[index], func(ctx context.Context) (io.ReadCloser, error) {
+		data, err := u.fetchChunk(ctx, rec, index)
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+// DeleteFile moves a file to the trash and removes its local copy. The
+// chunks stay in Telegram until the trash is purged.
+func (u *Uploader) DeleteFile(ctx context.Context, name string, chatID string) error {
+	if err := u.trash(ctx, name); err != nil {
+		return err
 	}
 
 	if err := u.BackupMetadata(ctx, chatID); err != nil {
-		rec.State = model.StateCloud
-		if err2 := u.Store.Create(ctx, rec); err2 != nil {
+		if err2 := u.Store.Restore(ctx, name); err2 != nil {
 			return fmt.Errorf("backup failed: %v; rollback failed: %w", err, err2)
 		}
 		return fmt.Errorf("backup metadata failed: %w", err)
@@ -270,3 +923,22 @@ func (u *Uploader) DeleteFile(ctx context.Context, name string, chatID string) e
 
 	return nil
 }
+
+// trash is DeleteFile without the metadata backup.
+func (u *Uploader) trash(ctx context.Context, name string) error {
+	rec, err := u.Store.Get(ctx, name)
+	if err != nil {
+		return fmt.Errorf("lookup %q: %w", name, err)
+	}
+
+	path := filepath.Join(u.SyncFolder, rec.Name)
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("delete local file %q: %w", path, err)
+	}
+
+	if err := u.Store.Trash(ctx, name, time.Now()); err != nil {
+		return fmt.Errorf("trash %q: %w", name, err)
+	}
+
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,8 +13,10 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 	"tstore/internal/metadata"
+	"tstore/pkg/model"
 )
 
 func TestUploader_UploadFile_WithProgress(t *testing.T) {
@@ -31,6 +34,11 @@ func TestUploader_UploadFile_WithProgress(t *testing.T) {
 	}
 
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost && r.URL.Path == "/pinChatMessage" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+			return
+		}
 		if r.Method != http.MethodPost || r.URL.Path != "/sendDocument" {
 			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
 		}
@@ -53,6 +61,12 @@ func TestUploader_UploadFile_WithProgress(t *testing.T) {
 			t.Fatalf("read part: %v", err)
 		}
 
+		if header.Filename == "meta.json" {
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"document":{"file_id":"meta"}}}`)
+			return
+		}
+
 		var idx int
 		if _, err := fmt.Sscanf(header.Filename, "chunk_%d", &idx); err != nil {
 			t.Fatalf("invalid filename %q: %v", header.Filename, err)
@@ -108,6 +122,9 @@ func TestUploader_UploadFile_WithProgress(t *testing.T) {
 	if !reflect.DeepEqual(rec.ChunkIds, wantIDs) {
 		t.Errorf("ChunkIds = %v; want %v", rec.ChunkIds, wantIDs)
 	}
+	if rec.ChunkSize != 4 {
+		t.Errorf("ChunkSize = %d; want 4", rec.ChunkSize)
+	}
 
 	if len(progresses) != 2 {
 		t.Fatalf("progress callbacks = %d; want 2", len(progresses))
@@ -141,3 +158,252 @@ func TestUploader_UploadFile_WithProgress(t *testing.T) {
 		t.Errorf("stored record = %+v; want %+v", stored, rec)
 	}
 }
+
+func TestUploader_PutFile_ReplacesExisting(t *testing.T) {
+	var sent int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/pinChatMessage":
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+		case "/sendDocument":
+			sent++
+			fmt.Fprintf(w, `{"ok":true,"result":{"message_id":%d,"document":{"file_id":"fid_%d"}}}`, sent, sent)
+		default:
+			t.Fatalf("unexpected request to %s", r.URL.Path)
+		}
+	}))
+	defer srv.Close()
+
+	tmp := t.TempDir()
+	syncDir := filepath.Join(tmp, "sync")
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	u := NewUploader(&Client{baseURL: srv.URL, client: srv.Client()}, store, syncDir, 1024)
+
+	ctx := context.Background()
+	put := func(content string) {
+		t.Helper()
+		src := filepath.Join(tmp, "src.txt")
+		if err := os.WriteFile(src, []byte(content), 0o600); err != nil {
+			t.Fatalf("write source: %v", err)
+		}
+		if _, err := u.PutFile(ctx, src, "docs/a.txt", "1", nil); err != nil {
+			t.Fatalf("PutFile(%q): %v", content, err)
+		}
+	}
+
+	put("first")
+	rec, err := store.Get(ctx, "docs/a.txt")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	rec.Description = "notes"
+	if err := store.Update(ctx, rec); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	put("second")
+
+	recs, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(recs) != 1 || recs[0].Name != "docs/a.txt" || recs[0].Size != int64(len("second")) {
+		t.Fatalf("records = %+v; want a single docs/a.txt of the second size", recs)
+	}
+	if recs[0].Description != "notes" {
+		t.Errorf("description = %q; want it kept across the overwrite", recs[0].Description)
+	}
+	if trashed, _ := store.ListTrash(ctx); len(trashed) != 0 {
+		t.Errorf("trash = %+v; want the old version left out of it", trashed)
+	}
+	// One chunk and one metadata backup per put.
+	if sent != 4 {
+		t.Errorf("sent %d documents; want 4", sent)
+	}
+
+	data, err := os.ReadFile(filepath.Join(syncDir, "docs", "a.txt"))
+	if err != nil {
+		t.Fatalf("read synced file: %v", err)
+	}
+	if string(data) != "second" {
+		t.Errorf("synced content = %q; want %q", data, "second")
+	}
+}
+
+func TestUploader_PutFile_RestoresRecordWhenReplaceFails(t *testing.T) {
+	srv, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	syncDir := filepath.Join(tmp, "sync")
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	ctx := context.Background()
+	old := &model.FileRecord{Name: "docs/a.txt", State: model.StateCloud, Size: 5, ChunkIds: []string{"old"}}
+	if err := store.Create(ctx, old); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	// A directory in the way keeps the new content from being moved in.
+	if err := os.MkdirAll(filepath.Join(syncDir, "docs", "a.txt", "x"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	src := filepath.Join(tmp, "src.txt")
+	if err := os.WriteFile(src, []byte("second"), 0o600); err != nil {
+		t.Fatalf("write source: %v", err)
+	}
+	u := NewUploader(testClient(srv), store, syncDir, 1024)
+	if _, err := u.PutFile(ctx, src, "docs/a.txt", "1", nil); err == nil {
+		t.Fatal("PutFile succeeded with the local path taken by a directory")
+	}
+
+	recs, err := store.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(recs) != 1 || !reflect.DeepEqual(recs[0].ChunkIds, old.ChunkIds) {
+		t.Errorf("records = %+v; want only the original docs/a.txt", recs)
+	}
+	if trashed, _ := store.ListTrash(ctx); len(trashed) != 0 {
+		t.Errorf("trash = %+v; want it empty", trashed)
+	}
+}
+
+// moveCheckStore notes whether a new record's file was already in the sync
+// folder when the record was created.
+type moveCheckStore struct {
+	metadata.Store
+	syncDir string
+	early   []string
+}
+
+func (s *moveCheckStore) Create(ctx context.Context, rec *model.FileRecord) error {
+	if _, err := os.Stat(filepath.Join(s.syncDir, rec.Name)); err == nil {
+		s.early = append(s.early, rec.Name)
+	}
+	return s.Store.Create(ctx, rec)
+}
+
+func TestUploader_UploadFile_CreatesRecordBeforeMoving(t *testing.T) {
+	srv, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	syncDir := filepath.Join(tmp, "sync")
+	js, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	store := &moveCheckStore{Store: js, syncDir: syncDir}
+	u := NewUploader(testClient(srv), store, syncDir, 1024)
+	ctx := context.Background()
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(tmp, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("write source: %v", err)
+		}
+	}
+	if _, err := u.UploadFile(ctx, filepath.Join(tmp, "a.txt"), "1", nil); err != nil {
+		t.Fatalf("UploadFile: %v", err)
+	}
+	if len(store.early) != 0 {
+		t.Errorf("files in the sync folder before their records: %v", store.early)
+	}
+
+	// A directory in the way keeps b.txt from being moved in.
+	if err := os.MkdirAll(filepath.Join(syncDir, "b.txt", "x"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if _, err := u.UploadFile(ctx, filepath.Join(tmp, "b.txt"), "1", nil); err == nil {
+		t.Fatal("UploadFile succeeded with the local path taken by a directory")
+	}
+	if _, err := store.Get(ctx, "b.txt"); !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("record left after the move failed: %v", err)
+	}
+}
+
+func TestUploader_PutFileWith_OffloadAndETag(t *testing.T) {
+	srv, stored := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	syncDir := filepath.Join(tmp, "sync")
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	u := NewUploader(testClient(srv), store, syncDir, 1024)
+
+	ctx := context.Background()
+	src := filepath.Join(tmp, "src.txt")
+	for i, content := range []string{"first", "second"} {
+		if err := os.WriteFile(src, []byte(content), 0o600); err != nil {
+			t.Fatalf("write source: %v", err)
+		}
+		tag := fmt.Sprintf("tag-%d", i)
+		rec, err := u.PutFileWith(ctx, src, "docs/a.txt", "1", PutOptions{ETag: tag, Offload: true}, nil)
+		if err != nil {
+			t.Fatalf("PutFileWith(%q): %v", content, err)
+		}
+		if rec.State != model.StateCloud || rec.ETag != tag {
+			t.Errorf("record = state %s, etag %q; want cloud, %q", rec.State, rec.ETag, tag)
+		}
+		if _, err := os.Stat(src); err != nil {
+			t.Errorf("source was not left in place: %v", err)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(syncDir, "docs", "a.txt")); !os.IsNotExist(err) {
+		t.Errorf("offloaded file has a local copy: %v", err)
+	}
+	backedUp := false
+	for _, data := range stored {
+		backedUp = backedUp || strings.Contains(data, `"etag": "tag-1"`)
+	}
+	if !backedUp {
+		t.Error("no metadata backup holds the ETag")
+	}
+}
+
+func TestUploader_PutStream(t *testing.T) {
+	srv, _ := chunkServer(t, false)
+
+	tmp := t.TempDir()
+	store, err := metadata.NewJSONStore(filepath.Join(tmp, "meta.json"))
+	if err != nil {
+		t.Fatalf("NewJSONStore: %v", err)
+	}
+	u := NewUploader(testClient(srv), store, filepath.Join(tmp, "sync"), 4)
+
+	ctx := context.Background()
+	for _, content := range []string{"first", "second one"} {
+		rec, err := u.PutStream(ctx, strings.NewReader(content), int64(len(content)), "bkt/obj", "1", PutOptions{ETag: "tag"}, nil)
+		if err != nil {
+			t.Fatalf("PutStream(%q): %v", content, err)
+		}
+		if rec.State != model.StateCloud || rec.Size != int64(len(content)) || rec.ETag != "tag" {
+			t.Errorf("record = %+v", rec)
+		}
+
+		var got []byte
+		for i := range rec.ChunkIds {
+			data, err := u.ReadChunk(ctx, rec, i)
+			if err != nil {
+				t.Fatalf("ReadChunk(%d): %v", i, err)
+			}
+			got = append(got, data...)
+		}
+		if string(got) != content {
+			t.Errorf("stored content = %q; want %q", got, content)
+		}
+	}
+
+	if _, err := u.PutStream(ctx, strings.NewReader("short"), 10, "bkt/short", "1", PutOptions{}, nil); err == nil {
+		t.Error("PutStream accepted a stream shorter than its size")
+	}
+	if _, err := store.Get(ctx, "bkt/short"); err == nil {
+		t.Error("short stream left a record")
+	}
+}
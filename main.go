@@ -2,7 +2,19 @@ package main
 
 import (
 	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
+	"tstore/internal/config"
+	"tstore/internal/metadata"
+	"tstore/internal/secrets"
 	"tstore/pkg/model"
 
 	"github.com/wailsapp/wails/v2"
@@ -14,12 +26,52 @@ import (
 //go:embed all:frontend/dist
 var assets embed.FS
 
+// setFlags collects repeated -set key=value flags.
+type setFlags map[string]string
+
+func (f setFlags) String() string {
+	return fmt.Sprint(map[string]string(f))
+}
+
+func (f setFlags) Set(kv string) error {
+	key, value, ok := strings.Cut(kv, "=")
+	if !ok {
+		return fmt.Errorf("want key=value, got %q", kv)
+	}
+	f[key] = value
+	return nil
+}
+
 func main() {
+	profile := flag.String("profile", config.DefaultProfile, "profile to open; each has its own config, metadata and sync folder")
+	configDir := flag.String("config-dir", "", "directory holding tstore's profiles (default $"+config.ConfigDirEnv+" or the user config directory)")
+	printCfg := flag.Bool("print-config", false, "print the effective config with secrets redacted and exit")
+	sets := make(setFlags)
+	flag.Var(sets, "set", "override a setting for this run, e.g. -set chunk_size=8388608; repeatable")
+	flag.Parse()
+
+	if err := setup(*profile, *configDir, sets); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(2)
+	}
+	if *printCfg {
+		if err := printConfig(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	app := &App{}
 	frameless := runtime.GOOS == "windows"
 
+	title := "tstore"
+	if *profile != config.DefaultProfile {
+		title += " – " + *profile
+	}
+
 	err := wails.Run(&options.App{
-		Title:     "tstore",
+		Title:     title,
 		Width:     1280,
 		Height:    768,
 		Frameless: frameless,
@@ -28,11 +80,19 @@ func main() {
 		},
 		OnStartup:  app.startup,
 		OnShutdown: app.shutdown,
+		// One window per profile: launching a profile that is already
+		// open focuses it, while other profiles run side by side.
+		SingleInstanceLock: &options.SingleInstanceLock{
+			UniqueId:               "tstore-profile-" + *profile,
+			OnSecondInstanceLaunch: app.onSecondInstanceLaunch,
+		},
 		Bind: []any{
 			app,
 		},
 		EnumBind: []any{
 			model.AllStates,
+			model.AllPreviewKinds,
+			metadata.AllSortFields,
 		},
 		Mac: &mac.Options{
 			TitleBar: &mac.TitleBar{
@@ -47,3 +107,73 @@ func main() {
 		println("Error:", err.Error())
 	}
 }
+
+func setup(profile, configDir string, sets setFlags) error {
+	if configDir != "" {
+		if err := config.SetConfigDir(configDir); err != nil {
+			return err
+		}
+	}
+	if err := config.SetProfile(profile); err != nil {
+		return err
+	}
+	return config.SetFlagOverrides(sets)
+}
+
+// printConfig writes the effective config as JSON with secrets redacted,
+// followed by where each override came from and any validation errors. It
+// reads config.json and the secret store but never writes either; secrets
+// in a vault that stays locked are marked as such.
+func printConfig(w io.Writer) error {
+	store, err := peekSecrets()
+	if err != nil {
+		return err
+	}
+	var (
+		cfg    *config.Config
+		locked bool
+	)
+	if store != nil {
+		cfg, locked, err = config.PeekConfigWithSecrets(store)
+	} else {
+		cfg, err = config.PeekConfig()
+	}
+	if err != nil {
+		return err
+	}
+
+	redacted, validate := cfg.Redacted(), cfg.Validate
+	if locked {
+		redacted, validate = cfg.RedactedLocked(), cfg.ValidateWithoutSecrets
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(redacted); err != nil {
+		return err
+	}
+	sources := config.Overridden()
+	for _, key := range slices.Sorted(maps.Keys(sources)) {
+		fmt.Fprintf(w, "%s: set by %s\n", key, sources[key])
+	}
+	return validate()
+}
+
+// peekSecrets opens the vault the app would use without creating it,
+// unlocked when MASTER_PASSWORD_ENV is set. It returns nil when there is no
+// vault yet.
+func peekSecrets() (secrets.Store, error) {
+	dir, err := config.ProfileDir()
+	if err != nil {
+		return nil, err
+	}
+	vault := secrets.NewVault(filepath.Join(dir, "secrets.vault"))
+	if !vault.Exists() {
+		return nil, nil
+	}
+	if password := os.Getenv(MASTER_PASSWORD_ENV); password != "" {
+		if err := vault.Unlock(password); err != nil {
+			return nil, fmt.Errorf("unlocking secret vault: %w", err)
+		}
+	}
+	return vault, nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"tstore/internal/config"
+	"tstore/internal/secrets"
+)
+
+func TestPrintConfig_LeavesConfigFileAlone(t *testing.T) {
+	t.Setenv(config.ConfigDirEnv, t.TempDir())
+	t.Setenv(MASTER_PASSWORD_ENV, "pw")
+	t.Setenv(config.EnvPrefix+"CONCURRENCY", "4")
+
+	// A plain-text token is what loading with secrets would move into the
+	// vault, rewriting config.json.
+	cfg := config.Defaults()
+	cfg.BotToken = "123456:abcdefghijklmnopqrstuvwxyzABCDEFGHI"
+	cfg.ChatID = "1"
+	cfg.SyncFolder = t.TempDir()
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	path, err := config.ConfigPath()
+	if err != nil {
+		t.Fatalf("ConfigPath: %v", err)
+	}
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	before, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := printConfig(&out); err != nil {
+		t.Fatalf("printConfig: %v", err)
+	}
+
+	after, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("config.json changed:\n%s\nwant:\n%s", after, before)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat: %v", err)
+	}
+	if !info.ModTime().Equal(old) {
+		t.Errorf("config.json mtime = %v, want %v", info.ModTime(), old)
+	}
+
+	got := out.String()
+	if strings.Contains(got, cfg.BotToken) || !strings.Contains(got, `"bot_token": "REDACTED"`) {
+		t.Errorf("bot token not redacted:\n%s", got)
+	}
+	if !strings.Contains(got, "concurrency: set by env\n") {
+		t.Errorf("override source missing from output:\n%s", got)
+	}
+}
+
+func TestPrintConfig_DoesNotCreateConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(config.ConfigDirEnv, dir)
+
+	var out bytes.Buffer
+	printConfig(&out)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("config dir has %d entries, want none", len(entries))
+	}
+	if !strings.Contains(out.String(), `"chunk_size"`) {
+		t.Errorf("defaults missing from output:\n%s", out.String())
+	}
+}
+
+func TestPrintConfig_MarksVaultSecrets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(config.ConfigDirEnv, dir)
+
+	vault := secrets.NewVault(filepath.Join(dir, "secrets.vault"))
+	if err := vault.Unlock("pw"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := vault.Set("bot_token", "123456:abcdefghijklmnopqrstuvwxyzABCDEFGHI"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	cfg := config.Defaults()
+	cfg.ChatID = "1"
+	if err := config.SaveConfig(cfg); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	before, err := os.ReadFile(vault.Path())
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := printConfig(&out); err != nil {
+		t.Fatalf("printConfig with the vault locked: %v", err)
+	}
+	if !strings.Contains(out.String(), `"bot_token": "(vault locked)"`) {
+		t.Errorf("locked bot token not marked:\n%s", out.String())
+	}
+
+	t.Setenv(MASTER_PASSWORD_ENV, "pw")
+	out.Reset()
+	if err := printConfig(&out); err != nil {
+		t.Fatalf("printConfig with the vault unlocked: %v", err)
+	}
+	if !strings.Contains(out.String(), `"bot_token": "REDACTED"`) {
+		t.Errorf("stored bot token not redacted:\n%s", out.String())
+	}
+
+	after, err := os.ReadFile(vault.Path())
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("printConfig rewrote the vault")
+	}
+}
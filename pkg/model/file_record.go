@@ -2,6 +2,7 @@ package model
 
 import (
 	"errors"
+	"slices"
 	"time"
 )
 
@@ -12,6 +13,7 @@ type FileState string
 const (
 	StateLocal FileState = "local"
 	StateCloud FileState = "cloud"
+	StateTrash FileState = "trash"
 )
 
 var AllStates = []struct {
@@ -20,14 +22,108 @@ var AllStates = []struct {
 }{
 	{StateLocal, "local"},
 	{StateCloud, "cloud"},
+	{StateTrash, "trash"},
+}
+
+type ChunkLocation struct {
+	Target string `json:"target,omitempty"`
+	FileID string `json:"file_id"`
+}
+
+type PreviewKind string
+
+const (
+	PreviewImage PreviewKind = "image"
+	PreviewText  PreviewKind = "text"
+)
+
+var AllPreviewKinds = []struct {
+	Value  PreviewKind
+	TSName string
+}{
+	{PreviewImage, "image"},
+	{PreviewText, "text"},
+}
+
+// Preview is a small stand-in for a file's content, stored as its own
+// document so it can be shown without downloading the file.
+type Preview struct {
+	Kind   PreviewKind `json:"kind"`
+	MIME   string      `json:"mime"`
+	FileID string      `json:"file_id"`
+	Size   int64       `json:"size"`
 }
 
 type FileRecord struct {
-	Name        string    `json:"name"`
-	State       FileState `json:"state"`
-	Description string    `json:"description"`
-	Size        int64     `json:"size"`
-	Checksum    string    `json:"checksum"`
-	UploadedAt  time.Time `json:"uploaded_at"`
-	ChunkIds    []string  `json:"chunk_ids"`
+	Name            string            `json:"name"`
+	State           FileState         `json:"state"`
+	Description     string            `json:"description"`
+	Tags            []string          `json:"tags,omitempty"`
+	Attributes      map[string]string `json:"attributes,omitempty"`
+	Size            int64             `json:"size"`
+	Checksum        string            `json:"checksum"`
+	UploadedAt      time.Time         `json:"uploaded_at"`
+	ChunkIds        []string          `json:"chunk_ids"`
+	ChunkSize       int64             `json:"chunk_size,omitempty"`
+	ChunkTargets    []string          `json:"chunk_targets,omitempty"`
+	Replicas        [][]ChunkLocation `json:"replicas,omitempty"`
+	ChunkChecksums  []string          `json:"chunk_checksums,omitempty"`
+	DataShards      int               `json:"data_shards,omitempty"`
+	ParityShards    int               `json:"parity_shards,omitempty"`
+	ParityChunks    []ChunkLocation   `json:"parity_chunks,omitempty"`
+	ParityChecksums []string          `json:"parity_checksums,omitempty"`
+	TrashedAt       *time.Time        `json:"trashed_at,omitempty"`
+	Preview         *Preview          `json:"preview,omitempty"`
+	ETag            string            `json:"etag,omitempty"`
+}
+
+// ChunkTarget names the storage target holding chunk index. Files stored on
+// the primary bot and chat have no ChunkTargets.
+func (r *FileRecord) ChunkTarget(index int) string {
+	if index < len(r.ChunkTargets) {
+		return r.ChunkTargets[index]
+	}
+	return ""
+}
+
+// ChunkLocations lists every stored copy of chunk index, primary first.
+func (r *FileRecord) ChunkLocations(index int) []ChunkLocation {
+	locs := []ChunkLocation{{Target: r.ChunkTarget(index), FileID: r.ChunkIds[index]}}
+	if index < len(r.Replicas) {
+		locs = append(locs, r.Replicas[index]...)
+	}
+	return locs
+}
+
+// SetChunkLocations replaces the copies recorded for chunk index. The first
+// location becomes the primary one. Records returned by a store share their
+// slices with it, so the slices are copied rather than written in place, and
+// grown to cover every chunk when older metadata recorded fewer entries.
+func (r *FileRecord) SetChunkLocations(index int, locs []ChunkLocation) {
+	r.ChunkIds = slices.Clone(r.ChunkIds)
+	r.ChunkIds[index] = locs[0].FileID
+	if locs[0].Target != "" || r.ChunkTargets != nil {
+		targets := make([]string, max(len(r.ChunkTargets), len(r.ChunkIds)))
+		copy(targets, r.ChunkTargets)
+		r.ChunkTargets = targets
+		r.ChunkTargets[index] = locs[0].Target
+	}
+
+	extra := locs[1:]
+	if len(extra) == 0 && index >= len(r.Replicas) {
+		return
+	}
+	replicas := make([][]ChunkLocation, max(len(r.Replicas), len(r.ChunkIds)))
+	copy(replicas, r.Replicas)
+	r.Replicas = replicas
+	r.Replicas[index] = append([]ChunkLocation(nil), extra...)
+}
+
+// ChunkChecksum is the sha256 of chunk index, or "" for files uploaded
+// before chunk checksums were recorded.
+func (r *FileRecord) ChunkChecksum(index int) string {
+	if index < len(r.ChunkChecksums) {
+		return r.ChunkChecksums[index]
+	}
+	return ""
 }
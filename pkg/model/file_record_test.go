@@ -0,0 +1,23 @@
+package model
+
+import "testing"
+
+func TestFileRecord_SetChunkLocationsGrowsShortSlices(t *testing.T) {
+	r := &FileRecord{
+		ChunkIds:     []string{"c0", "c1", "c2"},
+		ChunkTargets: []string{"a"},
+		Replicas:     [][]ChunkLocation{{{Target: "b", FileID: "r0"}}},
+	}
+
+	r.SetChunkLocations(2, []ChunkLocation{{Target: "b", FileID: "n2"}, {Target: "a", FileID: "r2"}})
+
+	if len(r.ChunkTargets) != 3 || len(r.Replicas) != 3 {
+		t.Fatalf("len(ChunkTargets) = %d, len(Replicas) = %d; want 3", len(r.ChunkTargets), len(r.Replicas))
+	}
+	if locs := r.ChunkLocations(2); len(locs) != 2 || locs[0].FileID != "n2" || locs[1].FileID != "r2" {
+		t.Errorf("chunk 2 locations = %+v", locs)
+	}
+	if locs := r.ChunkLocations(0); len(locs) != 2 || locs[0].Target != "a" || locs[1].FileID != "r0" {
+		t.Errorf("chunk 0 locations = %+v", locs)
+	}
+}